@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/usual2970/acto/lib"
+	"github.com/usual2970/acto/points"
+)
+
+// runCommand runs a one-off CLI subcommand instead of the HTTP server and
+// returns the process exit code.
+func runCommand(ctx context.Context, args []string) int {
+	switch args[0] {
+	case "reconcile":
+		return reconcileCommand(ctx, args[1:])
+	case "rebuild-rankings":
+		return rebuildRankingsCommand(ctx, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\nusage: %s [reconcile [--fix] [--uri <point type>] | rebuild-rankings [--uri <point type>]]\n", args[0], os.Args[0])
+	return 2
+}
+
+// reconcileCommand prints the reconciliation report as JSON. It exits with 1
+// while mismatches remain unfixed, so it can gate scheduled checks.
+func reconcileCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "repair drifted balance rows and ranking scores")
+	uri := fs.String("uri", "", "only reconcile this point type")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	svc, err := lib.GetServices()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if svc.ReconcileService == nil {
+		fmt.Fprintln(os.Stderr, "reconcile: no reconciliation repository configured")
+		return 1
+	}
+	rep, err := svc.ReconcileService.Reconcile(ctx, points.ReconcileRequest{URI: *uri, Fix: *fix})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := printJSON(rep); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(rep.Mismatches) > rep.Fixed {
+		return 1
+	}
+	return 0
+}
+
+// rebuildRankingsCommand repopulates the Redis rankings from MySQL and prints
+// the number of users written per point type.
+func rebuildRankingsCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("rebuild-rankings", flag.ContinueOnError)
+	uri := fs.String("uri", "", "only rebuild this point type")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	svc, err := lib.GetServices()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	res, err := svc.RankingRebuildService.Rebuild(ctx, *uri)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := printJSON(res); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/usual2970/acto/internal/config"
@@ -9,7 +10,9 @@ import (
 
 	"database/sql"
 	"net/http"
+	"os"
 	"regexp"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
@@ -49,6 +52,10 @@ func (g ginAdapter) NoRoute(h http.Handler) {
 	g.r.NoRoute(gin.HandlerFunc(ginHandler))
 }
 
+// defaultExpiryInterval is how often the server expires points when
+// EXPIRY_INTERVAL is unset; the library itself leaves expiry off.
+const defaultExpiryInterval = time.Minute
+
 func main() {
 	// Load config
 	cfg := config.Load()
@@ -59,7 +66,7 @@ func main() {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET,POST,PATCH,DELETE,OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Role")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Role, Idempotency-Key")
 		c.Header("Vary", "Origin")
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -76,13 +83,26 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to open mysql connection: %v", err)
 	}
-	redisClient := goRedis.NewClient(&goRedis.Options{Addr: cfg.RedisAddr})
+	var redisClient *goRedis.Client
+	if cfg.RankingBackend == config.RankingBackendRedis {
+		redisClient = goRedis.NewClient(&goRedis.Options{Addr: cfg.RedisAddr})
+	}
 
 	// Create library via one-shot setup (hides internal DI)
 	if err := lib.Setup(db, redisClient); err != nil {
 		log.Fatalf("failed to init library: %v", err)
 	}
 
+	// Run a CLI subcommand (e.g. "reconcile") instead of serving
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(context.Background(), os.Args[1:]))
+	}
+
+	// Start periodic jobs (point expiry, ...)
+	if err := lib.StartBackgroundJobs(context.Background(), lib.WithDefaultExpiryInterval(defaultExpiryInterval)); err != nil {
+		log.Fatalf("failed to start background jobs: %v", err)
+	}
+
 	// Create registrar adapter for Gin
 	adapter := ginAdapter{r: r}
 
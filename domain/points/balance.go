@@ -1,9 +1,16 @@
 package points
 
-// UserBalance represents the balance of a specific point type for a user
+// UserBalance represents the balance of a specific point type for a user.
+// Balance includes Held, the part reserved by active holds.
 type UserBalance struct {
 	UserID      string `json:"userId"`
 	PointTypeID int64  `json:"pointTypeId"`
 	Balance     int64  `json:"balance"`
+	Held        int64  `json:"held"`
 	UpdatedAt   int64  `json:"updatedAt"`
 }
+
+// Available returns the part of the balance that is not reserved by holds.
+func (ub UserBalance) Available() int64 {
+	return ub.Balance - ub.Held
+}
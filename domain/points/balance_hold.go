@@ -0,0 +1,25 @@
+package points
+
+// BalanceHoldStatus defines the status of a balance hold
+type BalanceHoldStatus string
+
+const (
+	HoldActive   BalanceHoldStatus = "active"
+	HoldCaptured BalanceHoldStatus = "captured"
+	HoldReleased BalanceHoldStatus = "released"
+	HoldExpired  BalanceHoldStatus = "expired"
+)
+
+// BalanceHold reserves part of a user balance until it is captured (debited),
+// released, or expires.
+type BalanceHold struct {
+	ID          string            `json:"id"`
+	UserID      string            `json:"userId"`
+	PointTypeID int64             `json:"pointTypeId"`
+	Amount      int64             `json:"amount"`
+	Reason      string            `json:"reason"`
+	Status      BalanceHoldStatus `json:"status"`
+	ExpiresAt   int64             `json:"expiresAt"`
+	CreatedAt   int64             `json:"createdAt"`
+	UpdatedAt   int64             `json:"updatedAt"`
+}
@@ -0,0 +1,14 @@
+package points
+
+// BalanceLot tracks the unspent remainder of a single credit so that debits
+// can consume points oldest-first and expired remainders can be written off.
+type BalanceLot struct {
+	ID            string `json:"id"`
+	UserID        string `json:"userId"`
+	PointTypeID   int64  `json:"pointTypeId"`
+	TransactionID string `json:"transactionId"`
+	Amount        int64  `json:"amount"`
+	Remaining     int64  `json:"remaining"`
+	EarnedAt      int64  `json:"earnedAt"`
+	ExpiresAt     int64  `json:"expiresAt,omitempty"` // 0 when the lot never expires
+}
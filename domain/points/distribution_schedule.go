@@ -0,0 +1,141 @@
+package points
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/usual2970/acto/pkg/cron"
+)
+
+// DistributionSchedule runs a reward distribution for a point type at the
+// start of every daily, weekly or monthly period, or whenever a cron
+// expression fires, both evaluated in Timezone.
+type DistributionSchedule struct {
+	ID          int64         `json:"id"`
+	PointTypeID int64         `json:"pointTypeId"`
+	Period      RankingPeriod `json:"period,omitempty"` // daily, weekly or monthly; exclusive with Cron
+	Cron        string        `json:"cron,omitempty"`
+	TopN        int           `json:"topN"`
+	Timezone    string        `json:"timezone"`
+	// Window selects the ranking rewarded: empty for all-time, otherwise the
+	// last daily, weekly or monthly window that ended by the run.
+	Window        RankingPeriod `json:"window,omitempty"`
+	Enabled       bool          `json:"enabled"`
+	NextRunAt     int64         `json:"nextRunAt"`
+	LastPeriodKey string        `json:"lastPeriodKey,omitempty"`
+	LastRunAt     int64         `json:"lastRunAt,omitempty"`
+	LastError     string        `json:"lastError,omitempty"`
+	CreatedAt     int64         `json:"createdAt"`
+}
+
+func (s DistributionSchedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, ErrInvalidSchedule
+	}
+	return loc, nil
+}
+
+// periodLength orders the periods a schedule runs and rewards by
+var periodLength = map[RankingPeriod]int{PeriodDaily: 1, PeriodWeekly: 2, PeriodMonthly: 3}
+
+// Validate checks the trigger, timezone, TopN and window. A window may not be
+// longer than the period, which would reward the same window several times.
+func (s DistributionSchedule) Validate() error {
+	if s.TopN <= 0 || (s.Window != PeriodAllTime && periodLength[s.Window] == 0) {
+		return ErrInvalidSchedule
+	}
+	if s.Cron == "" && periodLength[s.Window] > periodLength[s.Period] {
+		return ErrInvalidSchedule
+	}
+	_, err := s.Next(time.Now())
+	return err
+}
+
+// periodStart returns the start of the daily, weekly or monthly period
+// containing t, in t's location.
+func periodStart(p RankingPeriod, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p {
+	case PeriodWeekly:
+		return day.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	case PeriodMonthly:
+		return day.AddDate(0, 0, 1-t.Day())
+	}
+	return day
+}
+
+// Next returns the first run strictly after t.
+func (s DistributionSchedule) Next(t time.Time) (time.Time, error) {
+	loc, err := s.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.In(loc)
+	if s.Cron != "" {
+		if s.Period != PeriodAllTime {
+			return time.Time{}, ErrInvalidSchedule
+		}
+		c, err := cron.Parse(s.Cron)
+		if err != nil {
+			return time.Time{}, ErrInvalidSchedule
+		}
+		next := c.Next(t)
+		if next.IsZero() {
+			return time.Time{}, ErrInvalidSchedule
+		}
+		return next, nil
+	}
+	start := periodStart(s.Period, t)
+	switch s.Period {
+	case PeriodDaily:
+		return start.AddDate(0, 0, 1), nil
+	case PeriodWeekly:
+		return start.AddDate(0, 0, 7), nil
+	case PeriodMonthly:
+		return start.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, ErrInvalidSchedule
+}
+
+// PeriodKey names the period a run at t belongs to, in the schedule's
+// timezone: the day, ISO week or month that starts at t, or the minute the
+// cron expression fired. A cron schedule with a window is keyed by the
+// window instead. A schedule distributes at most once per key.
+func (s DistributionSchedule) PeriodKey(t time.Time) string {
+	loc, err := s.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	switch {
+	case s.Cron != "" && s.Window != PeriodAllTime:
+		return s.RankingWindow(t).Key
+	case s.Cron != "":
+		return t.Format("2006-01-02T15:04")
+	case s.Period == PeriodWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case s.Period == PeriodMonthly:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// RankingWindow returns the ranking rewarded by a run at t: the last day,
+// week or month in the schedule's timezone that ended by t. Rankings are
+// kept per UTC window, so east of UTC the ranking of that name may still be
+// open when the run is due; runs wait for it to close.
+func (s DistributionSchedule) RankingWindow(t time.Time) RankingWindow {
+	if s.Window == PeriodAllTime {
+		return RankingWindow{}
+	}
+	loc, err := s.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	return windowOf(s.Window, periodStart(s.Window, t.In(loc)).Add(-time.Second))
+}
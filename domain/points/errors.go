@@ -14,4 +14,41 @@ var (
 	ErrRewardOutOfStock        = errors.New("reward out of stock")
 	ErrDistributionAlreadyDone = errors.New("distribution already executed for period")
 	ErrUnauthorizedOperation   = errors.New("unauthorized operation for role")
+	ErrIdempotencyKeyConflict  = errors.New("idempotency key reused with different parameters")
+	ErrTransferDisabled        = errors.New("transfers are disabled for point type")
+	ErrInvalidTransfer         = errors.New("invalid transfer")
+	ErrInvalidExpiryPolicy     = errors.New("invalid expiry policy")
+	ErrHoldNotFound            = errors.New("hold not found")
+	ErrHoldNotActive           = errors.New("hold is not active")
+	ErrTransactionNotFound     = errors.New("transaction not found")
+	ErrTransactionReversed     = errors.New("transaction already reversed")
+	ErrTransactionIrreversible = errors.New("transaction cannot be reversed")
+	ErrInvalidBatch            = errors.New("invalid batch request")
+	ErrImportJobNotFound       = errors.New("import job not found")
+	ErrInvalidImport           = errors.New("invalid import file")
+	ErrInvalidExport           = errors.New("invalid export request")
+	ErrInvalidTiePolicy        = errors.New("invalid tie policy")
+	ErrUserNotRanked           = errors.New("user not ranked")
+	ErrInvalidRankingWindow    = errors.New("invalid ranking window")
+	ErrInvalidSeason           = errors.New("invalid ranking season")
+	ErrSeasonNotFound          = errors.New("ranking season not found")
+	ErrInvalidRankingMetric    = errors.New("invalid ranking metric")
+	ErrInvalidSchedule         = errors.New("invalid distribution schedule")
+	ErrScheduleNotFound        = errors.New("distribution schedule not found")
+	ErrInvalidSnapshot         = errors.New("invalid ranking snapshot")
+	ErrSnapshotNotFound        = errors.New("ranking snapshot not found")
+	ErrPlanChanged             = errors.New("distribution plan changed")
+	ErrDistributionNotFound    = errors.New("distribution not found")
+	ErrDistributionIncomplete  = errors.New("distribution has failed payouts")
+	ErrInvalidRewardRule       = errors.New("invalid reward rule")
+	ErrRewardRuleOverlap       = errors.New("reward rule overlaps another rule")
+	ErrRewardRuleNotFound      = errors.New("reward rule not found")
+	ErrInvalidRewardPool       = errors.New("invalid reward pool amount")
+	ErrInvalidReward           = errors.New("invalid reward")
+	ErrRewardNotFound          = errors.New("reward not found")
+	ErrRedemptionNotFound      = errors.New("redemption not found")
+	ErrRedemptionTransition    = errors.New("invalid redemption status transition")
+	ErrRedemptionLimitReached  = errors.New("redemption limit reached")
+	ErrRewardUnavailable       = errors.New("reward unavailable")
+	ErrRankTooDeep             = errors.New("rank too deep for dense ties")
 )
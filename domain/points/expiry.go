@@ -0,0 +1,39 @@
+package points
+
+import "time"
+
+// ExpiryPolicy defines when credited points of a point type expire
+type ExpiryPolicy string
+
+const (
+	ExpiryNone       ExpiryPolicy = "none"
+	ExpiryTTL        ExpiryPolicy = "ttl"          // ExpiryDays after the credit
+	ExpiryEndOfMonth ExpiryPolicy = "end_of_month" // end of the calendar month of the credit
+	ExpiryEndOfYear  ExpiryPolicy = "end_of_year"  // end of the calendar year of the credit
+)
+
+// Valid reports whether p is a known policy. The empty policy means ExpiryNone.
+func (p ExpiryPolicy) Valid() bool {
+	switch p {
+	case "", ExpiryNone, ExpiryTTL, ExpiryEndOfMonth, ExpiryEndOfYear:
+		return true
+	}
+	return false
+}
+
+// LotExpiry returns the unix time at which points earned at t expire, or 0
+// when points of this type never expire.
+func (pt PointType) LotExpiry(t time.Time) int64 {
+	switch pt.ExpiryPolicy {
+	case ExpiryTTL:
+		if pt.ExpiryDays <= 0 {
+			return 0
+		}
+		return t.AddDate(0, 0, pt.ExpiryDays).Unix()
+	case ExpiryEndOfMonth:
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()).Unix()
+	case ExpiryEndOfYear:
+		return time.Date(t.Year()+1, time.January, 1, 0, 0, 0, 0, t.Location()).Unix()
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package points
+
+// ImportJobStatus defines the status of a balance import job
+type ImportJobStatus string
+
+const (
+	ImportPending   ImportJobStatus = "pending"
+	ImportRunning   ImportJobStatus = "running"
+	ImportCompleted ImportJobStatus = "completed"
+	ImportFailed    ImportJobStatus = "failed"
+)
+
+// ImportFormat is the file format of a balance import
+type ImportFormat string
+
+const (
+	ImportCSV   ImportFormat = "csv"
+	ImportJSONL ImportFormat = "jsonl"
+)
+
+// ImportJob tracks the progress of an asynchronous balance import
+type ImportJob struct {
+	ID            string          `json:"id"`
+	Format        ImportFormat    `json:"format"`
+	FileHash      string          `json:"fileHash"` // sha256 of the uploaded file
+	Status        ImportJobStatus `json:"status"`
+	ProcessedRows int64           `json:"processedRows"`
+	SucceededRows int64           `json:"succeededRows"`
+	FailedRows    int64           `json:"failedRows"`
+	Error         string          `json:"error,omitempty"` // set when the job itself failed
+	CreatedAt     int64           `json:"createdAt"`
+	UpdatedAt     int64           `json:"updatedAt"`
+}
+
+// ImportRowError records a row that could not be applied
+type ImportRowError struct {
+	JobID string `json:"jobId"`
+	Row   int64  `json:"row"` // 1-based data row number, excluding any header
+	Data  string `json:"data"`
+	Error string `json:"error"`
+}
@@ -2,11 +2,15 @@ package points
 
 // PointType represents a type of points in the system
 type PointType struct {
-	ID          int64  `json:"id"`
-	URI         string `json:"uri"`
-	DisplayName string `json:"displayName"`
-	Description string `json:"description"`
-	Enabled     bool   `json:"enabled"`
-	DeletedAt   *int64 `json:"deletedAt,omitempty"`
-	CreatedAt   int64  `json:"createdAt"`
+	ID              int64         `json:"id"`
+	URI             string        `json:"uri"`
+	DisplayName     string        `json:"displayName"`
+	Description     string        `json:"description"`
+	Enabled         bool          `json:"enabled"`
+	TransferEnabled bool          `json:"transferEnabled"` // users may transfer balances of this type to each other
+	ExpiryPolicy    ExpiryPolicy  `json:"expiryPolicy"`
+	ExpiryDays      int           `json:"expiryDays,omitempty"` // lifetime of a credit under ExpiryTTL
+	RankingMetric   RankingMetric `json:"rankingMetric"`        // score of the all-time ranking
+	DeletedAt       *int64        `json:"deletedAt,omitempty"`
+	CreatedAt       int64         `json:"createdAt"`
 }
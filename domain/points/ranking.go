@@ -0,0 +1,143 @@
+package points
+
+import (
+	"fmt"
+	"time"
+)
+
+// TiePolicy decides how users with equal scores are ranked
+type TiePolicy string
+
+const (
+	// ties share a rank and the next rank skips: 1, 2, 2, 4
+	TieStandard TiePolicy = "standard"
+	// ties share a rank and the next rank follows: 1, 2, 2, 3
+	TieDense TiePolicy = "dense"
+)
+
+func (p TiePolicy) Valid() bool {
+	return p == TieStandard || p == TieDense
+}
+
+// RankingMetric is the score of a point type's all-time ranking
+type RankingMetric string
+
+const (
+	// current balance; spending points lowers the rank
+	MetricBalance RankingMetric = "balance"
+	// every point ever credited, less reversed credits
+	MetricLifetimeCredits RankingMetric = "lifetime_credits"
+	// balance changes except redemptions and their reversals
+	MetricNetEarned RankingMetric = "net_earned"
+)
+
+func (m RankingMetric) Valid() bool {
+	return m == MetricBalance || m == MetricLifetimeCredits || m == MetricNetEarned
+}
+
+// RankingEntry is one user's position in a ranking. Rank is 1-based.
+type RankingEntry struct {
+	Rank   int64  `json:"rank"`
+	UserID string `json:"userId"`
+	Score  int64  `json:"score"`
+}
+
+// RankingPeriod is the time window a ranking counts points over
+type RankingPeriod string
+
+const (
+	// all-time ranking by current balance
+	PeriodAllTime RankingPeriod = ""
+	// rankings by points earned within the window
+	PeriodDaily   RankingPeriod = "daily"
+	PeriodWeekly  RankingPeriod = "weekly" // ISO week
+	PeriodMonthly RankingPeriod = "monthly"
+	PeriodSeason  RankingPeriod = "season"
+)
+
+// RankingWindow selects one ranking of a point type. The zero value is the
+// all-time ranking; otherwise Key names the period instance: 2006-01-02 for
+// daily, 2006-W01 for weekly, 2006-01 for monthly, or the season name.
+type RankingWindow struct {
+	Period RankingPeriod `json:"period"`
+	Key    string        `json:"key,omitempty"`
+}
+
+// WindowAt returns the daily, weekly or monthly window containing t, in UTC.
+func WindowAt(p RankingPeriod, t time.Time) RankingWindow {
+	return windowOf(p, t.UTC())
+}
+
+// windowOf names the window containing t by the calendar of t's location.
+func windowOf(p RankingPeriod, t time.Time) RankingWindow {
+	switch p {
+	case PeriodDaily:
+		return RankingWindow{Period: p, Key: t.Format("2006-01-02")}
+	case PeriodWeekly:
+		year, week := t.ISOWeek()
+		return RankingWindow{Period: p, Key: fmt.Sprintf("%04d-W%02d", year, week)}
+	case PeriodMonthly:
+		return RankingWindow{Period: p, Key: t.Format("2006-01")}
+	}
+	return RankingWindow{}
+}
+
+// Bounds returns the UTC start and exclusive end of a daily, weekly or monthly
+// window, failing with ErrInvalidRankingWindow for malformed keys. Seasons
+// carry their own bounds.
+func (w RankingWindow) Bounds() (time.Time, time.Time, error) {
+	switch w.Period {
+	case PeriodDaily:
+		start, err := time.Parse("2006-01-02", w.Key)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidRankingWindow
+		}
+		return start, start.AddDate(0, 0, 1), nil
+	case PeriodWeekly:
+		var year, week int
+		if _, err := fmt.Sscanf(w.Key, "%04d-W%02d", &year, &week); err != nil || week < 1 || week > 53 {
+			return time.Time{}, time.Time{}, ErrInvalidRankingWindow
+		}
+		// the Monday of ISO week 1 is in the week containing January 4th
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+		start := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+		if y, wk := start.ISOWeek(); y != year || wk != week {
+			return time.Time{}, time.Time{}, ErrInvalidRankingWindow
+		}
+		return start, start.AddDate(0, 0, 7), nil
+	case PeriodMonthly:
+		start, err := time.Parse("2006-01", w.Key)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidRankingWindow
+		}
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, time.Time{}, ErrInvalidRankingWindow
+}
+
+// RankingSeason is a named window with explicit bounds, shared by all point types
+type RankingSeason struct {
+	Name      string `json:"name"`
+	StartsAt  int64  `json:"startsAt"`
+	EndsAt    int64  `json:"endsAt"` // exclusive
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// Contains reports whether the unix time t falls within the season.
+func (s RankingSeason) Contains(t int64) bool {
+	return t >= s.StartsAt && t < s.EndsAt
+}
+
+// RankingSnapshot freezes the top of a ranking as it stood at CreatedAt, so
+// distributions and later disputes see the same standings.
+type RankingSnapshot struct {
+	ID          string        `json:"id"`
+	PointTypeID int64         `json:"pointTypeId"`
+	Window      RankingWindow `json:"window"`
+	TiePolicy   TiePolicy     `json:"tiePolicy"`
+	TopN        int           `json:"topN"`
+	Entries     int           `json:"entries"` // fewer than TopN when fewer users were ranked
+	// Participants counts everyone ranked, beyond the TopN entries kept
+	Participants int64 `json:"participants"`
+	CreatedAt    int64 `json:"createdAt"`
+}
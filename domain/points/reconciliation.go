@@ -0,0 +1,40 @@
+package points
+
+// ReconciliationKind names the check that found a mismatch
+type ReconciliationKind string
+
+const (
+	// stored balance differs from the replayed ledger total
+	ReconcileBalance ReconciliationKind = "balance"
+	// replayed ledger total differs from the after_balance of the last entry
+	ReconcileLedger ReconciliationKind = "ledger"
+	// ranking score differs from the stored balance or is missing
+	ReconcileRanking ReconciliationKind = "ranking"
+	// ranking member without a balance row
+	ReconcileRankingOrphan ReconciliationKind = "ranking_orphan"
+)
+
+// ReconciliationMismatch is one discrepancy found by a reconciliation run
+type ReconciliationMismatch struct {
+	Kind         ReconciliationKind `json:"kind"`
+	UserID       string             `json:"userId"`
+	PointTypeID  int64              `json:"pointTypeId"`
+	URI          string             `json:"uri"`
+	Balance      int64              `json:"balance"`
+	LedgerTotal  int64              `json:"ledgerTotal"`
+	LastAfter    int64              `json:"lastAfter"`
+	RankingScore *int64             `json:"rankingScore"` // nil when the user is not in the ranking
+	Fixed        bool               `json:"fixed"`
+	FixError     string             `json:"fixError,omitempty"`
+}
+
+// ReconciliationFix records a change made by a reconciliation run in fix mode
+type ReconciliationFix struct {
+	RunID       string             `json:"runId"`
+	Kind        ReconciliationKind `json:"kind"`
+	UserID      string             `json:"userId"`
+	PointTypeID int64              `json:"pointTypeId"`
+	OldValue    *int64             `json:"oldValue"` // nil when the ranking member was missing
+	NewValue    *int64             `json:"newValue"` // nil when the ranking member was removed
+	CreatedAt   int64              `json:"createdAt"`
+}
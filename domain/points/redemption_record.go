@@ -4,17 +4,38 @@ package points
 type RedemptionStatus string
 
 const (
+	// RedemptionCompleted marks records made before redemptions became orders.
 	RedemptionCompleted RedemptionStatus = "completed"
 	RedemptionPending   RedemptionStatus = "pending"
+	RedemptionFulfilled RedemptionStatus = "fulfilled"
 	RedemptionCancelled RedemptionStatus = "cancelled"
 )
 
-// RedemptionRecord represents a record of user redeeming rewards
+// CanTransitionTo reports whether an order in status s may move to next: a
+// pending order is either fulfilled or cancelled, and both are final.
+func (s RedemptionStatus) CanTransitionTo(next RedemptionStatus) bool {
+	return s == RedemptionPending && (next == RedemptionFulfilled || next == RedemptionCancelled)
+}
+
+// RedemptionRecord represents a record of user redeeming rewards. It is an
+// order: created pending once the costs are paid, then fulfilled or
+// cancelled, which refunds the costs.
 type RedemptionRecord struct {
 	ID        string           `json:"id"`
 	UserID    string           `json:"userId"`
 	RewardID  string           `json:"rewardId"`
-	Costs     map[string]int64 `json:"costs"`
+	Costs     map[string]int64 `json:"costs"` // point type URI -> amount
 	CreatedAt int64            `json:"createdAt"`
+	UpdatedAt int64            `json:"updatedAt,omitempty"`
 	Status    RedemptionStatus `json:"status"`
+	Note      string           `json:"note,omitempty"` // fulfilment details or cancellation reason
+
+	Payments []RedemptionPayment `json:"-"`
+}
+
+// RedemptionPayment is the debit paying one cost of a redemption.
+type RedemptionPayment struct {
+	PointTypeID   int64
+	Amount        int64
+	TransactionID string
 }
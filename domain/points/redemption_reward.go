@@ -1,13 +1,88 @@
 package points
 
-// RedemptionReward represents a reward that users can redeem with points
+import "time"
+
+// RedemptionReward represents a reward that users can redeem with points. An
+// archived reward is disabled for good and hidden from the catalog.
 type RedemptionReward struct {
-	ID            string          `json:"id"`
-	Name          string          `json:"name"`
-	Description   string          `json:"description"`
-	Costs         map[int64]int64 `json:"costs"` // pointTypeID -> amount
-	Quantity      int             `json:"quantity"`
-	Enabled       bool            `json:"enabled"`
-	TotalRedeemed int             `json:"totalRedeemed"`
-	CreatedAt     int64           `json:"createdAt"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Costs         map[string]int64  `json:"costs"` // point type URI -> amount
+	Quantity      int               `json:"quantity"`
+	Enabled       bool              `json:"enabled"`
+	TotalRedeemed int               `json:"totalRedeemed"`
+	Limits        []RedemptionLimit `json:"limits,omitempty"`
+	ArchivedAt    *int64            `json:"archivedAt,omitempty"`
+	CreatedAt     int64             `json:"createdAt"`
+	UpdatedAt     int64             `json:"updatedAt,omitempty"`
+}
+
+// Validate checks the name and that every cost is positive.
+func (r RedemptionReward) Validate() error {
+	if r.Name == "" || len(r.Name) > 128 || r.Quantity < 0 {
+		return ErrInvalidReward
+	}
+	for uri, amount := range r.Costs {
+		if uri == "" || amount <= 0 {
+			return ErrInvalidReward
+		}
+	}
+	for i, l := range r.Limits {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+		for _, prev := range r.Limits[:i] {
+			if prev.Scope == l.Scope && prev.Period == l.Period {
+				return ErrInvalidReward
+			}
+		}
+	}
+	return nil
+}
+
+// Available reports whether users can redeem the reward now.
+func (r RedemptionReward) Available() bool {
+	return r.Enabled && r.ArchivedAt == nil && r.Quantity > 0
+}
+
+// LimitScope says whose redemptions a limit counts.
+type LimitScope string
+
+const (
+	LimitPerUser LimitScope = "user"
+	LimitGlobal  LimitScope = "global"
+)
+
+// RedemptionLimit caps how often a reward is redeemed, per user or by
+// everyone, within the daily, weekly or monthly UTC window of the ranking
+// periods, or ever when Period is empty. Cancelled redemptions do not count.
+type RedemptionLimit struct {
+	Scope  LimitScope    `json:"scope"`
+	Period RankingPeriod `json:"period,omitempty"`
+	Max    int           `json:"max"`
+}
+
+func (l RedemptionLimit) Validate() error {
+	if l.Scope != LimitPerUser && l.Scope != LimitGlobal || l.Max <= 0 {
+		return ErrInvalidReward
+	}
+	switch l.Period {
+	case PeriodAllTime, PeriodDaily, PeriodWeekly, PeriodMonthly:
+		return nil
+	}
+	return ErrInvalidReward
+}
+
+// Since returns the unix time the limit counts redemptions from at t, or 0
+// for a lifetime limit.
+func (l RedemptionLimit) Since(t time.Time) int64 {
+	if l.Period == PeriodAllTime {
+		return 0
+	}
+	start, _, err := WindowAt(l.Period, t).Bounds()
+	if err != nil {
+		return 0
+	}
+	return start.Unix()
 }
@@ -9,10 +9,39 @@ const (
 	DistributionFailed    RewardDistributionStatus = "failed"
 )
 
-// RewardDistribution represents a reward distribution execution record
+// RewardDistribution represents a reward distribution execution record. A
+// point type is distributed at most once per schedule and PeriodKey.
 type RewardDistribution struct {
-	ID         string                   `json:"id"`
-	SnapshotID string                   `json:"snapshotId"`
-	ExecutedAt int64                    `json:"executedAt"`
-	Status     RewardDistributionStatus `json:"status"`
+	ID          string                   `json:"id"`
+	PointTypeID int64                    `json:"pointTypeId"`
+	ScheduleID  int64                    `json:"scheduleId,omitempty"` // 0 when executed on request
+	PeriodKey   string                   `json:"periodKey,omitempty"`
+	SnapshotID  string                   `json:"snapshotId"`
+	ExecutedAt  int64                    `json:"executedAt"`
+	Status      RewardDistributionStatus `json:"status"`
+}
+
+// DistributionItemStatus is the payout state of one distribution recipient.
+type DistributionItemStatus string
+
+const (
+	DistributionItemPending DistributionItemStatus = "pending"
+	DistributionItemPaid    DistributionItemStatus = "paid"
+	DistributionItemFailed  DistributionItemStatus = "failed"
+)
+
+// RewardDistributionItem records the reward of one recipient of a
+// distribution. TransactionID links a paid item to its credit.
+type RewardDistributionItem struct {
+	ID                int64                  `json:"id"`
+	DistributionID    string                 `json:"distributionId"`
+	Position          int                    `json:"position"`
+	UserID            string                 `json:"userId"`
+	RuleID            string                 `json:"ruleId"`
+	RewardPointTypeID int64                  `json:"rewardPointTypeId"`
+	Amount            int64                  `json:"amount"`
+	Status            DistributionItemStatus `json:"status"`
+	TransactionID     string                 `json:"transactionId,omitempty"`
+	Error             string                 `json:"error,omitempty"`
+	UpdatedAt         int64                  `json:"updatedAt"`
 }
@@ -1,12 +1,134 @@
 package points
 
-// RewardRule defines rules for distributing rewards based on ranking
+// RewardRuleMode selects the recipients of a rule and how their reward is
+// computed.
+type RewardRuleMode string
+
+const (
+	// RuleFixed pays RewardAmount to each position from MinRank to MaxRank.
+	RuleFixed RewardRuleMode = "fixed"
+	// RulePoolShare splits ShareBps of the reward pool evenly among the
+	// positions from MinRank to MaxRank.
+	RulePoolShare RewardRuleMode = "pool_share"
+	// RulePercentile rewards the participants ranked within the band from
+	// PercentileFrom to PercentileTo, in basis points from the top.
+	RulePercentile RewardRuleMode = "percentile"
+	// RuleThreshold rewards every entry that scored at least MinScore.
+	RuleThreshold RewardRuleMode = "threshold"
+)
+
+// MaxShareBps is a whole pool or the whole of a ranking, in basis points.
+const MaxShareBps = 10000
+
+// RewardRule defines rules for distributing rewards based on ranking. A rule
+// applies from StartsAt until EndsAt; zero leaves that end open. Percentile
+// and threshold rules pay either RewardAmount each or a ShareBps slice of the
+// pool split evenly.
 type RewardRule struct {
-	ID                string `json:"id"`
-	PointTypeID       string `json:"pointTypeId"`
-	MinRank           int    `json:"minRank"`
-	MaxRank           int    `json:"maxRank"`
-	RewardAmount      int64  `json:"rewardAmount"`
-	RewardPointTypeID int64  `json:"rewardPointTypeId"`
-	Active            bool   `json:"active"`
+	ID                string         `json:"id"`
+	PointTypeID       int64          `json:"pointTypeId"`
+	Mode              RewardRuleMode `json:"mode"`
+	MinRank           int            `json:"minRank,omitempty"`
+	MaxRank           int            `json:"maxRank,omitempty"`
+	PercentileFrom    int            `json:"percentileFrom,omitempty"`
+	PercentileTo      int            `json:"percentileTo,omitempty"`
+	MinScore          int64          `json:"minScore,omitempty"`
+	RewardAmount      int64          `json:"rewardAmount,omitempty"`
+	ShareBps          int            `json:"shareBps,omitempty"`
+	RewardPointTypeID int64          `json:"rewardPointTypeId"`
+	Active            bool           `json:"active"`
+	StartsAt          int64          `json:"startsAt,omitempty"`
+	EndsAt            int64          `json:"endsAt,omitempty"`
+}
+
+// Validate checks the fields the mode uses and the validity window.
+func (r RewardRule) Validate() error {
+	if r.StartsAt < 0 || (r.EndsAt != 0 && r.EndsAt <= r.StartsAt) || r.RewardAmount < 0 || r.ShareBps < 0 || r.ShareBps > MaxShareBps {
+		return ErrInvalidRewardRule
+	}
+	// exactly one way to pay
+	if (r.RewardAmount > 0) == (r.ShareBps > 0) {
+		return ErrInvalidRewardRule
+	}
+	switch r.Mode {
+	case RuleFixed, RulePoolShare:
+		if r.MinRank < 1 || r.MaxRank < r.MinRank || (r.Mode == RuleFixed) != (r.RewardAmount > 0) {
+			return ErrInvalidRewardRule
+		}
+	case RulePercentile:
+		if r.PercentileFrom < 0 || r.PercentileTo <= r.PercentileFrom || r.PercentileTo > MaxShareBps {
+			return ErrInvalidRewardRule
+		}
+	case RuleThreshold:
+	default:
+		return ErrInvalidRewardRule
+	}
+	return nil
+}
+
+// ActiveAt reports whether the rule is active and valid at unix time t.
+func (r RewardRule) ActiveAt(t int64) bool {
+	return r.Active && t >= r.StartsAt && (r.EndsAt == 0 || t < r.EndsAt)
+}
+
+// Coincides reports whether both rules are active at some common time.
+func (r RewardRule) Coincides(o RewardRule) bool {
+	return r.Active && o.Active && (r.EndsAt == 0 || o.StartsAt < r.EndsAt) && (o.EndsAt == 0 || r.StartsAt < o.EndsAt)
+}
+
+// Precedence orders the kinds of rules: an entry is rewarded by rank rules
+// first, then by percentile rules, then by threshold rules.
+func (r RewardRule) Precedence() int {
+	switch r.Mode {
+	case RulePercentile:
+		return 1
+	case RuleThreshold:
+		return 2
+	}
+	return 0
+}
+
+// Overlaps reports whether both rules are of the same kind and could reward
+// the same entry at the same time. Threshold rules form tiers, so only equal
+// thresholds overlap.
+func (r RewardRule) Overlaps(o RewardRule) bool {
+	if !r.Coincides(o) || r.Precedence() != o.Precedence() {
+		return false
+	}
+	switch r.Mode {
+	case RulePercentile:
+		return r.PercentileFrom < o.PercentileTo && o.PercentileFrom < r.PercentileTo
+	case RuleThreshold:
+		return r.MinScore == o.MinScore
+	}
+	return r.MinRank <= o.MaxRank && o.MinRank <= r.MaxRank
+}
+
+// Matches reports whether an entry with the 1-based rank and score is a
+// recipient of the rule, out of participants ranked in total. Ranks follow
+// the tie policy, so tied entries share a rank and match the same rules.
+func (r RewardRule) Matches(rank, score, participants int64) bool {
+	switch r.Mode {
+	case RulePercentile:
+		return rank > ceilBps(participants, r.PercentileFrom) && rank <= ceilBps(participants, r.PercentileTo)
+	case RuleThreshold:
+		return score >= r.MinScore
+	}
+	return rank >= int64(r.MinRank) && rank <= int64(r.MaxRank)
+}
+
+// ceilBps is n*bps/10000 rounded up, so a band of the top includes at least
+// one participant whenever it has any width.
+func ceilBps(n int64, bps int) int64 {
+	return (n*int64(bps) + MaxShareBps - 1) / MaxShareBps
+}
+
+// RewardPool holds the amount of a reward point type that pool-share rules
+// of a ranked point type split. Rounding remainders and unclaimed shares stay
+// in the pool.
+type RewardPool struct {
+	PointTypeID       int64 `json:"pointTypeId"`
+	RewardPointTypeID int64 `json:"rewardPointTypeId"`
+	Balance           int64 `json:"balance"`
+	UpdatedAt         int64 `json:"updatedAt"`
 }
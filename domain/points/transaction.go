@@ -6,17 +6,40 @@ type TransactionType string
 const (
 	TransactionCredit TransactionType = "credit"
 	TransactionDebit  TransactionType = "debit"
+	// Two-phase debit: a hold reserves points, a capture debits them and a
+	// release returns them to the available balance.
+	TransactionHold    TransactionType = "hold"
+	TransactionCapture TransactionType = "capture"
+	TransactionRelease TransactionType = "release"
 )
 
 // Transaction represents a point transaction record
 type Transaction struct {
-	ID          string          `json:"id"`
-	UserID      string          `json:"userId"`
-	PointTypeID int64           `json:"pointTypeId"`
-	Amount      int64           `json:"amount"`
-	Type        TransactionType `json:"type"`
-	Reason      string          `json:"reason"`
-	Before      int64           `json:"before"`
-	After       int64           `json:"after"`
-	CreatedAt   int64           `json:"createdAt"`
+	ID             string          `json:"id"`
+	UserID         string          `json:"userId"`
+	PointTypeID    int64           `json:"pointTypeId"`
+	Amount         int64           `json:"amount"`
+	Type           TransactionType `json:"type"`
+	Reason         string          `json:"reason"`
+	Before         int64           `json:"before"`
+	After          int64           `json:"after"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"` // caller-supplied request identity, unique across the ledger
+	TransferID     string          `json:"transferId,omitempty"`     // shared by the debit/credit pair of a transfer
+	HoldID         string          `json:"holdId,omitempty"`         // hold that a hold/capture/release entry belongs to
+	ReversalOf     string          `json:"reversalOf,omitempty"`     // transaction compensated by this entry
+	ReversedBy     string          `json:"reversedBy,omitempty"`     // compensating entry that reversed this transaction
+	RedemptionID   string          `json:"redemptionId,omitempty"`   // redemption order a debit paid for or a refund returned
+	CreatedAt      int64           `json:"createdAt"`
+}
+
+// Reversible reports whether a compensating entry may be posted for t.
+func (t Transaction) Reversible() bool {
+	if t.ReversalOf != "" {
+		return false
+	}
+	switch t.Type {
+	case TransactionCredit, TransactionDebit, TransactionCapture:
+		return true
+	}
+	return false
 }
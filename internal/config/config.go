@@ -15,8 +15,22 @@ type Config struct {
 	JWTSecret    string
 	JWTIssuer    string
 	JWTTTL       string // duration string, e.g. "1h", "30m"
+	// Background jobs
+	ExpiryInterval        string // duration string between point expiry passes; "0" disables, empty leaves the default to the caller
+	DistributionInterval  string // duration string between checks for due distribution schedules; "0" disables
+	RankingRebuildOnStart bool   // rebuild missing Redis rankings from MySQL at startup
+	// Ranking storage: RankingBackendRedis, RankingBackendMemory or RankingBackendMySQL
+	RankingBackend string
 }
 
+const (
+	RankingBackendRedis = "redis"
+	// in-process rankings, rebuilt from MySQL at every start
+	RankingBackendMemory = "memory"
+	// rankings queried from MySQL; no Redis needed
+	RankingBackendMySQL = "mysql"
+)
+
 var (
 	loadedOnce sync.Once
 	cachedCfg  Config
@@ -36,6 +50,11 @@ func Load() Config {
 			JWTSecret:    getenv("JWT_SECRET", "dev-secret"),
 			JWTIssuer:    getenv("JWT_ISSUER", "acto-auth"),
 			JWTTTL:       getenv("JWT_TTL", "720h"),
+
+			ExpiryInterval:        os.Getenv("EXPIRY_INTERVAL"),
+			DistributionInterval:  getenv("DISTRIBUTION_INTERVAL", "1m"),
+			RankingRebuildOnStart: getenv("RANKING_REBUILD_ON_START", "false") == "true",
+			RankingBackend:        getenv("RANKING_BACKEND", RankingBackendRedis),
 		}
 	})
 	return cachedCfg
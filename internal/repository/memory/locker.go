@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	uc "github.com/usual2970/acto/points"
+)
+
+// Locker implements points.Locker within one process.
+type Locker struct {
+	mu   sync.Mutex
+	held map[string]time.Time // name -> expiry
+}
+
+func NewLocker() *Locker { return &Locker{held: map[string]time.Time{}} }
+
+var _ uc.Locker = (*Locker)(nil)
+
+func (l *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if exp, ok := l.held[name]; ok && exp.After(now) {
+		return nil, false, nil
+	}
+	exp := now.Add(ttl)
+	l.held[name] = exp
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// a lock that expired may belong to a later holder by now
+		if l.held[name] == exp {
+			delete(l.held, name)
+		}
+	}, true, nil
+}
@@ -0,0 +1,292 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+// boardPurgeInterval is how often expired windowed boards are deleted.
+const boardPurgeInterval = time.Minute
+
+// RankingRepository keeps rankings in process memory with the semantics of
+// the Redis repository: boards are sorted sets of integer scores, windowed
+// boards expire at their ExpireAt and rankings are lost on restart, to be
+// rebuilt from MySQL. It suits single-instance deployments and tests.
+type RankingRepository struct {
+	mu       sync.RWMutex
+	boards   map[boardKey]*board
+	purgedAt time.Time
+}
+
+func NewRankingRepository() *RankingRepository {
+	return &RankingRepository{boards: map[boardKey]*board{}}
+}
+
+var (
+	_ uc.RankingRepository    = (*RankingRepository)(nil)
+	_ uc.DistinctScoreCounter = (*RankingRepository)(nil)
+)
+
+type boardKey struct {
+	pointTypeID int64
+	window      d.RankingWindow
+}
+
+func allTime(pointTypeID int64) boardKey { return boardKey{pointTypeID: pointTypeID} }
+
+type board struct {
+	list   *skipList
+	scores map[string]int64
+	// distinct holds each score once, with its number of users in ties, so
+	// dense ranks are counted in O(log n)
+	distinct *skipList
+	ties     map[int64]int
+	expireAt int64 // unix seconds; 0 never expires
+}
+
+func newBoard() *board {
+	return &board{list: newSkipList(), scores: map[string]int64{}, distinct: newSkipList(), ties: map[int64]int{}}
+}
+
+func (b *board) set(userID string, score int64) {
+	if old, ok := b.scores[userID]; ok {
+		if old == score {
+			return
+		}
+		b.list.remove(userID, old)
+		b.untie(old)
+	}
+	b.scores[userID] = score
+	b.list.insert(userID, score)
+	if b.ties[score]++; b.ties[score] == 1 {
+		b.distinct.insert("", score)
+	}
+}
+
+func (b *board) remove(userID string) {
+	if old, ok := b.scores[userID]; ok {
+		b.list.remove(userID, old)
+		b.untie(old)
+		delete(b.scores, userID)
+	}
+}
+
+// untie drops one user from the users with score.
+func (b *board) untie(score int64) {
+	if b.ties[score]--; b.ties[score] == 0 {
+		delete(b.ties, score)
+		b.distinct.remove("", score)
+	}
+}
+
+// get returns a live board, or nil when it is missing or expired. Callers
+// hold at least the read lock.
+func (r *RankingRepository) get(k boardKey) *board {
+	b := r.boards[k]
+	if b == nil || (b.expireAt > 0 && b.expireAt <= time.Now().Unix()) {
+		return nil
+	}
+	return b
+}
+
+// writable returns the board for k, replacing an expired one. Callers hold
+// the write lock.
+func (r *RankingRepository) writable(k boardKey) *board {
+	b := r.get(k)
+	if b == nil {
+		b = newBoard()
+		r.boards[k] = b
+	}
+	return b
+}
+
+func (r *RankingRepository) UpdateUserScore(ctx context.Context, pointTypeID int64, userID string, score int64) error {
+	return r.UpdateUserScores(ctx, []uc.ScoreUpdate{{PointTypeID: pointTypeID, UserID: userID, Score: score}})
+}
+
+func (r *RankingRepository) UpdateUserScores(ctx context.Context, updates []uc.ScoreUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range updates {
+		r.writable(allTime(u.PointTypeID)).set(u.UserID, u.Score)
+	}
+	return nil
+}
+
+func (r *RankingRepository) IncrementUserScores(ctx context.Context, updates []uc.ScoreUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range updates {
+		b := r.writable(allTime(u.PointTypeID))
+		b.set(u.UserID, b.scores[u.UserID]+u.Score)
+	}
+	return nil
+}
+
+func (r *RankingRepository) IncrementWindowScores(ctx context.Context, incs []uc.WindowIncrement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, inc := range incs {
+		b := r.writable(boardKey{pointTypeID: inc.PointTypeID, window: inc.Window})
+		b.set(inc.UserID, b.scores[inc.UserID]+inc.Amount)
+		b.expireAt = inc.ExpireAt
+	}
+	r.purgeExpired(time.Now())
+	return nil
+}
+
+// purgeExpired deletes the expired boards at most once per
+// boardPurgeInterval: a past window is never written again, so get and
+// writable alone would keep it forever. Callers hold the write lock.
+func (r *RankingRepository) purgeExpired(now time.Time) {
+	if now.Sub(r.purgedAt) < boardPurgeInterval {
+		return
+	}
+	r.purgedAt = now
+	for k, b := range r.boards {
+		if b.expireAt > 0 && b.expireAt <= now.Unix() {
+			delete(r.boards, k)
+		}
+	}
+}
+
+func (r *RankingRepository) GetTop(ctx context.Context, pointTypeID int64, w d.RankingWindow, start, stop int64) ([]d.RankingEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b := r.get(boardKey{pointTypeID: pointTypeID, window: w})
+	if b == nil {
+		return []d.RankingEntry{}, nil
+	}
+	// negative indexes count from the end, as in ZREVRANGE
+	n := int64(b.list.length)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	res := []d.RankingEntry{}
+	for x := b.list.at(int(start)); x != nil && start <= stop; x, start = x.next[0].node, start+1 {
+		res = append(res, d.RankingEntry{UserID: x.member, Score: x.score})
+	}
+	return res, nil
+}
+
+func (r *RankingRepository) Count(ctx context.Context, pointTypeID int64, w d.RankingWindow) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if b := r.get(boardKey{pointTypeID: pointTypeID, window: w}); b != nil {
+		return int64(b.list.length), nil
+	}
+	return 0, nil
+}
+
+func (r *RankingRepository) CountAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if b := r.get(boardKey{pointTypeID: pointTypeID, window: w}); b != nil {
+		return int64(b.list.countAbove(score)), nil
+	}
+	return 0, nil
+}
+
+func (r *RankingRepository) CountDistinctAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if b := r.get(boardKey{pointTypeID: pointTypeID, window: w}); b != nil {
+		return int64(b.distinct.countAbove(score)), nil
+	}
+	return 0, nil
+}
+
+func (r *RankingRepository) GetUserPosition(ctx context.Context, pointTypeID int64, w d.RankingWindow, userID string) (int64, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b := r.get(boardKey{pointTypeID: pointTypeID, window: w})
+	if b == nil {
+		return 0, 0, d.ErrUserNotRanked
+	}
+	score, ok := b.scores[userID]
+	if !ok {
+		return 0, 0, d.ErrUserNotRanked
+	}
+	return int64(b.list.position(userID, score)), score, nil
+}
+
+func (r *RankingRepository) GetUserScores(ctx context.Context, pointTypeID int64, userIDs []string) (map[string]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make(map[string]int64, len(userIDs))
+	if b := r.get(allTime(pointTypeID)); b != nil {
+		for _, u := range userIDs {
+			if score, ok := b.scores[u]; ok {
+				res[u] = score
+			}
+		}
+	}
+	return res, nil
+}
+
+// ScanUserScores copies the scores before calling fn, so fn may write to the
+// repository.
+func (r *RankingRepository) ScanUserScores(ctx context.Context, pointTypeID int64, fn func(userID string, score int64) error) error {
+	r.mu.RLock()
+	var entries []d.RankingEntry
+	if b := r.get(allTime(pointTypeID)); b != nil {
+		entries = make([]d.RankingEntry, 0, len(b.scores))
+		for u, score := range b.scores {
+			entries = append(entries, d.RankingEntry{UserID: u, Score: score})
+		}
+	}
+	r.mu.RUnlock()
+	for _, e := range entries {
+		if err := fn(e.UserID, e.Score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RankingRepository) RemoveUsers(ctx context.Context, pointTypeID int64, userIDs ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b := r.get(allTime(pointTypeID)); b != nil {
+		for _, u := range userIDs {
+			b.remove(u)
+		}
+	}
+	return nil
+}
+
+// Rebuild fills a new board without holding the lock and swaps it in, so
+// readers switch from the old to the new ranking atomically.
+func (r *RankingRepository) Rebuild(ctx context.Context, pointTypeID int64, fill func(add func(userID string, score int64) error) error) (int64, error) {
+	b := newBoard()
+	err := fill(func(userID string, score int64) error {
+		b.set(userID, score)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(b.scores) == 0 {
+		delete(r.boards, allTime(pointTypeID))
+		return 0, nil
+	}
+	r.boards[allTime(pointTypeID)] = b
+	return int64(len(b.scores)), nil
+}
+
+func (r *RankingRepository) Exists(ctx context.Context, pointTypeID int64) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b := r.get(allTime(pointTypeID))
+	return b != nil && len(b.scores) > 0, nil
+}
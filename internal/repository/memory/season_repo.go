@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+// SeasonRepository implements points.SeasonRepository within one process.
+// Seasons are lost on restart.
+type SeasonRepository struct {
+	mu      sync.RWMutex
+	seasons map[string]d.RankingSeason
+}
+
+func NewSeasonRepository() *SeasonRepository {
+	return &SeasonRepository{seasons: map[string]d.RankingSeason{}}
+}
+
+var _ uc.SeasonRepository = (*SeasonRepository)(nil)
+
+func (r *SeasonRepository) CreateSeason(ctx context.Context, s d.RankingSeason) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seasons[s.Name]; ok {
+		return d.ErrInvalidSeason
+	}
+	r.seasons[s.Name] = s
+	return nil
+}
+
+func (r *SeasonRepository) GetSeason(ctx context.Context, name string) (*d.RankingSeason, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.seasons[name]
+	if !ok {
+		return nil, d.ErrSeasonNotFound
+	}
+	return &s, nil
+}
+
+func (r *SeasonRepository) ListSeasons(ctx context.Context) ([]d.RankingSeason, error) {
+	res := r.list(func(d.RankingSeason) bool { return true })
+	// newest first, as the MySQL repository lists them
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StartsAt > res[j].StartsAt })
+	return res, nil
+}
+
+func (r *SeasonRepository) ListUnfinishedSeasons(ctx context.Context, now int64) ([]d.RankingSeason, error) {
+	res := r.list(func(s d.RankingSeason) bool { return s.EndsAt > now })
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StartsAt < res[j].StartsAt })
+	return res, nil
+}
+
+func (r *SeasonRepository) list(keep func(d.RankingSeason) bool) []d.RankingSeason {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := []d.RankingSeason{}
+	for _, s := range r.seasons {
+		if keep(s) {
+			res = append(res, s)
+		}
+	}
+	// map order is random; break start-time ties by name
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
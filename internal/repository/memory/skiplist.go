@@ -0,0 +1,156 @@
+package memory
+
+import "math/rand/v2"
+
+const (
+	maxLevel = 32
+	// levelP is the chance of a node reaching the next level, as in Redis.
+	levelP = 0.25
+)
+
+// skipList orders members by descending score, and equal scores by
+// descending member, matching ZREVRANGE. Each link records how many nodes it
+// skips so positions are found in O(log n). It is not safe for concurrent
+// use; RankingRepository guards it.
+type skipList struct {
+	head   *slNode
+	level  int
+	length int
+}
+
+type slNode struct {
+	member string
+	score  int64
+	next   []slLink
+}
+
+type slLink struct {
+	node *slNode
+	span int
+}
+
+func newSkipList() *skipList {
+	return &skipList{head: &slNode{next: make([]slLink, maxLevel)}, level: 1}
+}
+
+// before reports whether n sorts ahead of (score, member).
+func (n *slNode) before(score int64, member string) bool {
+	return n.score > score || (n.score == score && n.member > member)
+}
+
+func randomLevel() int {
+	level := 1
+	for level < maxLevel && rand.Float64() < levelP {
+		level++
+	}
+	return level
+}
+
+// insert adds a member that is not in the list.
+func (l *skipList) insert(member string, score int64) {
+	var update [maxLevel]*slNode
+	var pos [maxLevel]int
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			pos[i] = pos[i+1]
+		}
+		for x.next[i].node != nil && x.next[i].node.before(score, member) {
+			pos[i] += x.next[i].span
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+	level := randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.head
+			update[i].next[i].span = l.length
+		}
+		l.level = level
+	}
+	n := &slNode{member: member, score: score, next: make([]slLink, level)}
+	for i := 0; i < level; i++ {
+		n.next[i].node = update[i].next[i].node
+		update[i].next[i].node = n
+		n.next[i].span = update[i].next[i].span - (pos[0] - pos[i])
+		update[i].next[i].span = pos[0] - pos[i] + 1
+	}
+	for i := level; i < l.level; i++ {
+		update[i].next[i].span++
+	}
+	l.length++
+}
+
+// remove deletes a member stored with score, if present.
+func (l *skipList) remove(member string, score int64) {
+	var update [maxLevel]*slNode
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && x.next[i].node.before(score, member) {
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+	n := x.next[0].node
+	if n == nil || n.member != member || n.score != score {
+		return
+	}
+	for i := 0; i < l.level; i++ {
+		if update[i].next[i].node == n {
+			update[i].next[i].span += n.next[i].span - 1
+			update[i].next[i].node = n.next[i].node
+		} else {
+			update[i].next[i].span--
+		}
+	}
+	for l.level > 1 && l.head.next[l.level-1].node == nil {
+		l.level--
+	}
+	l.length--
+}
+
+// position returns the 0-based position of a member stored with score.
+func (l *skipList) position(member string, score int64) int {
+	var pos int
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && x.next[i].node.before(score, member) {
+			pos += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+	return pos
+}
+
+// countAbove returns the number of members with a score strictly above score.
+func (l *skipList) countAbove(score int64) int {
+	var n int
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && x.next[i].node.score > score {
+			n += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+	return n
+}
+
+// at returns the node at a 0-based position, or nil past the end.
+func (l *skipList) at(pos int) *slNode {
+	if pos < 0 || pos >= l.length {
+		return nil
+	}
+	var traversed int
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && traversed+x.next[i].span <= pos+1 {
+			traversed += x.next[i].span
+			x = x.next[i].node
+		}
+		if traversed == pos+1 {
+			return x
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+type entry struct {
+	member string
+	score  int64
+}
+
+// checkList compares every lookup of l against want, which is in ranking
+// order: descending score, then descending member.
+func checkList(t *testing.T, l *skipList, want []entry) {
+	t.Helper()
+	if l.length != len(want) {
+		t.Fatalf("got length %d, want %d", l.length, len(want))
+	}
+	for i, e := range want {
+		n := l.at(i)
+		if n == nil || n.member != e.member || n.score != e.score {
+			t.Fatalf("at(%d) = %+v, want %+v", i, n, e)
+		}
+		if pos := l.position(e.member, e.score); pos != i {
+			t.Fatalf("position(%q) = %d, want %d", e.member, pos, i)
+		}
+		// members ahead with an equal score are not above
+		above := i
+		for above > 0 && want[above-1].score == e.score {
+			above--
+		}
+		if got := l.countAbove(e.score); got != above {
+			t.Fatalf("countAbove(%d) = %d, want %d", e.score, got, above)
+		}
+	}
+	if n := l.at(-1); n != nil {
+		t.Fatalf("at(-1) = %+v, want nil", n)
+	}
+	if n := l.at(len(want)); n != nil {
+		t.Fatalf("at(%d) = %+v, want nil", len(want), n)
+	}
+	if got := l.countAbove(-1 << 62); got != len(want) {
+		t.Fatalf("countAbove(min) = %d, want %d", got, len(want))
+	}
+	if got := l.countAbove(1 << 62); got != 0 {
+		t.Fatalf("countAbove(max) = %d, want 0", got)
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	tests := []struct {
+		name   string
+		insert []entry
+		remove []entry
+		want   []entry
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "distinct scores",
+			insert: []entry{{"a", 10}, {"b", 30}, {"c", 20}},
+			want:   []entry{{"b", 30}, {"c", 20}, {"a", 10}},
+		},
+		{
+			name:   "ties by descending member",
+			insert: []entry{{"a", 5}, {"c", 5}, {"b", 5}, {"d", 7}},
+			want:   []entry{{"d", 7}, {"c", 5}, {"b", 5}, {"a", 5}},
+		},
+		{
+			name:   "negative and zero scores",
+			insert: []entry{{"a", 0}, {"b", -3}, {"c", 2}, {"d", 0}},
+			want:   []entry{{"c", 2}, {"d", 0}, {"a", 0}, {"b", -3}},
+		},
+		{
+			name:   "remove within ties",
+			insert: []entry{{"a", 5}, {"b", 5}, {"c", 5}, {"d", 1}},
+			remove: []entry{{"b", 5}},
+			want:   []entry{{"c", 5}, {"a", 5}, {"d", 1}},
+		},
+		{
+			name:   "remove head and tail",
+			insert: []entry{{"a", 1}, {"b", 2}, {"c", 3}},
+			remove: []entry{{"c", 3}, {"a", 1}},
+			want:   []entry{{"b", 2}},
+		},
+		{
+			name:   "remove everything",
+			insert: []entry{{"a", 1}, {"b", 1}},
+			remove: []entry{{"a", 1}, {"b", 1}},
+		},
+		{
+			name:   "remove ignores a wrong score or missing member",
+			insert: []entry{{"a", 1}, {"b", 2}},
+			remove: []entry{{"a", 2}, {"z", 1}},
+			want:   []entry{{"b", 2}, {"a", 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newSkipList()
+			for _, e := range tt.insert {
+				l.insert(e.member, e.score)
+			}
+			for _, e := range tt.remove {
+				l.remove(e.member, e.score)
+			}
+			checkList(t, l, tt.want)
+		})
+	}
+}
+
+// TestSkipListRandom checks a list large enough to use several levels
+// against a sorted slice, after inserts and deletes with many ties.
+func TestSkipListRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	l := newSkipList()
+	scores := map[string]int64{}
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("u%04d", i)
+		scores[member] = r.Int64N(50)
+		l.insert(member, scores[member])
+	}
+	for member, score := range scores {
+		if r.IntN(3) == 0 {
+			l.remove(member, score)
+			delete(scores, member)
+		}
+	}
+	want := make([]entry, 0, len(scores))
+	for member, score := range scores {
+		want = append(want, entry{member, score})
+	}
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].score != want[j].score {
+			return want[i].score > want[j].score
+		}
+		return want[i].member > want[j].member
+	})
+	checkList(t, l, want)
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+// SnapshotRepository implements points.SnapshotRepository within one
+// process. Snapshots are lost on restart.
+type SnapshotRepository struct {
+	mu        sync.RWMutex
+	snapshots map[string]d.RankingSnapshot
+	entries   map[string][]d.RankingEntry // snapshot id -> entries in ranking order
+}
+
+func NewSnapshotRepository() *SnapshotRepository {
+	return &SnapshotRepository{snapshots: map[string]d.RankingSnapshot{}, entries: map[string][]d.RankingEntry{}}
+}
+
+var _ uc.SnapshotRepository = (*SnapshotRepository)(nil)
+
+func (r *SnapshotRepository) CreateSnapshot(ctx context.Context, s d.RankingSnapshot, entries []d.RankingEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.Entries = len(entries)
+	r.snapshots[s.ID] = s
+	r.entries[s.ID] = append([]d.RankingEntry(nil), entries...)
+	return nil
+}
+
+func (r *SnapshotRepository) GetSnapshot(ctx context.Context, id string) (*d.RankingSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.snapshots[id]
+	if !ok {
+		return nil, d.ErrSnapshotNotFound
+	}
+	return &s, nil
+}
+
+func (r *SnapshotRepository) ListSnapshots(ctx context.Context, pointTypeID int64, limit, offset int) ([]d.RankingSnapshot, int, error) {
+	r.mu.RLock()
+	res := []d.RankingSnapshot{}
+	for _, s := range r.snapshots {
+		if pointTypeID == 0 || s.PointTypeID == pointTypeID {
+			res = append(res, s)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt != res[j].CreatedAt {
+			return res[i].CreatedAt > res[j].CreatedAt
+		}
+		return res[i].ID < res[j].ID
+	})
+	return page(res, limit, offset), len(res), nil
+}
+
+func (r *SnapshotRepository) ListSnapshotEntries(ctx context.Context, snapshotID, userID string, limit, offset int) ([]d.RankingEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := []d.RankingEntry{}
+	for _, e := range r.entries[snapshotID] {
+		if userID == "" || e.UserID == userID {
+			res = append(res, e)
+		}
+	}
+	return page(res, limit, offset), nil
+}
+
+// page returns the items from offset on, at most limit of them.
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
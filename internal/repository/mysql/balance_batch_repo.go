@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+// placeholders returns n comma-separated copies of group, e.g. "(?,?),(?,?)".
+func placeholders(group string, n int) string {
+	return strings.TrimSuffix(strings.Repeat(group+",", n), ",")
+}
+
+func (r *BalanceTxRepository) GetUserBalancesForUpdate(ctx context.Context, keys []uc.BalanceKey) ([]d.UserBalance, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ex := getTx(ctx, r.db)
+	args := make([]any, 0, len(keys)*2)
+	for _, k := range keys {
+		args = append(args, k.UserID, k.PointTypeID)
+	}
+	// rows are locked in primary key order, so concurrent batches cannot deadlock on each other
+	rows, err := ex.QueryContext(ctx, `SELECT user_id, point_type_id, balance, held, updated_at FROM user_balances WHERE (user_id, point_type_id) IN (`+placeholders("(?,?)", len(keys))+`) ORDER BY user_id, point_type_id FOR UPDATE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []d.UserBalance
+	for rows.Next() {
+		var ub d.UserBalance
+		if err := rows.Scan(&ub.UserID, &ub.PointTypeID, &ub.Balance, &ub.Held, &ub.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, ub)
+	}
+	return res, rows.Err()
+}
+
+func (r *BalanceTxRepository) UpsertUserBalances(ctx context.Context, ubs []d.UserBalance) error {
+	if len(ubs) == 0 {
+		return nil
+	}
+	ex := getTx(ctx, r.db)
+	now := time.Now().Unix()
+	args := make([]any, 0, len(ubs)*5)
+	for _, ub := range ubs {
+		args = append(args, ub.UserID, ub.PointTypeID, ub.Balance, ub.Held, now)
+	}
+	_, err := ex.ExecContext(ctx, `INSERT INTO user_balances (user_id, point_type_id, balance, held, updated_at) VALUES `+placeholders("(?,?,?,?,?)", len(ubs))+` ON DUPLICATE KEY UPDATE balance=VALUES(balance), held=VALUES(held), updated_at=VALUES(updated_at)`, args...)
+	return err
+}
+
+// InsertTransactions writes txs with a single multi-row INSERT and returns
+// their IDs in order. InnoDB assigns consecutive auto-increment values to the
+// rows of one multi-row INSERT ... VALUES, starting at LastInsertId.
+func (r *BalanceTxRepository) InsertTransactions(ctx context.Context, txs []d.Transaction) ([]string, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	ex := getTx(ctx, r.db)
+	now := time.Now().Unix()
+	args := make([]any, 0, len(txs)*12)
+	for _, tx := range txs {
+		args = append(args, tx.UserID, tx.PointTypeID, tx.Amount, string(tx.Type), tx.Reason, tx.Before, tx.After, nullString(tx.IdempotencyKey), nullString(tx.TransferID), nullString(tx.HoldID), nullString(tx.ReversalOf), now)
+	}
+	res, err := ex.ExecContext(ctx, `INSERT INTO transactions (user_id,point_type_id,amount,type,reason,before_balance,after_balance,idempotency_key,transfer_id,hold_id,reversal_of,created_at) VALUES `+placeholders("(?,?,?,?,?,?,?,?,?,?,?,?)", len(txs)), args...)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, d.ErrIdempotencyKeyConflict
+		}
+		return nil, err
+	}
+	first, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(txs))
+	for i := range txs {
+		ids[i] = strconv.FormatInt(first+int64(i), 10)
+	}
+	return ids, nil
+}
+
+func (r *BalanceTxRepository) InsertLots(ctx context.Context, lots []d.BalanceLot) error {
+	if len(lots) == 0 {
+		return nil
+	}
+	ex := getTx(ctx, r.db)
+	args := make([]any, 0, len(lots)*7)
+	for _, l := range lots {
+		var expiresAt any
+		if l.ExpiresAt > 0 {
+			expiresAt = l.ExpiresAt
+		}
+		args = append(args, l.UserID, l.PointTypeID, l.TransactionID, l.Amount, l.Remaining, l.EarnedAt, expiresAt)
+	}
+	_, err := ex.ExecContext(ctx, `INSERT INTO balance_lots (user_id,point_type_id,transaction_id,amount,remaining,earned_at,expires_at) VALUES `+placeholders("(?,?,?,?,?,?,?)", len(lots)), args...)
+	return err
+}
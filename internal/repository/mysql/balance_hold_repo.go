@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+const holdColumns = `id,user_id,point_type_id,amount,COALESCE(reason,''),status,expires_at,created_at,updated_at`
+
+func scanHold(sc scanner) (d.BalanceHold, error) {
+	var h d.BalanceHold
+	var status string
+	err := sc.Scan(&h.ID, &h.UserID, &h.PointTypeID, &h.Amount, &h.Reason, &status, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt)
+	h.Status = d.BalanceHoldStatus(status)
+	return h, err
+}
+
+func (r *BalanceTxRepository) InsertHold(ctx context.Context, h d.BalanceHold) error {
+	ex := getTx(ctx, r.db)
+	now := time.Now().Unix()
+	_, err := ex.ExecContext(ctx, `INSERT INTO balance_holds (id,user_id,point_type_id,amount,reason,status,expires_at,created_at,updated_at) VALUES (?,?,?,?,?,?,?,?,?)`, h.ID, h.UserID, h.PointTypeID, h.Amount, h.Reason, string(h.Status), h.ExpiresAt, now, now)
+	return err
+}
+
+func (r *BalanceTxRepository) GetHoldForUpdate(ctx context.Context, holdID string) (*d.BalanceHold, error) {
+	ex := getTx(ctx, r.db)
+	h, err := scanHold(ex.QueryRowContext(ctx, `SELECT `+holdColumns+` FROM balance_holds WHERE id=? FOR UPDATE`, holdID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, d.ErrHoldNotFound
+		}
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (r *BalanceTxRepository) UpdateHoldStatus(ctx context.Context, holdID string, status d.BalanceHoldStatus) error {
+	ex := getTx(ctx, r.db)
+	_, err := ex.ExecContext(ctx, `UPDATE balance_holds SET status=?, updated_at=? WHERE id=?`, string(status), time.Now().Unix(), holdID)
+	return err
+}
+
+func (r *BalanceTxRepository) ListExpiredHolds(ctx context.Context, now int64, limit int) ([]d.BalanceHold, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+holdColumns+` FROM balance_holds WHERE status='active' AND expires_at<=? ORDER BY expires_at LIMIT ?`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []d.BalanceHold
+	for rows.Next() {
+		h, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, h)
+	}
+	return res, rows.Err()
+}
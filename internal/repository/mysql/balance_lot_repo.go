@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+const lotColumns = `id,user_id,point_type_id,transaction_id,amount,remaining,earned_at,COALESCE(expires_at,0)`
+
+func scanLot(sc scanner) (d.BalanceLot, error) {
+	var l d.BalanceLot
+	err := sc.Scan(&l.ID, &l.UserID, &l.PointTypeID, &l.TransactionID, &l.Amount, &l.Remaining, &l.EarnedAt, &l.ExpiresAt)
+	return l, err
+}
+
+func (r *BalanceTxRepository) InsertLot(ctx context.Context, lot d.BalanceLot) (string, error) {
+	ex := getTx(ctx, r.db)
+	var expiresAt sql.NullInt64
+	if lot.ExpiresAt > 0 {
+		expiresAt = sql.NullInt64{Int64: lot.ExpiresAt, Valid: true}
+	}
+	res, err := ex.ExecContext(ctx, `INSERT INTO balance_lots (user_id,point_type_id,transaction_id,amount,remaining,earned_at,expires_at) VALUES (?,?,?,?,?,?,?)`, lot.UserID, lot.PointTypeID, lot.TransactionID, lot.Amount, lot.Remaining, lot.EarnedAt, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (r *BalanceTxRepository) ListOpenLotsForUpdate(ctx context.Context, userID string, pointTypeID int64) ([]d.BalanceLot, error) {
+	ex := getTx(ctx, r.db)
+	rows, err := ex.QueryContext(ctx, `SELECT `+lotColumns+` FROM balance_lots WHERE user_id=? AND point_type_id=? AND remaining>0 ORDER BY earned_at, id FOR UPDATE`, userID, pointTypeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []d.BalanceLot
+	for rows.Next() {
+		l, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+func (r *BalanceTxRepository) GetLotForUpdate(ctx context.Context, lotID string) (*d.BalanceLot, error) {
+	ex := getTx(ctx, r.db)
+	l, err := scanLot(ex.QueryRowContext(ctx, `SELECT `+lotColumns+` FROM balance_lots WHERE id=? FOR UPDATE`, lotID))
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *BalanceTxRepository) UpdateLotRemaining(ctx context.Context, lotID string, remaining int64) error {
+	ex := getTx(ctx, r.db)
+	_, err := ex.ExecContext(ctx, `UPDATE balance_lots SET remaining=? WHERE id=?`, remaining, lotID)
+	return err
+}
+
+func (r *BalanceTxRepository) ListExpiredLots(ctx context.Context, now int64, limit int) ([]d.BalanceLot, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+lotColumns+` FROM balance_lots WHERE expires_at<=? AND remaining>0 ORDER BY expires_at, id LIMIT ?`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []d.BalanceLot
+	for rows.Next() {
+		l, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+func (r *BalanceTxRepository) SumExpiringLots(ctx context.Context, userID string, pointTypeID int64, before int64) (int64, error) {
+	var sum int64
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(remaining),0) FROM balance_lots WHERE user_id=? AND point_type_id=? AND remaining>0 AND expires_at<=?`, userID, pointTypeID, before).Scan(&sum)
+	return sum, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"time"
 
 	d "github.com/usual2970/acto/domain/points"
@@ -43,13 +44,42 @@ func getTx(ctx context.Context, db *sql.DB) executor {
 type executor interface {
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+const transactionColumns = `id,user_id,point_type_id,amount,type,COALESCE(reason,''),before_balance,after_balance,COALESCE(idempotency_key,''),COALESCE(transfer_id,''),COALESCE(hold_id,''),COALESCE(reversal_of,''),COALESCE(reversed_by,''),COALESCE(redemption_id,''),created_at`
+
+func scanTransaction(sc scanner) (d.Transaction, error) {
+	var t d.Transaction
+	var typ string
+	err := sc.Scan(&t.ID, &t.UserID, &t.PointTypeID, &t.Amount, &typ, &t.Reason, &t.Before, &t.After, &t.IdempotencyKey, &t.TransferID, &t.HoldID, &t.ReversalOf, &t.ReversedBy, &t.RedemptionID, &t.CreatedAt)
+	t.Type = d.TransactionType(typ)
+	return t, err
 }
 
 func (r *BalanceTxRepository) GetUserBalanceForUpdate(ctx context.Context, userID string, pointTypeID int64) (*d.UserBalance, error) {
 	ex := getTx(ctx, r.db)
-	row := ex.QueryRowContext(ctx, `SELECT user_id, point_type_id, balance, updated_at FROM user_balances WHERE user_id=? AND point_type_id=? FOR UPDATE`, userID, pointTypeID)
+	row := ex.QueryRowContext(ctx, `SELECT user_id, point_type_id, balance, held, updated_at FROM user_balances WHERE user_id=? AND point_type_id=? FOR UPDATE`, userID, pointTypeID)
 	var ub d.UserBalance
-	if err := row.Scan(&ub.UserID, &ub.PointTypeID, &ub.Balance, &ub.UpdatedAt); err != nil {
+	if err := row.Scan(&ub.UserID, &ub.PointTypeID, &ub.Balance, &ub.Held, &ub.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return &d.UserBalance{UserID: userID, PointTypeID: pointTypeID, Balance: 0}, nil
+		}
+		return nil, err
+	}
+	return &ub, nil
+}
+
+func (r *BalanceTxRepository) GetUserBalance(ctx context.Context, userID string, pointTypeID int64) (*d.UserBalance, error) {
+	ex := getTx(ctx, r.db)
+	row := ex.QueryRowContext(ctx, `SELECT user_id, point_type_id, balance, held, updated_at FROM user_balances WHERE user_id=? AND point_type_id=?`, userID, pointTypeID)
+	var ub d.UserBalance
+	if err := row.Scan(&ub.UserID, &ub.PointTypeID, &ub.Balance, &ub.Held, &ub.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return &d.UserBalance{UserID: userID, PointTypeID: pointTypeID, Balance: 0}, nil
 		}
@@ -60,29 +90,96 @@ func (r *BalanceTxRepository) GetUserBalanceForUpdate(ctx context.Context, userI
 
 func (r *BalanceTxRepository) UpsertUserBalance(ctx context.Context, ub d.UserBalance) error {
 	ex := getTx(ctx, r.db)
-	_, err := ex.ExecContext(ctx, `INSERT INTO user_balances (user_id, point_type_id, balance, updated_at) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE balance=VALUES(balance), updated_at=VALUES(updated_at)`, ub.UserID, ub.PointTypeID, ub.Balance, time.Now().Unix())
+	_, err := ex.ExecContext(ctx, `INSERT INTO user_balances (user_id, point_type_id, balance, held, updated_at) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE balance=VALUES(balance), held=VALUES(held), updated_at=VALUES(updated_at)`, ub.UserID, ub.PointTypeID, ub.Balance, ub.Held, time.Now().Unix())
 	return err
 }
 
 func (r *BalanceTxRepository) InsertTransaction(ctx context.Context, tx d.Transaction) (string, error) {
 	ex := getTx(ctx, r.db)
-	_, err := ex.ExecContext(ctx, `INSERT INTO transactions (user_id,point_type_id,amount,type,reason,before_balance,after_balance,created_at) VALUES (?,?,?,?,?,?,?,?)`, tx.UserID, tx.PointTypeID, tx.Amount, string(tx.Type), tx.Reason, tx.Before, tx.After, time.Now().Unix())
+	res, err := ex.ExecContext(ctx, `INSERT INTO transactions (user_id,point_type_id,amount,type,reason,before_balance,after_balance,idempotency_key,transfer_id,hold_id,reversal_of,redemption_id,created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?)`, tx.UserID, tx.PointTypeID, tx.Amount, string(tx.Type), tx.Reason, tx.Before, tx.After, nullString(tx.IdempotencyKey), nullString(tx.TransferID), nullString(tx.HoldID), nullString(tx.ReversalOf), nullString(tx.RedemptionID), time.Now().Unix())
 	if err != nil {
+		if isDuplicateKey(err) && tx.ReversalOf != "" {
+			return "", d.ErrTransactionReversed
+		}
+		if isDuplicateKey(err) && tx.IdempotencyKey != "" {
+			return "", d.ErrIdempotencyKeyConflict
+		}
 		return "", err
 	}
-	var id string
-	_ = r.db.QueryRowContext(ctx, `SELECT id FROM transactions WHERE user_id=? ORDER BY created_at DESC LIMIT 1`, tx.UserID).Scan(&id)
-	return id, nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
 }
 
-func (r *BalanceTxRepository) ListTransactions(ctx context.Context, userID string, filter uc.TransactionFilter) ([]d.Transaction, int, error) {
-	where := "WHERE user_id=?"
-	args := []any{userID}
+// GetTransactionByIdempotencyKey returns the transaction recorded under key,
+// or nil when the key has not been used. Callers inside WithTx should lock the
+// user balance row first so the lookup observes any concurrent duplicate that
+// committed while they waited; the unique index on idempotency_key backs this up.
+func (r *BalanceTxRepository) GetTransactionByIdempotencyKey(ctx context.Context, key string) (*d.Transaction, error) {
+	ex := getTx(ctx, r.db)
+	row := ex.QueryRowContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE idempotency_key=?`, key)
+	t, err := scanTransaction(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTransaction returns a transaction by ID. With lock set the row is read
+// FOR UPDATE, which only has an effect inside WithTx.
+func (r *BalanceTxRepository) GetTransaction(ctx context.Context, transactionID string, lock bool) (*d.Transaction, error) {
+	ex := getTx(ctx, r.db)
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id=?`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	t, err := scanTransaction(ex.QueryRowContext(ctx, query, transactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, d.ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// MarkTransactionReversed links a transaction to its compensating entry. It
+// fails with ErrTransactionReversed if the transaction was already reversed.
+func (r *BalanceTxRepository) MarkTransactionReversed(ctx context.Context, transactionID, reversalID string) error {
+	ex := getTx(ctx, r.db)
+	res, err := ex.ExecContext(ctx, `UPDATE transactions SET reversed_by=? WHERE id=? AND reversed_by IS NULL`, reversalID, transactionID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return d.ErrTransactionReversed
+	}
+	return nil
+}
+
+// transactionWhere builds the WHERE clause for filter. An empty userID
+// matches every user.
+func transactionWhere(userID string, filter uc.TransactionFilter) (string, []any) {
+	where := "WHERE 1=1"
+	var args []any
+	if userID != "" {
+		where += " AND user_id=?"
+		args = append(args, userID)
+	}
 	if filter.PointTypeID != 0 {
 		where += " AND point_type_id=?"
 		args = append(args, filter.PointTypeID)
 	}
-	if filter.OperationType == "credit" || filter.OperationType == "debit" {
+	if filter.OperationType != "" {
 		where += " AND type=?"
 		args = append(args, filter.OperationType)
 	}
@@ -94,6 +191,11 @@ func (r *BalanceTxRepository) ListTransactions(ctx context.Context, userID strin
 		where += " AND created_at<?"
 		args = append(args, filter.EndTime)
 	}
+	return where, args
+}
+
+func (r *BalanceTxRepository) ListTransactions(ctx context.Context, userID string, filter uc.TransactionFilter) ([]d.Transaction, int, error) {
+	where, args := transactionWhere(userID, filter)
 
 	// total count
 	var total int
@@ -106,24 +208,148 @@ func (r *BalanceTxRepository) ListTransactions(ctx context.Context, userID strin
 		filter.Limit = 20
 	}
 
-	rows, err := r.db.QueryContext(ctx, fmt.Sprintf("SELECT id,user_id,point_type_id,amount,type,reason,before_balance,after_balance,created_at FROM transactions %s ORDER BY created_at DESC LIMIT ? OFFSET ?", where), append(args, filter.Limit, filter.Offset)...)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM transactions %s ORDER BY created_at DESC LIMIT ? OFFSET ?", transactionColumns, where), append(args, filter.Limit, filter.Offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 	var res []d.Transaction
 	for rows.Next() {
-		var t d.Transaction
-		var typ string
-		if err := rows.Scan(&t.ID, &t.UserID, &t.PointTypeID, &t.Amount, &typ, &t.Reason, &t.Before, &t.After, &t.CreatedAt); err != nil {
+		t, err := scanTransaction(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		if typ == "credit" {
-			t.Type = d.TransactionCredit
-		} else {
-			t.Type = d.TransactionDebit
-		}
 		res = append(res, t)
 	}
 	return res, total, rows.Err()
 }
+
+// StreamTransactions calls fn for every transaction matching the filter, in ID
+// order, reading from a single cursor. Limit and Offset are ignored.
+func (r *BalanceTxRepository) StreamTransactions(ctx context.Context, userID string, filter uc.TransactionFilter, fn func(d.Transaction) error) error {
+	where, args := transactionWhere(userID, filter)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM transactions %s ORDER BY id", transactionColumns, where), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamUserBalances calls fn for every balance row, optionally limited to one
+// point type, reading from a single cursor.
+func (r *BalanceTxRepository) StreamUserBalances(ctx context.Context, pointTypeID int64, fn func(d.UserBalance) error) error {
+	query := `SELECT user_id, point_type_id, balance, held, updated_at FROM user_balances`
+	var args []any
+	if pointTypeID != 0 {
+		query += ` WHERE point_type_id=?`
+		args = append(args, pointTypeID)
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY point_type_id, user_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ub d.UserBalance
+		if err := rows.Scan(&ub.UserID, &ub.PointTypeID, &ub.Balance, &ub.Held, &ub.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(ub); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// rankingScoreExprs replays the ledger under the incremental ranking metrics.
+// t is the entry and o the entry it reverses, if any; a reversal undoes
+// whatever its original contributed.
+var rankingScoreExprs = map[d.RankingMetric]string{
+	d.MetricLifetimeCredits: `CASE WHEN t.reversal_of IS NULL THEN IF(t.type='credit' AND t.transfer_id IS NULL, t.amount, 0)
+		WHEN o.type='credit' AND o.transfer_id IS NULL THEN -t.amount ELSE 0 END`,
+	d.MetricNetEarned: `CASE WHEN t.redemption_id IS NOT NULL AND t.type='debit' AND t.reversal_of IS NULL THEN 0
+		WHEN o.redemption_id IS NOT NULL AND o.type='debit' AND o.reversal_of IS NULL THEN 0
+		ELSE CASE t.type WHEN 'credit' THEN t.amount WHEN 'debit' THEN -t.amount WHEN 'capture' THEN -t.amount ELSE 0 END END`,
+}
+
+// rankingScoresSQL returns the per-user score query of a metric and its
+// leading arguments. Under the balance metric every balance row is a score;
+// otherwise users whose entries sum to zero are left out.
+func rankingScoresSQL(metric d.RankingMetric, pointTypeID int64, where string) (string, []any, error) {
+	if metric == "" || metric == d.MetricBalance {
+		return `SELECT user_id, balance FROM user_balances WHERE point_type_id=?` + where, []any{pointTypeID}, nil
+	}
+	expr, ok := rankingScoreExprs[metric]
+	if !ok {
+		return "", nil, d.ErrInvalidRankingMetric
+	}
+	query := `SELECT t.user_id, CAST(SUM(` + expr + `) AS SIGNED) AS score
+		FROM transactions t LEFT JOIN transactions o ON o.id=t.reversal_of
+		WHERE t.point_type_id=?` + where + ` GROUP BY t.user_id HAVING score<>0`
+	return query, []any{pointTypeID}, nil
+}
+
+func (r *BalanceTxRepository) StreamRankingScores(ctx context.Context, pointTypeID int64, metric d.RankingMetric, fn func(userID string, score int64) error) error {
+	query, args, err := rankingScoresSQL(metric, pointTypeID, "")
+	if err != nil {
+		return err
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var score int64
+		if err := rows.Scan(&userID, &score); err != nil {
+			return err
+		}
+		if err := fn(userID, score); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *BalanceTxRepository) GetRankingScores(ctx context.Context, pointTypeID int64, metric d.RankingMetric, userIDs []string) (map[string]int64, error) {
+	res := make(map[string]int64, len(userIDs))
+	if len(userIDs) == 0 {
+		return res, nil
+	}
+	col := "t.user_id"
+	if metric == "" || metric == d.MetricBalance {
+		col = "user_id"
+	}
+	query, args, err := rankingScoresSQL(metric, pointTypeID, ` AND `+col+` IN (`+placeholders("?", len(userIDs))+`)`)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range userIDs {
+		args = append(args, u)
+	}
+	rows, err := getTx(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var score int64
+		if err := rows.Scan(&userID, &score); err != nil {
+			return nil, err
+		}
+		res[userID] = score
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+type DistributionScheduleRepository struct{ db *sql.DB }
+
+func NewDistributionScheduleRepository(db *sql.DB) *DistributionScheduleRepository {
+	return &DistributionScheduleRepository{db: db}
+}
+
+var _ uc.DistributionScheduleRepository = (*DistributionScheduleRepository)(nil)
+
+const scheduleColumns = `id,point_type_id,COALESCE(period,''),COALESCE(cron,''),top_n,timezone,COALESCE(ranking_window,''),enabled,next_run_at,COALESCE(last_period_key,''),COALESCE(last_run_at,0),COALESCE(last_error,''),created_at`
+
+func scanSchedule(sc scanner) (d.DistributionSchedule, error) {
+	var s d.DistributionSchedule
+	err := sc.Scan(&s.ID, &s.PointTypeID, &s.Period, &s.Cron, &s.TopN, &s.Timezone, &s.Window, &s.Enabled, &s.NextRunAt, &s.LastPeriodKey, &s.LastRunAt, &s.LastError, &s.CreatedAt)
+	return s, err
+}
+
+func (r *DistributionScheduleRepository) CreateSchedule(ctx context.Context, s d.DistributionSchedule) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO distribution_schedules (point_type_id,period,cron,top_n,timezone,ranking_window,enabled,next_run_at,created_at) VALUES (?,?,?,?,?,?,?,?,?)`,
+		s.PointTypeID, nullString(string(s.Period)), nullString(s.Cron), s.TopN, s.Timezone, nullString(string(s.Window)), s.Enabled, s.NextRunAt, s.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *DistributionScheduleRepository) GetSchedule(ctx context.Context, id int64) (*d.DistributionSchedule, error) {
+	s, err := scanSchedule(r.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM distribution_schedules WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, d.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *DistributionScheduleRepository) ListSchedules(ctx context.Context) ([]d.DistributionSchedule, error) {
+	return r.list(ctx, `SELECT `+scheduleColumns+` FROM distribution_schedules ORDER BY id`)
+}
+
+func (r *DistributionScheduleRepository) ListDueSchedules(ctx context.Context, now int64) ([]d.DistributionSchedule, error) {
+	return r.list(ctx, `SELECT `+scheduleColumns+` FROM distribution_schedules WHERE enabled=1 AND next_run_at<=? ORDER BY next_run_at`, now)
+}
+
+func (r *DistributionScheduleRepository) list(ctx context.Context, query string, args ...any) ([]d.DistributionSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []d.DistributionSchedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// UpdateSchedule stores the enabled flag and run state of a schedule.
+func (r *DistributionScheduleRepository) UpdateSchedule(ctx context.Context, s d.DistributionSchedule) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE distribution_schedules SET enabled=?, next_run_at=?, last_period_key=?, last_run_at=?, last_error=? WHERE id=?`,
+		s.Enabled, s.NextRunAt, nullString(s.LastPeriodKey), sql.NullInt64{Int64: s.LastRunAt, Valid: s.LastRunAt != 0}, nullString(s.LastError), s.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := r.GetSchedule(ctx, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DistributionScheduleRepository) DeleteSchedule(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM distribution_schedules WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return d.ErrScheduleNotFound
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+// erDupEntry is the MySQL error number for unique key violations.
+const erDupEntry = 1062
+
+func isDuplicateKey(err error) bool {
+	var me *mysqlDriver.MySQLError
+	return errors.As(err, &me) && me.Number == erDupEntry
+}
+
+// nullString maps an empty string to SQL NULL so optional unique columns
+// do not collide on the empty value.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
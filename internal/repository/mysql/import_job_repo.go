@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+type ImportJobRepository struct{ db *sql.DB }
+
+func NewImportJobRepository(db *sql.DB) *ImportJobRepository { return &ImportJobRepository{db: db} }
+
+var _ uc.ImportJobRepository = (*ImportJobRepository)(nil)
+
+func (r *ImportJobRepository) CreateImportJob(ctx context.Context, job d.ImportJob) error {
+	now := time.Now().Unix()
+	_, err := r.db.ExecContext(ctx, `INSERT INTO import_jobs (id,format,file_hash,status,processed_rows,succeeded_rows,failed_rows,error,created_at,updated_at) VALUES (?,?,?,?,0,0,0,'',?,?)`, job.ID, string(job.Format), job.FileHash, string(job.Status), now, now)
+	return err
+}
+
+func (r *ImportJobRepository) UpdateImportJob(ctx context.Context, job d.ImportJob) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE import_jobs SET status=?, processed_rows=?, succeeded_rows=?, failed_rows=?, error=?, updated_at=? WHERE id=?`, string(job.Status), job.ProcessedRows, job.SucceededRows, job.FailedRows, job.Error, time.Now().Unix(), job.ID)
+	return err
+}
+
+func (r *ImportJobRepository) TouchImportJob(ctx context.Context, jobID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE import_jobs SET updated_at=? WHERE id=?`, time.Now().Unix(), jobID)
+	return err
+}
+
+func (r *ImportJobRepository) FailStaleImportJobs(ctx context.Context, before int64, reason string) (int, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE import_jobs SET status='failed', error=?, updated_at=? WHERE status IN ('pending','running') AND updated_at<?`, reason, time.Now().Unix(), before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *ImportJobRepository) GetImportJob(ctx context.Context, jobID string) (*d.ImportJob, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT id,format,file_hash,status,processed_rows,succeeded_rows,failed_rows,error,created_at,updated_at FROM import_jobs WHERE id=?`, jobID)
+	var job d.ImportJob
+	var format, status string
+	if err := row.Scan(&job.ID, &format, &job.FileHash, &status, &job.ProcessedRows, &job.SucceededRows, &job.FailedRows, &job.Error, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, d.ErrImportJobNotFound
+		}
+		return nil, err
+	}
+	job.Format = d.ImportFormat(format)
+	job.Status = d.ImportJobStatus(status)
+	return &job, nil
+}
+
+func (r *ImportJobRepository) InsertImportRowErrors(ctx context.Context, rows []d.ImportRowError) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(rows)*4)
+	for _, e := range rows {
+		args = append(args, e.JobID, e.Row, e.Data, e.Error)
+	}
+	_, err := r.db.ExecContext(ctx, `INSERT INTO import_job_errors (job_id,row_no,data,error) VALUES `+placeholders("(?,?,?,?)", len(rows)), args...)
+	return err
+}
+
+func (r *ImportJobRepository) ListImportRowErrors(ctx context.Context, jobID string, limit, offset int) ([]d.ImportRowError, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM import_job_errors WHERE job_id=?`, jobID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT job_id,row_no,data,error FROM import_job_errors WHERE job_id=? ORDER BY row_no LIMIT ? OFFSET ?`, jobID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []d.ImportRowError
+	for rows.Next() {
+		var e d.ImportRowError
+		if err := rows.Scan(&e.JobID, &e.Row, &e.Data, &e.Error); err != nil {
+			return nil, 0, err
+		}
+		res = append(res, e)
+	}
+	return res, total, rows.Err()
+}
@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	uc "github.com/usual2970/acto/points"
+)
+
+// Locker implements points.Locker with GET_LOCK. The lock belongs to a
+// dedicated connection and is released with it, so a crashed holder's lock
+// goes away with its session; ttl is not used.
+type Locker struct{ db *sql.DB }
+
+func NewLocker(db *sql.DB) *Locker { return &Locker{db: db} }
+
+var _ uc.Locker = (*Locker)(nil)
+
+// maxLockName is the longest name GET_LOCK accepts.
+const maxLockName = 64
+
+func (l *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	if len(name) > maxLockName {
+		name = name[:maxLockName]
+	}
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 0)`, name).Scan(&got); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if got.Int64 != 1 {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `DO RELEASE_LOCK(?)`, name)
+		_ = conn.Close()
+	}, true, nil
+}
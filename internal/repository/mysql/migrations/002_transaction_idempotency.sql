@@ -0,0 +1,6 @@
+-- ----------------------------
+-- Idempotency keys for balance credit/debit
+-- ----------------------------
+ALTER TABLE `transactions`
+  ADD COLUMN `idempotency_key` varchar(128) DEFAULT NULL AFTER `after_balance`,
+  ADD UNIQUE KEY `uk_idempotency_key` (`idempotency_key`);
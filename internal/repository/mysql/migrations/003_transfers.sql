@@ -0,0 +1,9 @@
+-- ----------------------------
+-- Point-to-point transfers
+-- ----------------------------
+ALTER TABLE `point_types`
+  ADD COLUMN `transfer_enabled` tinyint(1) NOT NULL DEFAULT '0' AFTER `enabled`;
+
+ALTER TABLE `transactions`
+  ADD COLUMN `transfer_id` char(32) DEFAULT NULL AFTER `idempotency_key`,
+  ADD KEY `idx_transfer` (`transfer_id`);
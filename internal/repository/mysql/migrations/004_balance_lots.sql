@@ -0,0 +1,21 @@
+-- ----------------------------
+-- Points expiration with FIFO lot tracking
+-- ----------------------------
+ALTER TABLE `point_types`
+  ADD COLUMN `expiry_policy` enum('none','ttl','end_of_month','end_of_year') NOT NULL DEFAULT 'none' AFTER `transfer_enabled`,
+  ADD COLUMN `expiry_days` int NOT NULL DEFAULT '0' AFTER `expiry_policy`;
+
+DROP TABLE IF EXISTS `balance_lots`;
+CREATE TABLE `balance_lots` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `user_id` varchar(128) NOT NULL,
+  `point_type_id` bigint NOT NULL,
+  `transaction_id` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `remaining` bigint NOT NULL,
+  `earned_at` bigint NOT NULL,
+  `expires_at` bigint DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_user_open` (`user_id`,`point_type_id`,`remaining`),
+  KEY `idx_expiry` (`expires_at`,`remaining`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
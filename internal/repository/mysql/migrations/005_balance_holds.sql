@@ -0,0 +1,26 @@
+-- ----------------------------
+-- Balance holds (two-phase debit)
+-- ----------------------------
+ALTER TABLE `user_balances`
+  ADD COLUMN `held` bigint NOT NULL DEFAULT '0' AFTER `balance`;
+
+ALTER TABLE `transactions`
+  MODIFY COLUMN `type` enum('credit','debit','hold','capture','release') NOT NULL,
+  ADD COLUMN `hold_id` char(32) DEFAULT NULL AFTER `transfer_id`,
+  ADD KEY `idx_hold` (`hold_id`);
+
+DROP TABLE IF EXISTS `balance_holds`;
+CREATE TABLE `balance_holds` (
+  `id` char(32) NOT NULL,
+  `user_id` varchar(128) NOT NULL,
+  `point_type_id` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `reason` varchar(255) DEFAULT NULL,
+  `status` enum('active','captured','released','expired') NOT NULL DEFAULT 'active',
+  `expires_at` bigint NOT NULL,
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_user` (`user_id`,`point_type_id`),
+  KEY `idx_status_expiry` (`status`,`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
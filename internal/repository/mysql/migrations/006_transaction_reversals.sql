@@ -0,0 +1,7 @@
+-- ----------------------------
+-- Transaction reversals
+-- ----------------------------
+ALTER TABLE `transactions`
+  ADD COLUMN `reversal_of` bigint DEFAULT NULL AFTER `hold_id`,
+  ADD COLUMN `reversed_by` bigint DEFAULT NULL AFTER `reversal_of`,
+  ADD UNIQUE KEY `uk_reversal_of` (`reversal_of`);
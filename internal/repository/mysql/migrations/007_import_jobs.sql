@@ -0,0 +1,27 @@
+-- ----------------------------
+-- Asynchronous balance imports
+-- ----------------------------
+DROP TABLE IF EXISTS `import_jobs`;
+CREATE TABLE `import_jobs` (
+  `id` char(32) NOT NULL,
+  `format` enum('csv','jsonl') NOT NULL,
+  `file_hash` char(64) NOT NULL,
+  `status` enum('pending','running','completed','failed') NOT NULL DEFAULT 'pending',
+  `processed_rows` bigint NOT NULL DEFAULT '0',
+  `succeeded_rows` bigint NOT NULL DEFAULT '0',
+  `failed_rows` bigint NOT NULL DEFAULT '0',
+  `error` varchar(1024) NOT NULL DEFAULT '',
+  `created_at` bigint NOT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_file_hash` (`file_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+DROP TABLE IF EXISTS `import_job_errors`;
+CREATE TABLE `import_job_errors` (
+  `job_id` char(32) NOT NULL,
+  `row_no` bigint NOT NULL,
+  `data` text,
+  `error` varchar(1024) NOT NULL,
+  PRIMARY KEY (`job_id`,`row_no`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
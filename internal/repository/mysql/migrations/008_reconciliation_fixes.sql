@@ -0,0 +1,17 @@
+-- ----------------------------
+-- Changes made by ledger reconciliation in fix mode
+-- ----------------------------
+DROP TABLE IF EXISTS `reconciliation_fixes`;
+CREATE TABLE `reconciliation_fixes` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `run_id` char(32) NOT NULL,
+  `kind` enum('balance','ranking','ranking_orphan') NOT NULL,
+  `user_id` varchar(128) NOT NULL,
+  `point_type_id` bigint NOT NULL,
+  `old_value` bigint DEFAULT NULL,
+  `new_value` bigint DEFAULT NULL,
+  `created_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_run_id` (`run_id`),
+  KEY `idx_user_point_type` (`user_id`,`point_type_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
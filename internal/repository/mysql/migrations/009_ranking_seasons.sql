@@ -0,0 +1,12 @@
+-- ----------------------------
+-- Named windows of seasonal rankings
+-- ----------------------------
+DROP TABLE IF EXISTS `ranking_seasons`;
+CREATE TABLE `ranking_seasons` (
+  `name` varchar(64) NOT NULL,
+  `starts_at` bigint NOT NULL,
+  `ends_at` bigint NOT NULL,
+  `created_at` bigint NOT NULL,
+  PRIMARY KEY (`name`),
+  KEY `idx_ends_at` (`ends_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,5 @@
+-- ----------------------------
+-- Per point type ranking metric; rebuild the rankings after changing it
+-- ----------------------------
+ALTER TABLE `point_types`
+  ADD COLUMN `ranking_metric` enum('balance','lifetime_credits','net_earned') NOT NULL DEFAULT 'balance' AFTER `expiry_days`;
@@ -0,0 +1,18 @@
+-- ----------------------------
+-- Rankings of the MySQL ranking backend. All-time rankings of point types
+-- ranked by balance are read from user_balances instead.
+-- ----------------------------
+DROP TABLE IF EXISTS `ranking_scores`;
+CREATE TABLE `ranking_scores` (
+  `point_type_id` bigint NOT NULL,
+  `board` varchar(80) NOT NULL DEFAULT '',
+  `user_id` varchar(128) NOT NULL,
+  `score` bigint NOT NULL,
+  `expire_at` bigint DEFAULT NULL,
+  PRIMARY KEY (`point_type_id`,`board`,`user_id`),
+  KEY `idx_board_score` (`point_type_id`,`board`,`score`),
+  KEY `idx_expire_at` (`expire_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+ALTER TABLE `user_balances`
+  ADD KEY `idx_point_type_balance` (`point_type_id`,`balance`);
@@ -0,0 +1,29 @@
+-- ----------------------------
+-- Scheduled reward distributions
+-- ----------------------------
+DROP TABLE IF EXISTS `distribution_schedules`;
+CREATE TABLE `distribution_schedules` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `point_type_id` bigint NOT NULL,
+  `period` enum('daily','weekly','monthly') DEFAULT NULL,
+  `cron` varchar(128) DEFAULT NULL,
+  `top_n` int NOT NULL,
+  `timezone` varchar(64) NOT NULL DEFAULT 'UTC',
+  `ranking_window` enum('daily','weekly','monthly') DEFAULT NULL,
+  `enabled` tinyint(1) NOT NULL DEFAULT '1',
+  `next_run_at` bigint NOT NULL,
+  `last_period_key` varchar(32) DEFAULT NULL,
+  `last_run_at` bigint DEFAULT NULL,
+  `last_error` varchar(1024) DEFAULT NULL,
+  `created_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_due` (`enabled`,`next_run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- A distribution runs at most once per point type, schedule and period;
+-- schedule_id is 0 for distributions executed on request.
+ALTER TABLE `reward_distributions`
+  ADD COLUMN `point_type_id` bigint NOT NULL DEFAULT '0' AFTER `id`,
+  ADD COLUMN `schedule_id` bigint NOT NULL DEFAULT '0' AFTER `point_type_id`,
+  ADD COLUMN `period_key` varchar(32) DEFAULT NULL AFTER `schedule_id`,
+  ADD UNIQUE KEY `uk_period` (`point_type_id`,`schedule_id`,`period_key`);
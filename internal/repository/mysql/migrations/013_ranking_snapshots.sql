@@ -0,0 +1,27 @@
+-- ----------------------------
+-- Frozen ranking tops used by distributions
+-- ----------------------------
+DROP TABLE IF EXISTS `ranking_snapshots`;
+CREATE TABLE `ranking_snapshots` (
+  `id` char(32) NOT NULL,
+  `point_type_id` bigint NOT NULL,
+  `period` varchar(16) NOT NULL DEFAULT '',
+  `window_key` varchar(64) NOT NULL DEFAULT '',
+  `tie_policy` enum('standard','dense') NOT NULL DEFAULT 'standard',
+  `top_n` int NOT NULL,
+  `entries` int NOT NULL,
+  `created_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_point_type_created` (`point_type_id`,`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+DROP TABLE IF EXISTS `ranking_snapshot_entries`;
+CREATE TABLE `ranking_snapshot_entries` (
+  `snapshot_id` char(32) NOT NULL,
+  `position` int NOT NULL,
+  `rank_no` bigint NOT NULL,
+  `user_id` varchar(128) NOT NULL,
+  `score` bigint NOT NULL,
+  PRIMARY KEY (`snapshot_id`,`position`),
+  KEY `idx_snapshot_user` (`snapshot_id`,`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
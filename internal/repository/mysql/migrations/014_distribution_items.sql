@@ -0,0 +1,20 @@
+-- ----------------------------
+-- Recipients of reward distributions and their payout state
+-- ----------------------------
+DROP TABLE IF EXISTS `reward_distribution_items`;
+CREATE TABLE `reward_distribution_items` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `distribution_id` char(36) NOT NULL,
+  `position` int NOT NULL,
+  `user_id` varchar(128) NOT NULL,
+  `rule_id` char(36) NOT NULL,
+  `reward_point_type_id` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `status` enum('pending','paid','failed') NOT NULL DEFAULT 'pending',
+  `transaction_id` bigint DEFAULT NULL,
+  `error` varchar(1024) DEFAULT NULL,
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_distribution_position` (`distribution_id`,`position`),
+  KEY `idx_distribution_status` (`distribution_id`,`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,7 @@
+-- ----------------------------
+-- Validity windows of reward rules; 0 leaves an end open
+-- ----------------------------
+ALTER TABLE `reward_rules`
+  MODIFY COLUMN `reward_point_type_id` bigint NOT NULL,
+  ADD COLUMN `starts_at` bigint NOT NULL DEFAULT '0' AFTER `active`,
+  ADD COLUMN `ends_at` bigint NOT NULL DEFAULT '0' AFTER `starts_at`;
@@ -0,0 +1,25 @@
+-- ----------------------------
+-- Reward rule modes and the pools that pool-share rules split
+-- ----------------------------
+ALTER TABLE `reward_rules`
+  ADD COLUMN `mode` enum('fixed','pool_share','percentile','threshold') NOT NULL DEFAULT 'fixed' AFTER `point_type_id`,
+  MODIFY COLUMN `min_rank` int NOT NULL DEFAULT '0',
+  MODIFY COLUMN `max_rank` int NOT NULL DEFAULT '0',
+  ADD COLUMN `percentile_from` int NOT NULL DEFAULT '0' AFTER `max_rank`,
+  ADD COLUMN `percentile_to` int NOT NULL DEFAULT '0' AFTER `percentile_from`,
+  ADD COLUMN `min_score` bigint NOT NULL DEFAULT '0' AFTER `percentile_to`,
+  MODIFY COLUMN `reward_amount` bigint NOT NULL DEFAULT '0',
+  ADD COLUMN `share_bps` int NOT NULL DEFAULT '0' AFTER `reward_amount`;
+
+DROP TABLE IF EXISTS `reward_pools`;
+CREATE TABLE `reward_pools` (
+  `point_type_id` bigint NOT NULL,
+  `reward_point_type_id` bigint NOT NULL,
+  `balance` bigint NOT NULL DEFAULT '0',
+  `updated_at` bigint NOT NULL,
+  PRIMARY KEY (`point_type_id`,`reward_point_type_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- Percentile bands are taken of everyone ranked when the snapshot was taken
+ALTER TABLE `ranking_snapshots`
+  ADD COLUMN `participants` bigint NOT NULL DEFAULT '0' AFTER `entries`;
@@ -0,0 +1,10 @@
+-- ----------------------------
+-- Reward catalog management
+-- ----------------------------
+ALTER TABLE `redemption_rewards`
+  ADD COLUMN `archived_at` bigint DEFAULT NULL AFTER `total_redeemed`,
+  ADD COLUMN `updated_at` bigint NOT NULL DEFAULT '0' AFTER `created_at`,
+  ADD KEY `idx_catalog` (`archived_at`,`enabled`,`created_at`);
+
+ALTER TABLE `redemption_costs`
+  ADD KEY `idx_point_type` (`point_type_id`);
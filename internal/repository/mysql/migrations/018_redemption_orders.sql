@@ -0,0 +1,21 @@
+-- ----------------------------
+-- Redemption orders: pending, then fulfilled or cancelled
+-- ----------------------------
+ALTER TABLE `redemption_records`
+  MODIFY COLUMN `status` enum('completed','pending','fulfilled','cancelled') NOT NULL DEFAULT 'pending',
+  ADD COLUMN `note` varchar(255) NOT NULL DEFAULT '' AFTER `status`,
+  ADD COLUMN `updated_at` bigint NOT NULL DEFAULT '0' AFTER `created_at`,
+  ADD KEY `idx_status` (`status`,`created_at`);
+
+-- ----------------------------
+-- Table structure for redemption_record_costs: the debit paying each cost,
+-- reversed when the order is cancelled
+-- ----------------------------
+DROP TABLE IF EXISTS `redemption_record_costs`;
+CREATE TABLE `redemption_record_costs` (
+  `record_id` char(36) NOT NULL,
+  `point_type_id` bigint NOT NULL,
+  `amount` bigint NOT NULL,
+  `transaction_id` varchar(64) NOT NULL,
+  PRIMARY KEY (`record_id`,`point_type_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
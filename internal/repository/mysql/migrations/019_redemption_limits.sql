@@ -0,0 +1,16 @@
+-- ----------------------------
+-- Table structure for redemption_reward_limits
+-- ----------------------------
+DROP TABLE IF EXISTS `redemption_reward_limits`;
+CREATE TABLE `redemption_reward_limits` (
+  `reward_id` char(36) NOT NULL,
+  `scope` enum('user','global') NOT NULL,
+  `period` varchar(16) NOT NULL DEFAULT '',
+  `max_count` int NOT NULL,
+  PRIMARY KEY (`reward_id`,`scope`,`period`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- limits count a reward's redemptions by user or by everyone since a time
+ALTER TABLE `redemption_records`
+  ADD KEY `idx_reward_user` (`reward_id`,`user_id`,`created_at`),
+  ADD KEY `idx_reward` (`reward_id`,`created_at`);
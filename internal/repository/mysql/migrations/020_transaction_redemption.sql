@@ -0,0 +1,30 @@
+-- ----------------------------
+-- Link redemption debits and refunds to their order, so rankings by net
+-- earned points recognise them structurally rather than by reason text
+-- ----------------------------
+ALTER TABLE `transactions`
+  ADD COLUMN `redemption_id` char(36) DEFAULT NULL AFTER `reversal_of`,
+  ADD KEY `idx_redemption` (`redemption_id`);
+
+UPDATE `transactions` t
+  JOIN `redemption_record_costs` c ON c.`transaction_id` = t.`id`
+  SET t.`redemption_id` = c.`record_id`;
+
+UPDATE `transactions` t
+  JOIN `redemption_record_costs` c ON c.`transaction_id` = t.`reversal_of`
+  SET t.`redemption_id` = c.`record_id`;
+
+-- debits of redemptions made before orders recorded their payments carry no
+-- order id, so they are matched by user, second and point type, and linked
+-- only where that finds exactly one debit and one completed order. Ambiguous
+-- legacy debits stay unlinked and count as spending in net earned rankings.
+UPDATE `transactions` t
+  JOIN (
+    SELECT MIN(l.`id`) AS `transaction_id`, MIN(r.`id`) AS `record_id`
+      FROM `transactions` l
+      JOIN `redemption_records` r ON r.`user_id` = l.`user_id` AND r.`created_at` = l.`created_at` AND r.`status` = 'completed'
+      WHERE l.`type` = 'debit' AND l.`reason` = 'redemption' AND l.`reversal_of` IS NULL AND l.`redemption_id` IS NULL
+      GROUP BY l.`user_id`, l.`created_at`, l.`point_type_id`
+      HAVING COUNT(DISTINCT l.`id`) = 1 AND COUNT(DISTINCT r.`id`) = 1
+  ) m ON m.`transaction_id` = t.`id`
+  SET t.`redemption_id` = m.`record_id`;
@@ -18,7 +18,7 @@ func NewPointTypeRepository(db *sql.DB) *PointTypeRepository { return &PointType
 var _ uc.PointTypeRepository = (*PointTypeRepository)(nil)
 
 func (r *PointTypeRepository) CreatePointType(ctx context.Context, pt d.PointType) (string, error) {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO point_types (uri,display_name,description,enabled,created_at) VALUES (?,?,?,?,?)`, pt.URI, pt.DisplayName, pt.Description, pt.Enabled, time.Now().Unix())
+	_, err := r.db.ExecContext(ctx, `INSERT INTO point_types (uri,display_name,description,enabled,transfer_enabled,expiry_policy,expiry_days,ranking_metric,created_at) VALUES (?,?,?,?,?,?,?,?,?)`, pt.URI, pt.DisplayName, pt.Description, pt.Enabled, pt.TransferEnabled, expiryPolicy(pt.ExpiryPolicy), pt.ExpiryDays, rankingMetric(pt.RankingMetric), time.Now().Unix())
 	if err != nil {
 		return "", err
 	}
@@ -27,7 +27,7 @@ func (r *PointTypeRepository) CreatePointType(ctx context.Context, pt d.PointTyp
 }
 
 func (r *PointTypeRepository) UpdatePointType(ctx context.Context, pt d.PointType) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE point_types SET display_name=?, description=?, enabled=? WHERE id=?`, pt.DisplayName, pt.Description, pt.Enabled, pt.ID)
+	_, err := r.db.ExecContext(ctx, `UPDATE point_types SET display_name=?, description=?, enabled=?, transfer_enabled=?, expiry_policy=?, expiry_days=?, ranking_metric=? WHERE id=?`, pt.DisplayName, pt.Description, pt.Enabled, pt.TransferEnabled, expiryPolicy(pt.ExpiryPolicy), pt.ExpiryDays, rankingMetric(pt.RankingMetric), pt.ID)
 	return err
 }
 
@@ -42,10 +42,10 @@ func (r *PointTypeRepository) SoftDeletePointType(ctx context.Context, uri strin
 }
 
 func (r *PointTypeRepository) GetPointTypeByID(ctx context.Context, pointTypeID int64) (*d.PointType, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,uri,display_name,description,enabled,deleted_at,created_at FROM point_types WHERE id=? AND deleted_at IS NULL`, pointTypeID)
+	row := r.db.QueryRowContext(ctx, `SELECT id,uri,display_name,description,enabled,transfer_enabled,expiry_policy,expiry_days,ranking_metric,deleted_at,created_at FROM point_types WHERE id=? AND deleted_at IS NULL`, pointTypeID)
 	var pt d.PointType
 	var deletedAt *int64
-	if err := row.Scan(&pt.ID, &pt.URI, &pt.DisplayName, &pt.Description, &pt.Enabled, &deletedAt, &pt.CreatedAt); err != nil {
+	if err := row.Scan(&pt.ID, &pt.URI, &pt.DisplayName, &pt.Description, &pt.Enabled, &pt.TransferEnabled, &pt.ExpiryPolicy, &pt.ExpiryDays, &pt.RankingMetric, &deletedAt, &pt.CreatedAt); err != nil {
 		return nil, err
 	}
 	pt.DeletedAt = deletedAt
@@ -53,10 +53,10 @@ func (r *PointTypeRepository) GetPointTypeByID(ctx context.Context, pointTypeID
 }
 
 func (r *PointTypeRepository) GetPointTypeByURI(ctx context.Context, uri string) (*d.PointType, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,uri,display_name,description,enabled,deleted_at,created_at FROM point_types WHERE uri=? AND deleted_at IS NULL`, uri)
+	row := r.db.QueryRowContext(ctx, `SELECT id,uri,display_name,description,enabled,transfer_enabled,expiry_policy,expiry_days,ranking_metric,deleted_at,created_at FROM point_types WHERE uri=? AND deleted_at IS NULL`, uri)
 	var pt d.PointType
 	var deletedAt *int64
-	if err := row.Scan(&pt.ID, &pt.URI, &pt.DisplayName, &pt.Description, &pt.Enabled, &deletedAt, &pt.CreatedAt); err != nil {
+	if err := row.Scan(&pt.ID, &pt.URI, &pt.DisplayName, &pt.Description, &pt.Enabled, &pt.TransferEnabled, &pt.ExpiryPolicy, &pt.ExpiryDays, &pt.RankingMetric, &deletedAt, &pt.CreatedAt); err != nil {
 		return nil, err
 	}
 	pt.DeletedAt = deletedAt
@@ -64,7 +64,7 @@ func (r *PointTypeRepository) GetPointTypeByURI(ctx context.Context, uri string)
 }
 
 func (r *PointTypeRepository) ListPointTypes(ctx context.Context, limit, offset int) ([]d.PointType, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id,uri,display_name,description,enabled,deleted_at,created_at FROM point_types WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset)
+	rows, err := r.db.QueryContext(ctx, `SELECT id,uri,display_name,description,enabled,transfer_enabled,expiry_policy,expiry_days,ranking_metric,deleted_at,created_at FROM point_types WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +73,7 @@ func (r *PointTypeRepository) ListPointTypes(ctx context.Context, limit, offset
 	for rows.Next() {
 		var pt d.PointType
 		var deletedAt *int64
-		if err := rows.Scan(&pt.ID, &pt.URI, &pt.DisplayName, &pt.Description, &pt.Enabled, &deletedAt, &pt.CreatedAt); err != nil {
+		if err := rows.Scan(&pt.ID, &pt.URI, &pt.DisplayName, &pt.Description, &pt.Enabled, &pt.TransferEnabled, &pt.ExpiryPolicy, &pt.ExpiryDays, &pt.RankingMetric, &deletedAt, &pt.CreatedAt); err != nil {
 			return nil, err
 		}
 		pt.DeletedAt = deletedAt
@@ -82,6 +82,20 @@ func (r *PointTypeRepository) ListPointTypes(ctx context.Context, limit, offset
 	return res, rows.Err()
 }
 
+func rankingMetric(m d.RankingMetric) string {
+	if m == "" {
+		return string(d.MetricBalance)
+	}
+	return string(m)
+}
+
+func expiryPolicy(p d.ExpiryPolicy) string {
+	if p == "" {
+		return string(d.ExpiryNone)
+	}
+	return string(p)
+}
+
 func (r *PointTypeRepository) HasBalances(ctx context.Context, pointTypeID int64) (bool, error) {
 	// Placeholder: real check when balances table exists
 	return false, nil
@@ -0,0 +1,354 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+// rankingPurgeInterval is how often expired windowed scores are deleted.
+const rankingPurgeInterval = time.Minute
+
+// RankingRepository ranks with SQL queries, for deployments without Redis.
+// The all-time ranking of a point type ranked by balance is read straight
+// from user_balances, so writing its scores is a no-op; every other ranking
+// is stored in ranking_scores. Order and tie-breaking follow the Redis
+// repository: highest score first, equal scores by descending user ID.
+type RankingRepository struct {
+	db *sql.DB
+
+	mu       sync.Mutex
+	purgedAt time.Time
+}
+
+func NewRankingRepository(db *sql.DB) *RankingRepository { return &RankingRepository{db: db} }
+
+var (
+	_ uc.RankingRepository    = (*RankingRepository)(nil)
+	_ uc.DistinctScoreCounter = (*RankingRepository)(nil)
+)
+
+// boardName is the ranking_scores board of a window; all-time is empty.
+func boardName(w d.RankingWindow) string {
+	if w.Period == d.PeriodAllTime {
+		return ""
+	}
+	return string(w.Period) + ":" + w.Key
+}
+
+// fromBalances reports whether a ranking is read from user_balances.
+func (r *RankingRepository) fromBalances(ctx context.Context, pointTypeID int64, w d.RankingWindow) (bool, error) {
+	if w.Period != d.PeriodAllTime {
+		return false, nil
+	}
+	var metric string
+	err := getTx(ctx, r.db).QueryRowContext(ctx, `SELECT ranking_metric FROM point_types WHERE id=?`, pointTypeID).Scan(&metric)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	return metric == string(d.MetricBalance), err
+}
+
+// source returns a (user_id, score) subquery of a ranking and its arguments.
+func (r *RankingRepository) source(ctx context.Context, pointTypeID int64, w d.RankingWindow) (string, []any, error) {
+	balances, err := r.fromBalances(ctx, pointTypeID, w)
+	if err != nil {
+		return "", nil, err
+	}
+	if balances {
+		return `SELECT user_id, balance AS score FROM user_balances WHERE point_type_id=?`, []any{pointTypeID}, nil
+	}
+	return `SELECT user_id, score FROM ranking_scores WHERE point_type_id=? AND board=? AND (expire_at IS NULL OR expire_at>?)`,
+		[]any{pointTypeID, boardName(w), time.Now().Unix()}, nil
+}
+
+func (r *RankingRepository) UpdateUserScore(ctx context.Context, pointTypeID int64, userID string, score int64) error {
+	return r.UpdateUserScores(ctx, []uc.ScoreUpdate{{PointTypeID: pointTypeID, UserID: userID, Score: score}})
+}
+
+// UpdateUserScores stores the scores of rankings that are not read from
+// user_balances; those already hold the balance being written.
+func (r *RankingRepository) UpdateUserScores(ctx context.Context, updates []uc.ScoreUpdate) error {
+	stored, err := r.storedUpdates(ctx, updates)
+	if err != nil || len(stored) == 0 {
+		return err
+	}
+	return r.upsertScores(ctx, stored, `score=VALUES(score)`)
+}
+
+func (r *RankingRepository) IncrementUserScores(ctx context.Context, updates []uc.ScoreUpdate) error {
+	stored, err := r.storedUpdates(ctx, updates)
+	if err != nil || len(stored) == 0 {
+		return err
+	}
+	return r.upsertScores(ctx, stored, `score=score+VALUES(score)`)
+}
+
+func (r *RankingRepository) storedUpdates(ctx context.Context, updates []uc.ScoreUpdate) ([]uc.ScoreUpdate, error) {
+	balances := map[int64]bool{}
+	var stored []uc.ScoreUpdate
+	for _, u := range updates {
+		b, ok := balances[u.PointTypeID]
+		if !ok {
+			var err error
+			if b, err = r.fromBalances(ctx, u.PointTypeID, d.RankingWindow{}); err != nil {
+				return nil, err
+			}
+			balances[u.PointTypeID] = b
+		}
+		if !b {
+			stored = append(stored, u)
+		}
+	}
+	return stored, nil
+}
+
+func (r *RankingRepository) upsertScores(ctx context.Context, updates []uc.ScoreUpdate, onDuplicate string) error {
+	args := make([]any, 0, len(updates)*3)
+	for _, u := range updates {
+		args = append(args, u.PointTypeID, u.UserID, u.Score)
+	}
+	_, err := getTx(ctx, r.db).ExecContext(ctx, `INSERT INTO ranking_scores (point_type_id,board,user_id,score) VALUES `+placeholders("(?,'',?,?)", len(updates))+` ON DUPLICATE KEY UPDATE `+onDuplicate, args...)
+	return err
+}
+
+// IncrementWindowScores upserts the windowed scores with their expiry, and
+// now and then deletes the scores of windows past their retention.
+func (r *RankingRepository) IncrementWindowScores(ctx context.Context, incs []uc.WindowIncrement) error {
+	if len(incs) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(incs)*5)
+	for _, inc := range incs {
+		args = append(args, inc.PointTypeID, boardName(inc.Window), inc.UserID, inc.Amount, inc.ExpireAt)
+	}
+	ex := getTx(ctx, r.db)
+	if _, err := ex.ExecContext(ctx, `INSERT INTO ranking_scores (point_type_id,board,user_id,score,expire_at) VALUES `+placeholders("(?,?,?,?,?)", len(incs))+` ON DUPLICATE KEY UPDATE score=score+VALUES(score), expire_at=VALUES(expire_at)`, args...); err != nil {
+		return err
+	}
+	now := time.Now()
+	r.mu.Lock()
+	purge := now.Sub(r.purgedAt) >= rankingPurgeInterval
+	if purge {
+		r.purgedAt = now
+	}
+	r.mu.Unlock()
+	if purge {
+		_, err := ex.ExecContext(ctx, `DELETE FROM ranking_scores WHERE expire_at<=?`, now.Unix())
+		return err
+	}
+	return nil
+}
+
+func (r *RankingRepository) GetTop(ctx context.Context, pointTypeID int64, w d.RankingWindow, start, stop int64) ([]d.RankingEntry, error) {
+	src, args, err := r.source(ctx, pointTypeID, w)
+	if err != nil {
+		return nil, err
+	}
+	// negative indexes count from the end, as in ZREVRANGE
+	if start < 0 || stop < 0 {
+		n, err := r.Count(ctx, pointTypeID, w)
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 {
+			start = max(n+start, 0)
+		}
+		if stop < 0 {
+			stop = n + stop
+		}
+	}
+	res := []d.RankingEntry{}
+	if stop < start {
+		return res, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT user_id, score FROM (`+src+`) b ORDER BY score DESC, user_id DESC LIMIT ? OFFSET ?`, append(args, stop-start+1, start)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e d.RankingEntry
+		if err := rows.Scan(&e.UserID, &e.Score); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+func (r *RankingRepository) Count(ctx context.Context, pointTypeID int64, w d.RankingWindow) (int64, error) {
+	src, args, err := r.source(ctx, pointTypeID, w)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM (`+src+`) b`, args...).Scan(&n)
+	return n, err
+}
+
+func (r *RankingRepository) CountAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error) {
+	src, args, err := r.source(ctx, pointTypeID, w)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM (`+src+`) b WHERE score>?`, append(args, score)...).Scan(&n)
+	return n, err
+}
+
+func (r *RankingRepository) CountDistinctAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error) {
+	src, args, err := r.source(ctx, pointTypeID, w)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT score) FROM (`+src+`) b WHERE score>?`, append(args, score)...).Scan(&n)
+	return n, err
+}
+
+// GetUserPosition looks up the user's score, then counts the users ahead.
+func (r *RankingRepository) GetUserPosition(ctx context.Context, pointTypeID int64, w d.RankingWindow, userID string) (int64, int64, error) {
+	src, args, err := r.source(ctx, pointTypeID, w)
+	if err != nil {
+		return 0, 0, err
+	}
+	var score int64
+	err = r.db.QueryRowContext(ctx, `SELECT score FROM (`+src+`) b WHERE user_id=?`, append(args, userID)...).Scan(&score)
+	if err == sql.ErrNoRows {
+		return 0, 0, d.ErrUserNotRanked
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var pos int64
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM (`+src+`) b WHERE score>? OR (score=? AND user_id>?)`, append(args, score, score, userID)...).Scan(&pos)
+	return pos, score, err
+}
+
+func (r *RankingRepository) GetUserScores(ctx context.Context, pointTypeID int64, userIDs []string) (map[string]int64, error) {
+	res := make(map[string]int64, len(userIDs))
+	if len(userIDs) == 0 {
+		return res, nil
+	}
+	src, args, err := r.source(ctx, pointTypeID, d.RankingWindow{})
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range userIDs {
+		args = append(args, u)
+	}
+	err = r.scan(ctx, `SELECT user_id, score FROM (`+src+`) b WHERE user_id IN (`+placeholders("?", len(userIDs))+`)`, args, func(userID string, score int64) error {
+		res[userID] = score
+		return nil
+	})
+	return res, err
+}
+
+func (r *RankingRepository) ScanUserScores(ctx context.Context, pointTypeID int64, fn func(userID string, score int64) error) error {
+	src, args, err := r.source(ctx, pointTypeID, d.RankingWindow{})
+	if err != nil {
+		return err
+	}
+	return r.scan(ctx, src, args, fn)
+}
+
+func (r *RankingRepository) scan(ctx context.Context, query string, args []any, fn func(userID string, score int64) error) error {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var score int64
+		if err := rows.Scan(&userID, &score); err != nil {
+			return err
+		}
+		if err := fn(userID, score); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RemoveUsers is a no-op for rankings read from user_balances, which only
+// rank users that have a balance row.
+func (r *RankingRepository) RemoveUsers(ctx context.Context, pointTypeID int64, userIDs ...string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	balances, err := r.fromBalances(ctx, pointTypeID, d.RankingWindow{})
+	if err != nil || balances {
+		return err
+	}
+	args := []any{pointTypeID}
+	for _, u := range userIDs {
+		args = append(args, u)
+	}
+	_, err = getTx(ctx, r.db).ExecContext(ctx, `DELETE FROM ranking_scores WHERE point_type_id=? AND board='' AND user_id IN (`+placeholders("?", len(userIDs))+`)`, args...)
+	return err
+}
+
+// rankingRebuildBatchSize is the number of scores inserted per statement.
+const rankingRebuildBatchSize = 1000
+
+// Rebuild replaces a stored ranking in one transaction, so readers keep
+// seeing the old ranking until it commits. A ranking read from user_balances
+// is always current; fill is not called and its size is returned.
+func (r *RankingRepository) Rebuild(ctx context.Context, pointTypeID int64, fill func(add func(userID string, score int64) error) error) (int64, error) {
+	balances, err := r.fromBalances(ctx, pointTypeID, d.RankingWindow{})
+	if err != nil {
+		return 0, err
+	}
+	if balances {
+		return r.Count(ctx, pointTypeID, d.RankingWindow{})
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ranking_scores WHERE point_type_id=? AND board=''`, pointTypeID); err != nil {
+		return 0, err
+	}
+	var count int64
+	batch := make([]uc.ScoreUpdate, 0, rankingRebuildBatchSize)
+	flush := func() error {
+		err := r.upsertScores(context.WithValue(ctx, txKey{}, tx), batch, `score=VALUES(score)`)
+		batch = batch[:0]
+		return err
+	}
+	err = fill(func(userID string, score int64) error {
+		batch = append(batch, uc.ScoreUpdate{PointTypeID: pointTypeID, UserID: userID, Score: score})
+		count++
+		if len(batch) == rankingRebuildBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+func (r *RankingRepository) Exists(ctx context.Context, pointTypeID int64) (bool, error) {
+	balances, err := r.fromBalances(ctx, pointTypeID, d.RankingWindow{})
+	if err != nil || balances {
+		return balances, err
+	}
+	var one int
+	err = r.db.QueryRowContext(ctx, `SELECT 1 FROM ranking_scores WHERE point_type_id=? AND board='' LIMIT 1`, pointTypeID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+type ReconciliationRepository struct{ db *sql.DB }
+
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+var _ uc.ReconciliationRepository = (*ReconciliationRepository)(nil)
+
+// ledgerAggregate sums the signed amount of every transaction per user. Holds
+// and releases leave the balance unchanged; captures are debits.
+const ledgerAggregate = `SELECT user_id,
+	SUM(CASE type WHEN 'credit' THEN amount WHEN 'debit' THEN -amount WHEN 'capture' THEN -amount ELSE 0 END) AS total,
+	COUNT(1) AS entries, MAX(id) AS last_id
+	FROM transactions WHERE point_type_id=? {agg} GROUP BY user_id`
+
+// ledgerTotalsQuery joins balance rows with their ledger aggregate, then adds
+// users that have transactions but no balance row.
+var ledgerTotalsQuery = `SELECT u.user_id, 1, u.balance, COALESCE(l.entries,0), COALESCE(l.total,0), COALESCE(t.after_balance,0)
+	FROM user_balances u
+	LEFT JOIN (` + ledgerAggregate + `) l ON l.user_id=u.user_id
+	LEFT JOIN transactions t ON t.id=l.last_id
+	WHERE u.point_type_id=? {bal}
+	UNION ALL
+	SELECT l.user_id, 0, 0, l.entries, l.total, t.after_balance
+	FROM (` + ledgerAggregate + `) l
+	JOIN transactions t ON t.id=l.last_id
+	WHERE NOT EXISTS (SELECT 1 FROM user_balances u WHERE u.user_id=l.user_id AND u.point_type_id=?)`
+
+// ledgerTotalsSQL returns ledgerTotalsQuery, restricted to a single user_id
+// parameter when oneUser is set.
+func ledgerTotalsSQL(oneUser bool) string {
+	if !oneUser {
+		return strings.NewReplacer("{agg}", "", "{bal}", "").Replace(ledgerTotalsQuery)
+	}
+	return strings.NewReplacer("{agg}", "AND user_id=?", "{bal}", "AND u.user_id=?").Replace(ledgerTotalsQuery)
+}
+
+func scanLedgerTotal(sc scanner, pointTypeID int64) (uc.LedgerTotal, error) {
+	lt := uc.LedgerTotal{PointTypeID: pointTypeID}
+	err := sc.Scan(&lt.UserID, &lt.HasBalance, &lt.Balance, &lt.Entries, &lt.Total, &lt.LastAfter)
+	return lt, err
+}
+
+func (r *ReconciliationRepository) StreamLedgerTotals(ctx context.Context, pointTypeID int64, fn func(uc.LedgerTotal) error) error {
+	query := ledgerTotalsSQL(false) + ` ORDER BY 1`
+	rows, err := r.db.QueryContext(ctx, query, pointTypeID, pointTypeID, pointTypeID, pointTypeID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		lt, err := scanLedgerTotal(rows, pointTypeID)
+		if err != nil {
+			return err
+		}
+		if err := fn(lt); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetLedgerTotal computes the ledger total of one user. Inside WithTx, lock
+// the balance row first so no transaction for the user commits in between.
+func (r *ReconciliationRepository) GetLedgerTotal(ctx context.Context, userID string, pointTypeID int64) (uc.LedgerTotal, error) {
+	ex := getTx(ctx, r.db)
+	query := ledgerTotalsSQL(true)
+	row := ex.QueryRowContext(ctx, query, pointTypeID, userID, pointTypeID, userID, pointTypeID, userID, pointTypeID)
+	lt, err := scanLedgerTotal(row, pointTypeID)
+	if err == sql.ErrNoRows {
+		return uc.LedgerTotal{UserID: userID, PointTypeID: pointTypeID}, nil
+	}
+	return lt, err
+}
+
+func (r *ReconciliationRepository) InsertReconciliationFixes(ctx context.Context, fixes []d.ReconciliationFix) error {
+	if len(fixes) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(fixes)*7)
+	for _, f := range fixes {
+		args = append(args, f.RunID, string(f.Kind), f.UserID, f.PointTypeID, f.OldValue, f.NewValue, f.CreatedAt)
+	}
+	ex := getTx(ctx, r.db)
+	_, err := ex.ExecContext(ctx, `INSERT INTO reconciliation_fixes (run_id,kind,user_id,point_type_id,old_value,new_value,created_at) VALUES `+placeholders("(?,?,?,?,?,?,?)", len(fixes)), args...)
+	return err
+}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 
 	d "github.com/usual2970/acto/domain/points"
@@ -15,55 +16,403 @@ func NewRedemptionRepository(db *sql.DB) *RedemptionRepository { return &Redempt
 
 var _ uc.RedemptionRepository = (*RedemptionRepository)(nil)
 
+const rewardColumns = `id,name,COALESCE(description,''),quantity,enabled,total_redeemed,archived_at,created_at,updated_at`
+
+func scanReward(sc scanner) (d.RedemptionReward, error) {
+	var rr d.RedemptionReward
+	err := sc.Scan(&rr.ID, &rr.Name, &rr.Description, &rr.Quantity, &rr.Enabled, &rr.TotalRedeemed, &rr.ArchivedAt, &rr.CreatedAt, &rr.UpdatedAt)
+	return rr, err
+}
+
+// CreateReward stores a reward with its costs, whose point type URIs must
+// exist.
 func (r *RedemptionRepository) CreateReward(ctx context.Context, rr d.RedemptionReward) (string, error) {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO redemption_rewards (name,description,quantity,enabled,total_redeemed,created_at) VALUES (?,?,?,?,0,?)`, rr.Name, rr.Description, rr.Quantity, rr.Enabled, time.Now().Unix())
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", err
 	}
-	var id string
-	_ = r.db.QueryRowContext(ctx, `SELECT id FROM redemption_rewards WHERE name=? ORDER BY created_at DESC LIMIT 1`, rr.Name).Scan(&id)
-	for pt, amt := range rr.Costs {
-		if _, err := r.db.ExecContext(ctx, `INSERT INTO redemption_costs (reward_id,point_type_id,amount) VALUES (?,?,?)`, id, pt, amt); err != nil {
-			return "", err
+	defer func() { _ = tx.Rollback() }()
+	now := time.Now().Unix()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO redemption_rewards (id,name,description,quantity,enabled,total_redeemed,created_at,updated_at) VALUES (?,?,?,?,?,0,?,?)`, rr.ID, rr.Name, rr.Description, rr.Quantity, rr.Enabled, now, now); err != nil {
+		return "", err
+	}
+	if err := insertCosts(ctx, tx, rr.ID, rr.Costs); err != nil {
+		return "", err
+	}
+	if err := insertLimits(ctx, tx, rr.ID, rr.Limits); err != nil {
+		return "", err
+	}
+	return rr.ID, tx.Commit()
+}
+
+// insertCosts resolves the point type URIs of costs in the same statement.
+func insertCosts(ctx context.Context, tx *sql.Tx, rewardID string, costs map[string]int64) error {
+	for uri, amount := range costs {
+		res, err := tx.ExecContext(ctx, `INSERT INTO redemption_costs (reward_id,point_type_id,amount) SELECT ?,id,? FROM point_types WHERE uri=? AND deleted_at IS NULL`, rewardID, amount, uri)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return d.ErrPointTypeNotFound
 		}
 	}
-	return id, nil
+	return nil
+}
+
+func insertLimits(ctx context.Context, tx *sql.Tx, rewardID string, limits []d.RedemptionLimit) error {
+	if len(limits) == 0 {
+		return nil
+	}
+	args := make([]any, 0, 4*len(limits))
+	for _, l := range limits {
+		args = append(args, rewardID, string(l.Scope), string(l.Period), l.Max)
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO redemption_reward_limits (reward_id,scope,period,max_count) VALUES `+placeholders("(?,?,?,?)", len(limits)), args...)
+	return err
 }
 
 func (r *RedemptionRepository) GetRewardByID(ctx context.Context, rewardID string) (*d.RedemptionReward, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,name,description,quantity,enabled,total_redeemed,created_at FROM redemption_rewards WHERE id=?`, rewardID)
-	var rr d.RedemptionReward
-	if err := row.Scan(&rr.ID, &rr.Name, &rr.Description, &rr.Quantity, &rr.Enabled, &rr.TotalRedeemed, &rr.CreatedAt); err != nil {
-		return nil, err
+	rr, err := scanReward(r.db.QueryRowContext(ctx, `SELECT `+rewardColumns+` FROM redemption_rewards WHERE id=?`, rewardID))
+	if err == sql.ErrNoRows {
+		return nil, d.ErrRewardNotFound
 	}
-	rows, err := r.db.QueryContext(ctx, `SELECT point_type_id,amount FROM redemption_costs WHERE reward_id=?`, rewardID)
 	if err != nil {
 		return nil, err
 	}
+	rewards := []d.RedemptionReward{rr}
+	if err := r.loadCosts(ctx, rewards); err != nil {
+		return nil, err
+	}
+	return &rewards[0], nil
+}
+
+// ListRewards returns a page of rewards, newest first, and the number of
+// rewards matching the filter.
+func (r *RedemptionRepository) ListRewards(ctx context.Context, f uc.RewardFilter) ([]d.RedemptionReward, int, error) {
+	where := ` WHERE 1=1`
+	var args []any
+	if f.PointTypeID != 0 {
+		where += ` AND id IN (SELECT reward_id FROM redemption_costs WHERE point_type_id=?)`
+		args = append(args, f.PointTypeID)
+	}
+	if f.Query != "" {
+		where += ` AND name LIKE ?`
+		args = append(args, "%"+escapeLike(f.Query)+"%")
+	}
+	if f.Enabled != nil {
+		where += ` AND enabled=?`
+		args = append(args, *f.Enabled)
+	}
+	if f.InStock {
+		where += ` AND quantity>0`
+	}
+	if !f.IncludeArchived {
+		where += ` AND archived_at IS NULL`
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM redemption_rewards`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+rewardColumns+` FROM redemption_rewards`+where+` ORDER BY created_at DESC, id LIMIT ? OFFSET ?`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer rows.Close()
-	rr.Costs = map[int64]int64{}
+	res := []d.RedemptionReward{}
 	for rows.Next() {
-		var pt int64
-		var amt int64
-		if err := rows.Scan(&pt, &amt); err != nil {
-			return nil, err
+		rr, err := scanReward(rows)
+		if err != nil {
+			return nil, 0, err
 		}
-		rr.Costs[pt] = amt
+		res = append(res, rr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := r.loadCosts(ctx, res); err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+// loadCosts fills in the costs and limits of rewards.
+func (r *RedemptionRepository) loadCosts(ctx context.Context, rewards []d.RedemptionReward) error {
+	if len(rewards) == 0 {
+		return nil
+	}
+	byID := make(map[string]*d.RedemptionReward, len(rewards))
+	args := make([]any, len(rewards))
+	for i := range rewards {
+		rewards[i].Costs = map[string]int64{}
+		byID[rewards[i].ID] = &rewards[i]
+		args[i] = rewards[i].ID
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT c.reward_id,p.uri,c.amount FROM redemption_costs c JOIN point_types p ON p.id=c.point_type_id WHERE c.reward_id IN (`+placeholders("?", len(args))+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, uri string
+		var amount int64
+		if err := rows.Scan(&id, &uri, &amount); err != nil {
+			return err
+		}
+		byID[id].Costs[uri] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return r.loadLimits(ctx, byID, args)
+}
+
+func (r *RedemptionRepository) loadLimits(ctx context.Context, byID map[string]*d.RedemptionReward, ids []any) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT reward_id,scope,period,max_count FROM redemption_reward_limits WHERE reward_id IN (`+placeholders("?", len(ids))+`) ORDER BY reward_id, scope, period`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var l d.RedemptionLimit
+		if err := rows.Scan(&id, &l.Scope, &l.Period, &l.Max); err != nil {
+			return err
+		}
+		byID[id].Limits = append(byID[id].Limits, l)
+	}
+	return rows.Err()
+}
+
+// UpdateReward writes the name, description, enabled flag, costs and limits
+// of a reward that is not archived; costs and limits are replaced as a whole.
+func (r *RedemptionRepository) UpdateReward(ctx context.Context, rr d.RedemptionReward) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	res, err := tx.ExecContext(ctx, `UPDATE redemption_rewards SET name=?, description=?, enabled=?, updated_at=? WHERE id=? AND archived_at IS NULL`, rr.Name, rr.Description, rr.Enabled, time.Now().Unix(), rr.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return d.ErrRewardNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM redemption_costs WHERE reward_id=?`, rr.ID); err != nil {
+		return err
+	}
+	if err := insertCosts(ctx, tx, rr.ID, rr.Costs); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM redemption_reward_limits WHERE reward_id=?`, rr.ID); err != nil {
+		return err
+	}
+	if err := insertLimits(ctx, tx, rr.ID, rr.Limits); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ArchiveReward also disables the reward; redemption records keep pointing
+// at it.
+func (r *RedemptionRepository) ArchiveReward(ctx context.Context, rewardID string) error {
+	now := time.Now().Unix()
+	res, err := r.db.ExecContext(ctx, `UPDATE redemption_rewards SET enabled=0, archived_at=?, updated_at=? WHERE id=? AND archived_at IS NULL`, now, now, rewardID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return d.ErrRewardNotFound
+	}
+	return nil
+}
+
+func (r *RedemptionRepository) RestockReward(ctx context.Context, rewardID string, quantity int) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE redemption_rewards SET quantity=quantity+?, updated_at=? WHERE id=? AND archived_at IS NULL`, quantity, time.Now().Unix(), rewardID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return d.ErrRewardNotFound
 	}
-	return &rr, rows.Err()
+	return nil
 }
 
+// DecrementInventory reserves stock inside the caller's transaction. The
+// conditional update locks the reward row, so concurrent redemptions of the
+// last items cannot both succeed.
 func (r *RedemptionRepository) DecrementInventory(ctx context.Context, rewardID string, quantity int) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE redemption_rewards SET quantity=quantity-? WHERE id=? AND quantity>=?`, quantity, rewardID, quantity)
+	res, err := getTx(ctx, r.db).ExecContext(ctx, `UPDATE redemption_rewards SET quantity=quantity-?, total_redeemed=total_redeemed+? WHERE id=? AND quantity>=?`, quantity, quantity, rewardID, quantity)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return d.ErrRewardOutOfStock
+	}
+	return nil
+}
+
+// ReleaseInventory puts back stock taken by a cancelled redemption. Records
+// made before total_redeemed was kept may have it at zero already.
+func (r *RedemptionRepository) ReleaseInventory(ctx context.Context, rewardID string, quantity int) error {
+	_, err := getTx(ctx, r.db).ExecContext(ctx, `UPDATE redemption_rewards SET quantity=quantity+?, total_redeemed=GREATEST(total_redeemed-?,0) WHERE id=?`, quantity, quantity, rewardID)
 	return err
 }
 
+// CountRedemptions counts the redemptions of a reward since a unix time that
+// were not cancelled, by userID or by everyone when it is empty. It is a
+// locking read so that, run after DecrementInventory locked the reward row,
+// it sees every redemption committed before.
+func (r *RedemptionRepository) CountRedemptions(ctx context.Context, rewardID, userID string, since int64) (int, error) {
+	q := `SELECT COUNT(1) FROM redemption_records WHERE reward_id=? AND created_at>=? AND status<>'cancelled'`
+	args := []any{rewardID, since}
+	if userID != "" {
+		q += ` AND user_id=?`
+		args = append(args, userID)
+	}
+	var n int
+	err := getTx(ctx, r.db).QueryRowContext(ctx, q+` FOR SHARE`, args...).Scan(&n)
+	return n, err
+}
+
+const redemptionColumns = `id,user_id,reward_id,status,note,created_at,updated_at`
+
+func scanRedemption(sc scanner) (d.RedemptionRecord, error) {
+	var rec d.RedemptionRecord
+	err := sc.Scan(&rec.ID, &rec.UserID, &rec.RewardID, &rec.Status, &rec.Note, &rec.CreatedAt, &rec.UpdatedAt)
+	return rec, err
+}
+
+// CreateRedemptionRecord stores a record with the debits paying for it.
 func (r *RedemptionRepository) CreateRedemptionRecord(ctx context.Context, rec d.RedemptionRecord) (string, error) {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO redemption_records (user_id,reward_id,status,created_at) VALUES (?,?,'completed',?)`, rec.UserID, rec.RewardID, time.Now().Unix())
-	if err != nil {
+	ex := getTx(ctx, r.db)
+	now := time.Now().Unix()
+	if _, err := ex.ExecContext(ctx, `INSERT INTO redemption_records (id,user_id,reward_id,status,note,created_at,updated_at) VALUES (?,?,?,?,?,?,?)`, rec.ID, rec.UserID, rec.RewardID, string(rec.Status), rec.Note, now, now); err != nil {
+		return "", err
+	}
+	if len(rec.Payments) == 0 {
+		return rec.ID, nil
+	}
+	args := make([]any, 0, 4*len(rec.Payments))
+	for _, p := range rec.Payments {
+		args = append(args, rec.ID, p.PointTypeID, p.Amount, p.TransactionID)
+	}
+	if _, err := ex.ExecContext(ctx, `INSERT INTO redemption_record_costs (record_id,point_type_id,amount,transaction_id) VALUES `+placeholders("(?,?,?,?)", len(rec.Payments)), args...); err != nil {
 		return "", err
 	}
-	var id string
-	_ = r.db.QueryRowContext(ctx, `SELECT id FROM redemption_records WHERE user_id=? ORDER BY created_at DESC LIMIT 1`, rec.UserID).Scan(&id)
-	return id, nil
+	return rec.ID, nil
+}
+
+// GetRedemptionRecord returns a record with its payments; lock reads it
+// with a row lock held until the transaction ends.
+func (r *RedemptionRepository) GetRedemptionRecord(ctx context.Context, id string, lock bool) (*d.RedemptionRecord, error) {
+	q := `SELECT ` + redemptionColumns + ` FROM redemption_records WHERE id=?`
+	if lock {
+		q += ` FOR UPDATE`
+	}
+	rec, err := scanRedemption(getTx(ctx, r.db).QueryRowContext(ctx, q, id))
+	if err == sql.ErrNoRows {
+		return nil, d.ErrRedemptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	recs := []d.RedemptionRecord{rec}
+	if err := r.loadPayments(ctx, recs); err != nil {
+		return nil, err
+	}
+	return &recs[0], nil
+}
+
+// ListRedemptionRecords returns a page of records, newest first, and the
+// number of records matching the filter.
+func (r *RedemptionRepository) ListRedemptionRecords(ctx context.Context, f uc.RedemptionFilter) ([]d.RedemptionRecord, int, error) {
+	where := ` WHERE 1=1`
+	var args []any
+	if f.UserID != "" {
+		where += ` AND user_id=?`
+		args = append(args, f.UserID)
+	}
+	if f.RewardID != "" {
+		where += ` AND reward_id=?`
+		args = append(args, f.RewardID)
+	}
+	if f.Status != "" {
+		where += ` AND status=?`
+		args = append(args, string(f.Status))
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM redemption_records`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+redemptionColumns+` FROM redemption_records`+where+` ORDER BY created_at DESC, id LIMIT ? OFFSET ?`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	res := []d.RedemptionRecord{}
+	for rows.Next() {
+		rec, err := scanRedemption(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if err := r.loadPayments(ctx, res); err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+// loadPayments fills in the payments and costs of records with one query.
+func (r *RedemptionRepository) loadPayments(ctx context.Context, recs []d.RedemptionRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	byID := make(map[string]*d.RedemptionRecord, len(recs))
+	args := make([]any, len(recs))
+	for i := range recs {
+		recs[i].Costs = map[string]int64{}
+		byID[recs[i].ID] = &recs[i]
+		args[i] = recs[i].ID
+	}
+	rows, err := getTx(ctx, r.db).QueryContext(ctx, `SELECT c.record_id,c.point_type_id,p.uri,c.amount,c.transaction_id FROM redemption_record_costs c JOIN point_types p ON p.id=c.point_type_id WHERE c.record_id IN (`+placeholders("?", len(args))+`) ORDER BY c.record_id, c.point_type_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, uri string
+		var p d.RedemptionPayment
+		if err := rows.Scan(&id, &p.PointTypeID, &uri, &p.Amount, &p.TransactionID); err != nil {
+			return err
+		}
+		rec := byID[id]
+		rec.Costs[uri] = p.Amount
+		rec.Payments = append(rec.Payments, p)
+	}
+	return rows.Err()
+}
+
+// UpdateRedemptionStatus moves a record from status from to status to; it
+// fails with ErrRedemptionTransition when the record is no longer in from.
+func (r *RedemptionRepository) UpdateRedemptionStatus(ctx context.Context, id string, from, to d.RedemptionStatus, note string) error {
+	res, err := getTx(ctx, r.db).ExecContext(ctx, `UPDATE redemption_records SET status=?, note=?, updated_at=? WHERE id=? AND status=?`, string(to), note, time.Now().Unix(), id, string(from))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return d.ErrRedemptionTransition
+	}
+	return nil
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
 }
@@ -0,0 +1,171 @@
+package mysql_test
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+
+	d "github.com/usual2970/acto/domain/points"
+	repoMysql "github.com/usual2970/acto/internal/repository/mysql"
+	uc "github.com/usual2970/acto/points"
+)
+
+// testDSNEnv names a MySQL server the tests may create databases on, such as
+// root:root@tcp(127.0.0.1:33061)/. Tests needing MySQL skip without it.
+const testDSNEnv = "ACTO_TEST_MYSQL_DSN"
+
+// openTestDB creates a throwaway database, applies the migrations to it and
+// drops it when the test ends.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDSNEnv)
+	}
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", testDSNEnv, err)
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := "acto_test_" + hex.EncodeToString(suffix)
+
+	cfg.DBName = ""
+	admin, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	if _, err := admin.Exec("CREATE DATABASE `" + name + "`"); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec("DROP DATABASE `" + name + "`") })
+
+	cfg.DBName = name
+	cfg.MultiStatements = true
+	cfg.ParseTime = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	files, err := filepath.Glob("migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		script, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(script)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(f), err)
+		}
+	}
+	return db
+}
+
+// TestRedeemConcurrentStock redeems a reward with stock K from N users at
+// once: exactly K succeed and are charged, the rest fail as out of stock.
+func TestRedeemConcurrentStock(t *testing.T) {
+	const (
+		users = 40
+		stock = 7
+		cost  = 10
+	)
+	db := openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().Unix()
+
+	res, err := db.Exec(`INSERT INTO point_types (uri,display_name,enabled,created_at) VALUES ('coins','Coins',1,?)`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptID, _ := res.LastInsertId()
+	for i := 0; i < users; i++ {
+		if _, err := db.Exec(`INSERT INTO user_balances (user_id,point_type_id,balance,updated_at) VALUES (?,?,?,?)`, fmt.Sprintf("u%02d", i), ptID, 100, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rewards := repoMysql.NewRedemptionRepository(db)
+	reward := d.RedemptionReward{ID: "reward-stock", Name: "Mug", Costs: map[string]int64{"coins": cost}, Quantity: stock, Enabled: true}
+	if _, err := rewards.CreateReward(ctx, reward); err != nil {
+		t.Fatal(err)
+	}
+	svc := uc.NewRedemptionService(rewards, repoMysql.NewBalanceTxRepository(db), nil, repoMysql.NewPointTypeRepository(db))
+
+	var (
+		wg         sync.WaitGroup
+		start      = make(chan struct{})
+		mu         sync.Mutex
+		ok, sold   int
+		unexpected []error
+	)
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			<-start
+			_, err := svc.Redeem(ctx, uc.RedemptionRequest{UserID: userID, RewardID: reward.ID})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, d.ErrRewardOutOfStock):
+				sold++
+			default:
+				unexpected = append(unexpected, err)
+			}
+		}(fmt.Sprintf("u%02d", i))
+	}
+	close(start)
+	wg.Wait()
+
+	if len(unexpected) > 0 {
+		t.Fatalf("unexpected errors: %v", unexpected)
+	}
+	if ok != stock || sold != users-stock {
+		t.Fatalf("got %d redeemed and %d out of stock, want %d and %d", ok, sold, stock, users-stock)
+	}
+	var quantity, redeemed int
+	if err := db.QueryRow(`SELECT quantity,total_redeemed FROM redemption_rewards WHERE id=?`, reward.ID).Scan(&quantity, &redeemed); err != nil {
+		t.Fatal(err)
+	}
+	if quantity != 0 || redeemed != stock {
+		t.Fatalf("got quantity %d and total_redeemed %d, want 0 and %d", quantity, redeemed, stock)
+	}
+	var debits, debited, records int
+	if err := db.QueryRow(`SELECT COUNT(1), COALESCE(SUM(amount),0) FROM transactions WHERE point_type_id=? AND type='debit'`, ptID).Scan(&debits, &debited); err != nil {
+		t.Fatal(err)
+	}
+	if debits != stock || debited != stock*cost {
+		t.Fatalf("got %d debits of %d points, want %d of %d", debits, debited, stock, stock*cost)
+	}
+	if err := db.QueryRow(`SELECT COUNT(1) FROM redemption_records WHERE reward_id=?`, reward.ID).Scan(&records); err != nil {
+		t.Fatal(err)
+	}
+	if records != stock {
+		t.Fatalf("got %d redemption records, want %d", records, stock)
+	}
+	var balances int
+	if err := db.QueryRow(`SELECT COALESCE(SUM(balance),0) FROM user_balances WHERE point_type_id=?`, ptID).Scan(&balances); err != nil {
+		t.Fatal(err)
+	}
+	if want := users*100 - stock*cost; balances != want {
+		t.Fatalf("got %d points left, want %d", balances, want)
+	}
+}
@@ -15,26 +15,52 @@ func NewRewardsRepository(db *sql.DB) *RewardsRepository { return &RewardsReposi
 
 var _ uc.RewardRepository = (*RewardsRepository)(nil)
 
+const ruleColumns = `id,point_type_id,mode,min_rank,max_rank,percentile_from,percentile_to,min_score,reward_amount,share_bps,reward_point_type_id,active,starts_at,ends_at`
+
 func (r *RewardsRepository) CreateRule(ctx context.Context, rr d.RewardRule) (string, error) {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO reward_rules (point_type_id,min_rank,max_rank,reward_amount,reward_point_type_id,active) VALUES (?,?,?,?,?,?)`, rr.PointTypeID, rr.MinRank, rr.MaxRank, rr.RewardAmount, rr.RewardPointTypeID, rr.Active)
+	_, err := getTx(ctx, r.db).ExecContext(ctx, `INSERT INTO reward_rules (`+ruleColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		rr.ID, rr.PointTypeID, string(rr.Mode), rr.MinRank, rr.MaxRank, rr.PercentileFrom, rr.PercentileTo, rr.MinScore, rr.RewardAmount, rr.ShareBps, rr.RewardPointTypeID, rr.Active, rr.StartsAt, rr.EndsAt)
 	if err != nil {
 		return "", err
 	}
-	var id string
-	_ = r.db.QueryRowContext(ctx, `SELECT id FROM reward_rules WHERE point_type_id=? ORDER BY id DESC LIMIT 1`, rr.PointTypeID).Scan(&id)
-	return id, nil
+	return rr.ID, nil
+}
+
+func (r *RewardsRepository) GetRule(ctx context.Context, pointTypeID int64, id string) (*d.RewardRule, error) {
+	rules, err := r.listRules(ctx, getTx(ctx, r.db), `SELECT `+ruleColumns+` FROM reward_rules WHERE point_type_id=? AND id=?`, pointTypeID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, d.ErrRewardRuleNotFound
+	}
+	return &rules[0], nil
 }
 
 func (r *RewardsRepository) ListRules(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id,point_type_id,min_rank,max_rank,reward_amount,reward_point_type_id,active FROM reward_rules WHERE point_type_id=? AND active=1`, pointTypeID)
+	return r.listRules(ctx, r.db, `SELECT `+ruleColumns+` FROM reward_rules WHERE point_type_id=? AND active=1 ORDER BY min_rank`, pointTypeID)
+}
+
+func (r *RewardsRepository) ListAllRules(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error) {
+	return r.listRules(ctx, r.db, `SELECT `+ruleColumns+` FROM reward_rules WHERE point_type_id=? ORDER BY min_rank, id`, pointTypeID)
+}
+
+// ListRulesForUpdate also locks the index range of the point type, so no
+// other transaction can add a rule to it until this one ends.
+func (r *RewardsRepository) ListRulesForUpdate(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error) {
+	return r.listRules(ctx, getTx(ctx, r.db), `SELECT `+ruleColumns+` FROM reward_rules WHERE point_type_id=? FOR UPDATE`, pointTypeID)
+}
+
+func (r *RewardsRepository) listRules(ctx context.Context, q executor, query string, args ...any) ([]d.RewardRule, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var res []d.RewardRule
+	res := []d.RewardRule{}
 	for rows.Next() {
 		var rr d.RewardRule
-		if err := rows.Scan(&rr.ID, &rr.PointTypeID, &rr.MinRank, &rr.MaxRank, &rr.RewardAmount, &rr.RewardPointTypeID, &rr.Active); err != nil {
+		if err := rows.Scan(&rr.ID, &rr.PointTypeID, &rr.Mode, &rr.MinRank, &rr.MaxRank, &rr.PercentileFrom, &rr.PercentileTo, &rr.MinScore, &rr.RewardAmount, &rr.ShareBps, &rr.RewardPointTypeID, &rr.Active, &rr.StartsAt, &rr.EndsAt); err != nil {
 			return nil, err
 		}
 		res = append(res, rr)
@@ -42,17 +68,177 @@ func (r *RewardsRepository) ListRules(ctx context.Context, pointTypeID int64) ([
 	return res, rows.Err()
 }
 
+func (r *RewardsRepository) UpdateRule(ctx context.Context, rr d.RewardRule) error {
+	res, err := getTx(ctx, r.db).ExecContext(ctx, `UPDATE reward_rules SET mode=?, min_rank=?, max_rank=?, percentile_from=?, percentile_to=?, min_score=?, reward_amount=?, share_bps=?, reward_point_type_id=?, active=?, starts_at=?, ends_at=? WHERE point_type_id=? AND id=?`,
+		string(rr.Mode), rr.MinRank, rr.MaxRank, rr.PercentileFrom, rr.PercentileTo, rr.MinScore, rr.RewardAmount, rr.ShareBps, rr.RewardPointTypeID, rr.Active, rr.StartsAt, rr.EndsAt, rr.PointTypeID, rr.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// an update writing the same values also reports no rows
+		_, err = r.GetRule(ctx, rr.PointTypeID, rr.ID)
+	}
+	return err
+}
+
+func (r *RewardsRepository) DeleteRule(ctx context.Context, pointTypeID int64, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM reward_rules WHERE point_type_id=? AND id=?`, pointTypeID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return d.ErrRewardRuleNotFound
+	}
+	return nil
+}
+
+func (r *RewardsRepository) ListRewardPools(ctx context.Context, pointTypeID int64) ([]d.RewardPool, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT point_type_id,reward_point_type_id,balance,updated_at FROM reward_pools WHERE point_type_id=? ORDER BY reward_point_type_id`, pointTypeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []d.RewardPool{}
+	for rows.Next() {
+		var p d.RewardPool
+		if err := rows.Scan(&p.PointTypeID, &p.RewardPointTypeID, &p.Balance, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// LockRewardPool returns an empty pool when none was funded yet.
+func (r *RewardsRepository) LockRewardPool(ctx context.Context, pointTypeID, rewardPointTypeID int64) (*d.RewardPool, error) {
+	p := d.RewardPool{PointTypeID: pointTypeID, RewardPointTypeID: rewardPointTypeID}
+	err := getTx(ctx, r.db).QueryRowContext(ctx, `SELECT balance,updated_at FROM reward_pools WHERE point_type_id=? AND reward_point_type_id=? FOR UPDATE`, pointTypeID, rewardPointTypeID).Scan(&p.Balance, &p.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *RewardsRepository) AddToRewardPool(ctx context.Context, pointTypeID, rewardPointTypeID, delta int64) error {
+	now := time.Now().Unix()
+	_, err := getTx(ctx, r.db).ExecContext(ctx, `INSERT INTO reward_pools (point_type_id,reward_point_type_id,balance,updated_at) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE balance=balance+VALUES(balance), updated_at=VALUES(updated_at)`, pointTypeID, rewardPointTypeID, delta, now)
+	return err
+}
+
+// CreateDistribution fails with ErrDistributionAlreadyDone when the point
+// type was already distributed for the schedule and period key.
 func (r *RewardsRepository) CreateDistribution(ctx context.Context, rd d.RewardDistribution) (string, error) {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO reward_distributions (snapshot_id,status) VALUES (?,?)`, rd.SnapshotID, rd.Status)
+	_, err := getTx(ctx, r.db).ExecContext(ctx, `INSERT INTO reward_distributions (id,point_type_id,schedule_id,period_key,snapshot_id,status) VALUES (?,?,?,?,?,?)`, rd.ID, rd.PointTypeID, rd.ScheduleID, nullString(rd.PeriodKey), rd.SnapshotID, rd.Status)
+	if isDuplicateKey(err) {
+		return "", d.ErrDistributionAlreadyDone
+	}
 	if err != nil {
 		return "", err
 	}
-	var id string
-	_ = r.db.QueryRowContext(ctx, `SELECT id FROM reward_distributions ORDER BY id DESC LIMIT 1`).Scan(&id)
-	return id, nil
+	return rd.ID, nil
+}
+
+const distributionColumns = `id,point_type_id,schedule_id,COALESCE(period_key,''),snapshot_id,COALESCE(executed_at,0),status`
+
+func scanDistribution(sc scanner) (*d.RewardDistribution, error) {
+	var rd d.RewardDistribution
+	err := sc.Scan(&rd.ID, &rd.PointTypeID, &rd.ScheduleID, &rd.PeriodKey, &rd.SnapshotID, &rd.ExecutedAt, &rd.Status)
+	if err == sql.ErrNoRows {
+		return nil, d.ErrDistributionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rd, nil
+}
+
+func (r *RewardsRepository) GetDistribution(ctx context.Context, id string) (*d.RewardDistribution, error) {
+	return scanDistribution(r.db.QueryRowContext(ctx, `SELECT `+distributionColumns+` FROM reward_distributions WHERE id=?`, id))
+}
+
+func (r *RewardsRepository) GetDistributionByPeriod(ctx context.Context, pointTypeID, scheduleID int64, periodKey string) (*d.RewardDistribution, error) {
+	return scanDistribution(r.db.QueryRowContext(ctx, `SELECT `+distributionColumns+` FROM reward_distributions WHERE point_type_id=? AND schedule_id=? AND period_key=?`, pointTypeID, scheduleID, periodKey))
 }
 
 func (r *RewardsRepository) MarkDistributionCompleted(ctx context.Context, distributionID string) error {
 	_, err := r.db.ExecContext(ctx, `UPDATE reward_distributions SET status='completed', executed_at=? WHERE id=?`, time.Now().Unix(), distributionID)
 	return err
 }
+
+func (r *RewardsRepository) MarkDistributionFailed(ctx context.Context, distributionID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE reward_distributions SET status='failed', executed_at=? WHERE id=?`, time.Now().Unix(), distributionID)
+	return err
+}
+
+// distributionItemBatch is the number of items inserted per statement.
+const distributionItemBatch = 1000
+
+const distributionItemColumns = `id,distribution_id,position,user_id,rule_id,reward_point_type_id,amount,status,COALESCE(transaction_id,''),COALESCE(error,''),updated_at`
+
+func scanDistributionItem(sc scanner) (d.RewardDistributionItem, error) {
+	var it d.RewardDistributionItem
+	err := sc.Scan(&it.ID, &it.DistributionID, &it.Position, &it.UserID, &it.RuleID, &it.RewardPointTypeID, &it.Amount, &it.Status, &it.TransactionID, &it.Error, &it.UpdatedAt)
+	return it, err
+}
+
+func (r *RewardsRepository) CreateDistributionItems(ctx context.Context, items []d.RewardDistributionItem) error {
+	now := time.Now().Unix()
+	for start := 0; start < len(items); start += distributionItemBatch {
+		batch := items[start:min(start+distributionItemBatch, len(items))]
+		args := make([]any, 0, len(batch)*7)
+		for _, it := range batch {
+			args = append(args, it.DistributionID, it.Position, it.UserID, it.RuleID, it.RewardPointTypeID, it.Amount, now)
+		}
+		if _, err := getTx(ctx, r.db).ExecContext(ctx, `INSERT INTO reward_distribution_items (distribution_id,position,user_id,rule_id,reward_point_type_id,amount,updated_at) VALUES `+placeholders("(?,?,?,?,?,?,?)", len(batch)), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RewardsRepository) ListDistributionItems(ctx context.Context, distributionID string, statuses ...d.DistributionItemStatus) ([]d.RewardDistributionItem, error) {
+	query := `SELECT ` + distributionItemColumns + ` FROM reward_distribution_items WHERE distribution_id=?`
+	args := []any{distributionID}
+	if len(statuses) > 0 {
+		query += ` AND status IN (` + placeholders("?", len(statuses)) + `)`
+		for _, st := range statuses {
+			args = append(args, string(st))
+		}
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []d.RewardDistributionItem{}
+	for rows.Next() {
+		it, err := scanDistributionItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, it)
+	}
+	return res, rows.Err()
+}
+
+func (r *RewardsRepository) LockDistributionItem(ctx context.Context, id int64) (*d.RewardDistributionItem, error) {
+	it, err := scanDistributionItem(getTx(ctx, r.db).QueryRowContext(ctx, `SELECT `+distributionItemColumns+` FROM reward_distribution_items WHERE id=? FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		return nil, d.ErrDistributionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+func (r *RewardsRepository) MarkDistributionItemPaid(ctx context.Context, id int64, transactionID string) error {
+	_, err := getTx(ctx, r.db).ExecContext(ctx, `UPDATE reward_distribution_items SET status='paid', transaction_id=?, error=NULL, updated_at=? WHERE id=?`, transactionID, time.Now().Unix(), id)
+	return err
+}
+
+// MarkDistributionItemFailed leaves paid items untouched.
+func (r *RewardsRepository) MarkDistributionItemFailed(ctx context.Context, id int64, reason string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE reward_distribution_items SET status='failed', error=?, updated_at=? WHERE id=? AND status<>'paid'`, reason, time.Now().Unix(), id)
+	return err
+}
@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+type SeasonRepository struct{ db *sql.DB }
+
+func NewSeasonRepository(db *sql.DB) *SeasonRepository { return &SeasonRepository{db: db} }
+
+var _ uc.SeasonRepository = (*SeasonRepository)(nil)
+
+func (r *SeasonRepository) CreateSeason(ctx context.Context, s d.RankingSeason) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO ranking_seasons (name,starts_at,ends_at,created_at) VALUES (?,?,?,?)`, s.Name, s.StartsAt, s.EndsAt, s.CreatedAt)
+	if isDuplicateKey(err) {
+		return d.ErrInvalidSeason
+	}
+	return err
+}
+
+func (r *SeasonRepository) GetSeason(ctx context.Context, name string) (*d.RankingSeason, error) {
+	var s d.RankingSeason
+	err := r.db.QueryRowContext(ctx, `SELECT name,starts_at,ends_at,created_at FROM ranking_seasons WHERE name=?`, name).Scan(&s.Name, &s.StartsAt, &s.EndsAt, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, d.ErrSeasonNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SeasonRepository) ListSeasons(ctx context.Context) ([]d.RankingSeason, error) {
+	return r.list(ctx, `SELECT name,starts_at,ends_at,created_at FROM ranking_seasons ORDER BY starts_at DESC`)
+}
+
+func (r *SeasonRepository) ListUnfinishedSeasons(ctx context.Context, now int64) ([]d.RankingSeason, error) {
+	return r.list(ctx, `SELECT name,starts_at,ends_at,created_at FROM ranking_seasons WHERE ends_at>? ORDER BY starts_at`, now)
+}
+
+func (r *SeasonRepository) list(ctx context.Context, query string, args ...any) ([]d.RankingSeason, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []d.RankingSeason{}
+	for rows.Next() {
+		var s d.RankingSeason
+		if err := rows.Scan(&s.Name, &s.StartsAt, &s.EndsAt, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	d "github.com/usual2970/acto/domain/points"
+	uc "github.com/usual2970/acto/points"
+)
+
+type SnapshotRepository struct{ db *sql.DB }
+
+func NewSnapshotRepository(db *sql.DB) *SnapshotRepository { return &SnapshotRepository{db: db} }
+
+var _ uc.SnapshotRepository = (*SnapshotRepository)(nil)
+
+// snapshotInsertBatch is the number of entries inserted per statement.
+const snapshotInsertBatch = 1000
+
+const snapshotColumns = `id,point_type_id,period,window_key,tie_policy,top_n,entries,participants,created_at`
+
+func scanSnapshot(sc scanner) (d.RankingSnapshot, error) {
+	var s d.RankingSnapshot
+	err := sc.Scan(&s.ID, &s.PointTypeID, &s.Window.Period, &s.Window.Key, &s.TiePolicy, &s.TopN, &s.Entries, &s.Participants, &s.CreatedAt)
+	return s, err
+}
+
+func (r *SnapshotRepository) CreateSnapshot(ctx context.Context, s d.RankingSnapshot, entries []d.RankingEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO ranking_snapshots (`+snapshotColumns+`) VALUES (?,?,?,?,?,?,?,?,?)`,
+		s.ID, s.PointTypeID, string(s.Window.Period), s.Window.Key, string(s.TiePolicy), s.TopN, len(entries), s.Participants, s.CreatedAt); err != nil {
+		return err
+	}
+	for start := 0; start < len(entries); start += snapshotInsertBatch {
+		batch := entries[start:min(start+snapshotInsertBatch, len(entries))]
+		args := make([]any, 0, len(batch)*5)
+		for i, e := range batch {
+			args = append(args, s.ID, start+i, e.Rank, e.UserID, e.Score)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO ranking_snapshot_entries (snapshot_id,position,rank_no,user_id,score) VALUES `+placeholders("(?,?,?,?,?)", len(batch)), args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SnapshotRepository) GetSnapshot(ctx context.Context, id string) (*d.RankingSnapshot, error) {
+	s, err := scanSnapshot(r.db.QueryRowContext(ctx, `SELECT `+snapshotColumns+` FROM ranking_snapshots WHERE id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, d.ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SnapshotRepository) ListSnapshots(ctx context.Context, pointTypeID int64, limit, offset int) ([]d.RankingSnapshot, int, error) {
+	where := ``
+	var args []any
+	if pointTypeID != 0 {
+		where = ` WHERE point_type_id=?`
+		args = append(args, pointTypeID)
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM ranking_snapshots`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+snapshotColumns+` FROM ranking_snapshots`+where+` ORDER BY created_at DESC, id LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	res := []d.RankingSnapshot{}
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, s)
+	}
+	return res, total, rows.Err()
+}
+
+func (r *SnapshotRepository) ListSnapshotEntries(ctx context.Context, snapshotID, userID string, limit, offset int) ([]d.RankingEntry, error) {
+	query := `SELECT rank_no,user_id,score FROM ranking_snapshot_entries WHERE snapshot_id=?`
+	args := []any{snapshotID}
+	if userID != "" {
+		query += ` AND user_id=?`
+		args = append(args, userID)
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY position LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []d.RankingEntry{}
+	for rows.Next() {
+		var e d.RankingEntry
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Score); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	uc "github.com/usual2970/acto/points"
+
+	goRedis "github.com/redis/go-redis/v9"
+)
+
+// Locker implements points.Locker with SET NX and a random token, so a holder
+// whose lock expired cannot release the next holder's lock.
+type Locker struct {
+	client *goRedis.Client
+}
+
+func NewLocker(client *goRedis.Client) *Locker { return &Locker{client: client} }
+
+var _ uc.Locker = (*Locker)(nil)
+
+// unlockScript deletes the lock only while it still holds our token.
+var unlockScript = goRedis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+func (l *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	k := "lock:" + name
+	token := newToken()
+	ok, err := l.client.SetNX(ctx, k, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func() {
+		_ = unlockScript.Run(context.WithoutCancel(ctx), l.client, []string{k}, token).Err()
+	}, true, nil
+}
+
+func newToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
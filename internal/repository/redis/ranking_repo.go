@@ -3,7 +3,10 @@ package redis
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
+	d "github.com/usual2970/acto/domain/points"
 	uc "github.com/usual2970/acto/points"
 
 	goRedis "github.com/redis/go-redis/v9"
@@ -21,14 +24,222 @@ var _ uc.RankingRepository = (*RankingRepository)(nil)
 
 func key(pointTypeID int64) string { return "ranking:" + fmt.Sprint(pointTypeID) }
 
+// windowKey is the all-time key for the zero window, and
+// ranking:<id>:<period>:<key> otherwise.
+func windowKey(pointTypeID int64, w d.RankingWindow) string {
+	if w.Period == d.PeriodAllTime {
+		return key(pointTypeID)
+	}
+	return key(pointTypeID) + ":" + string(w.Period) + ":" + w.Key
+}
+
 func (r *RankingRepository) UpdateUserScore(ctx context.Context, pointTypeID int64, userID string, score int64) error {
 	return r.client.ZAdd(ctx, key(pointTypeID), goRedis.Z{Member: userID, Score: float64(score)}).Err()
 }
 
-func (r *RankingRepository) GetTop(ctx context.Context, pointTypeID int64, start, stop int64) ([]string, error) {
-	vals, err := r.client.ZRevRange(ctx, key(pointTypeID), start, stop).Result()
+func (r *RankingRepository) GetTop(ctx context.Context, pointTypeID int64, w d.RankingWindow, start, stop int64) ([]d.RankingEntry, error) {
+	vals, err := r.client.ZRevRangeWithScores(ctx, windowKey(pointTypeID, w), start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]d.RankingEntry, len(vals))
+	for i, z := range vals {
+		res[i] = d.RankingEntry{UserID: fmt.Sprint(z.Member), Score: int64(z.Score)}
+	}
+	return res, nil
+}
+
+func (r *RankingRepository) Count(ctx context.Context, pointTypeID int64, w d.RankingWindow) (int64, error) {
+	return r.client.ZCard(ctx, windowKey(pointTypeID, w)).Result()
+}
+
+// GetUserPosition reads ZREVRANK and ZSCORE in one pipeline.
+func (r *RankingRepository) GetUserPosition(ctx context.Context, pointTypeID int64, w d.RankingWindow, userID string) (int64, int64, error) {
+	var rank *goRedis.IntCmd
+	var score *goRedis.FloatCmd
+	k := windowKey(pointTypeID, w)
+	_, err := r.client.Pipelined(ctx, func(p goRedis.Pipeliner) error {
+		rank = p.ZRevRank(ctx, k, userID)
+		score = p.ZScore(ctx, k, userID)
+		return nil
+	})
+	if err == goRedis.Nil {
+		return 0, 0, d.ErrUserNotRanked
+	}
 	if err != nil {
+		return 0, 0, err
+	}
+	return rank.Val(), int64(score.Val()), nil
+}
+
+func (r *RankingRepository) CountAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error) {
+	return r.client.ZCount(ctx, windowKey(pointTypeID, w), "("+strconv.FormatInt(score, 10), "+inf").Result()
+}
+
+// IncrementWindowScores sends a ZINCRBY and an EXPIREAT per increment in one
+// pipeline, so each window key is removed once its retention has passed.
+func (r *RankingRepository) IncrementWindowScores(ctx context.Context, incs []uc.WindowIncrement) error {
+	if len(incs) == 0 {
+		return nil
+	}
+	_, err := r.client.Pipelined(ctx, func(p goRedis.Pipeliner) error {
+		for _, inc := range incs {
+			k := windowKey(inc.PointTypeID, inc.Window)
+			p.ZIncrBy(ctx, k, float64(inc.Amount), inc.UserID)
+			p.ExpireAt(ctx, k, time.Unix(inc.ExpireAt, 0))
+		}
+		return nil
+	})
+	return err
+}
+
+// UpdateUserScores writes all updates with one ZADD per point type, sent in a
+// single pipeline.
+func (r *RankingRepository) UpdateUserScores(ctx context.Context, updates []uc.ScoreUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	byKey := map[string][]goRedis.Z{}
+	for _, u := range updates {
+		k := key(u.PointTypeID)
+		byKey[k] = append(byKey[k], goRedis.Z{Member: u.UserID, Score: float64(u.Score)})
+	}
+	_, err := r.client.Pipelined(ctx, func(p goRedis.Pipeliner) error {
+		for k, members := range byKey {
+			p.ZAdd(ctx, k, members...)
+		}
+		return nil
+	})
+	return err
+}
+
+// IncrementUserScores sends one ZINCRBY per update in a single pipeline.
+func (r *RankingRepository) IncrementUserScores(ctx context.Context, updates []uc.ScoreUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	_, err := r.client.Pipelined(ctx, func(p goRedis.Pipeliner) error {
+		for _, u := range updates {
+			p.ZIncrBy(ctx, key(u.PointTypeID), float64(u.Score), u.UserID)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetUserScores looks up the users' scores with one pipelined ZSCORE each.
+func (r *RankingRepository) GetUserScores(ctx context.Context, pointTypeID int64, userIDs []string) (map[string]int64, error) {
+	cmds := make([]*goRedis.FloatCmd, len(userIDs))
+	_, err := r.client.Pipelined(ctx, func(p goRedis.Pipeliner) error {
+		for i, u := range userIDs {
+			cmds[i] = p.ZScore(ctx, key(pointTypeID), u)
+		}
+		return nil
+	})
+	if err != nil && err != goRedis.Nil {
 		return nil, err
 	}
-	return vals, nil
+	res := make(map[string]int64, len(userIDs))
+	for i, cmd := range cmds {
+		score, err := cmd.Result()
+		if err == goRedis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res[userIDs[i]] = int64(score)
+	}
+	return res, nil
+}
+
+// ScanUserScores walks the ranking with ZSCAN so large sets are not loaded at once.
+func (r *RankingRepository) ScanUserScores(ctx context.Context, pointTypeID int64, fn func(userID string, score int64) error) error {
+	var cursor uint64
+	for {
+		vals, next, err := r.client.ZScan(ctx, key(pointTypeID), cursor, "", 500).Result()
+		if err != nil {
+			return err
+		}
+		// ZSCAN replies with alternating members and scores
+		for i := 0; i+1 < len(vals); i += 2 {
+			score, err := strconv.ParseFloat(vals[i+1], 64)
+			if err != nil {
+				return err
+			}
+			if err := fn(vals[i], int64(score)); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (r *RankingRepository) RemoveUsers(ctx context.Context, pointTypeID int64, userIDs ...string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	members := make([]any, len(userIDs))
+	for i, u := range userIDs {
+		members[i] = u
+	}
+	return r.client.ZRem(ctx, key(pointTypeID), members...).Err()
+}
+
+const (
+	// rebuildBatchSize is the number of members sent per pipelined ZADD.
+	rebuildBatchSize = 1000
+	// rebuildTTL bounds the lifetime of a temporary key left by a failed rebuild.
+	rebuildTTL = time.Hour
+)
+
+// Rebuild fills a temporary key in pipelined batches and renames it over the
+// live key, so readers switch from the old to the new ranking atomically.
+// Scores written to the live key while the rebuild runs are overwritten.
+func (r *RankingRepository) Rebuild(ctx context.Context, pointTypeID int64, fill func(add func(userID string, score int64) error) error) (int64, error) {
+	live := key(pointTypeID)
+	tmp := fmt.Sprintf("%s:rebuild:%d", live, time.Now().UnixNano())
+	var count int64
+	batch := make([]goRedis.Z, 0, rebuildBatchSize)
+	flush := func() error {
+		_, err := r.client.Pipelined(ctx, func(p goRedis.Pipeliner) error {
+			p.ZAdd(ctx, tmp, batch...)
+			p.Expire(ctx, tmp, rebuildTTL)
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+	err := fill(func(userID string, score int64) error {
+		batch = append(batch, goRedis.Z{Member: userID, Score: float64(score)})
+		count++
+		if len(batch) == rebuildBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		_ = r.client.Del(context.WithoutCancel(ctx), tmp).Err()
+		return 0, err
+	}
+	if count == 0 {
+		return 0, r.client.Del(ctx, live).Err()
+	}
+	// RENAME keeps the TTL of the temporary key, so drop it in the same step
+	_, err = r.client.TxPipelined(ctx, func(p goRedis.Pipeliner) error {
+		p.Rename(ctx, tmp, live)
+		p.Persist(ctx, live)
+		return nil
+	})
+	return count, err
+}
+
+func (r *RankingRepository) Exists(ctx context.Context, pointTypeID int64) (bool, error) {
+	n, err := r.client.Exists(ctx, key(pointTypeID)).Result()
+	return n > 0, err
 }
@@ -3,8 +3,10 @@ package admin
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
 	uc "github.com/usual2970/acto/points"
 )
 
@@ -20,7 +22,8 @@ func (h *DistributionsHandler) Execute(w http.ResponseWriter, r *http.Request) {
 		handlers.WriteError(w, 1000, "bad request")
 		return
 	}
-	if req.TopN <= 0 {
+	// a snapshot is rewarded in full unless topN is given
+	if req.TopN <= 0 && req.SnapshotID == "" {
 		req.TopN = 100
 	}
 	if err := h.svc.Execute(r.Context(), req); err != nil {
@@ -29,3 +32,103 @@ func (h *DistributionsHandler) Execute(w http.ResponseWriter, r *http.Request) {
 	}
 	handlers.WriteSuccess(w, nil)
 }
+
+// Preview returns the plan Execute would pay for the same request.
+func (h *DistributionsHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var req uc.DistirbutionsExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	if req.TopN <= 0 && req.SnapshotID == "" {
+		req.TopN = 100
+	}
+	plan, err := h.svc.Preview(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, plan)
+}
+
+// Get returns a distribution with the payout state of each recipient.
+func (h *DistributionsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	view, err := h.svc.Get(r.Context(), actoHttp.GetPathVars(r)["distributionId"])
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, view)
+}
+
+// Retry pays the recipients a distribution has not paid yet.
+func (h *DistributionsHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.Retry(r.Context(), actoHttp.GetPathVars(r)["distributionId"]); err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, nil)
+}
+
+type DistributionSchedulesHandler struct {
+	svc *uc.DistributionScheduleService
+}
+
+func NewDistributionSchedulesHandler(svc *uc.DistributionScheduleService) *DistributionSchedulesHandler {
+	return &DistributionSchedulesHandler{svc: svc}
+}
+
+func (h *DistributionSchedulesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req uc.DistributionScheduleCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	sch, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, sch)
+}
+
+func (h *DistributionSchedulesHandler) List(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svc.List(r.Context())
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, map[string]any{"items": items})
+}
+
+func (h *DistributionSchedulesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(actoHttp.GetPathVars(r)["scheduleId"], 10, 64)
+	if err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	var req uc.DistributionScheduleUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	sch, err := h.svc.Update(r.Context(), id, req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, sch)
+}
+
+func (h *DistributionSchedulesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(actoHttp.GetPathVars(r)["scheduleId"], 10, 64)
+	if err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, nil)
+}
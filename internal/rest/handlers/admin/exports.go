@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/usual2970/acto/internal/rest/handlers"
+	uc "github.com/usual2970/acto/points"
+)
+
+type ExportsHandler struct{ svc *uc.ExportService }
+
+func NewExportsHandler(svc *uc.ExportService) *ExportsHandler { return &ExportsHandler{svc: svc} }
+
+// Transactions streams the ledger as a file download. It takes the same
+// filters as the transaction list plus an optional userId; ?format= is csv
+// (default) or jsonl.
+func (h *ExportsHandler) Transactions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	startTime, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+	endTime, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+	req := uc.ExportTransactionsRequest{
+		Format:    exportFormat(r),
+		UserID:    q.Get("userId"),
+		URI:       q.Get("pointTypeName"),
+		Op:        q.Get("op"),
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+	open, started := download(w, "transactions", req.Format)
+	if err := h.svc.ExportTransactions(r.Context(), req, open); err != nil && !*started {
+		handlers.WriteDomainError(w, err)
+	}
+}
+
+// Balances streams user balances as a file download, optionally limited to
+// one point type with ?pointTypeName=.
+func (h *ExportsHandler) Balances(w http.ResponseWriter, r *http.Request) {
+	req := uc.ExportBalancesRequest{Format: exportFormat(r), URI: r.URL.Query().Get("pointTypeName")}
+	open, started := download(w, "balances", req.Format)
+	if err := h.svc.ExportBalances(r.Context(), req, open); err != nil && !*started {
+		handlers.WriteDomainError(w, err)
+	}
+}
+
+func exportFormat(r *http.Request) uc.ExportFormat {
+	format := uc.ExportFormat(strings.ToLower(r.URL.Query().Get("format")))
+	if format == "" {
+		return uc.ExportCSV
+	}
+	return format
+}
+
+// download returns the writer factory passed to the export service and
+// reports whether the response has been started. Once it has, errors can no
+// longer be sent as a JSON envelope and the client sees a truncated file.
+func download(w http.ResponseWriter, name string, format uc.ExportFormat) (func() io.Writer, *bool) {
+	started := new(bool)
+	return func() io.Writer {
+		contentType := "text/csv; charset=utf-8"
+		if format == uc.ExportJSONL {
+			contentType = "application/x-ndjson"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+string(format)+`"`)
+		w.WriteHeader(http.StatusOK)
+		*started = true
+		return w
+	}, started
+}
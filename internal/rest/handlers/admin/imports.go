@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	d "github.com/usual2970/acto/domain/points"
+	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
+	uc "github.com/usual2970/acto/points"
+)
+
+type ImportsHandler struct{ svc *uc.ImportService }
+
+func NewImportsHandler(svc *uc.ImportService) *ImportsHandler { return &ImportsHandler{svc: svc} }
+
+// Create accepts the file either as the raw request body or as the "file"
+// field of a multipart form. The format comes from ?format= or the file name.
+func (h *ImportsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	format := d.ImportFormat(strings.ToLower(r.URL.Query().Get("format")))
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			handlers.WriteError(w, 1000, "missing file")
+			return
+		}
+		defer f.Close()
+		body = f
+		if format == "" {
+			format = d.ImportFormat(strings.TrimPrefix(strings.ToLower(path.Ext(fh.Filename)), "."))
+		}
+	}
+	job, err := h.svc.Start(r.Context(), format, body)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, job)
+}
+
+func (h *ImportsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	jobID := actoHttp.GetPathVars(r)["jobId"]
+	job, err := h.svc.GetJob(r.Context(), jobID)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, job)
+}
+
+func (h *ImportsHandler) ListErrors(w http.ResponseWriter, r *http.Request) {
+	jobID := actoHttp.GetPathVars(r)["jobId"]
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	items, total, err := h.svc.ListErrors(r.Context(), jobID, limit, offset)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, map[string]any{"items": items, "total": total, "limit": limit, "offset": offset})
+}
@@ -1,10 +1,15 @@
 package admin
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
+	d "github.com/usual2970/acto/domain/points"
 	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
 	uc "github.com/usual2970/acto/points"
 )
 
@@ -20,10 +25,110 @@ func (h *RankingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	ptName := r.URL.Query().Get("pointTypeName")
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	users, err := h.svc.GetTop(r.Context(), ptName, limit, offset)
+	tie := d.TiePolicy(r.URL.Query().Get("tiePolicy"))
+	page, err := h.svc.GetTop(r.Context(), ptName, rankingWindow(r), limit, offset, tie)
 	if err != nil {
 		handlers.WriteDomainError(w, err)
 		return
 	}
-	handlers.WriteSuccess(w, map[string]any{"items": users, "limit": limit, "offset": offset})
+	handlers.WriteSuccess(w, page)
+}
+
+type RankingRebuildHandler struct{ svc *uc.RankingRebuildService }
+
+func NewRankingRebuildHandler(svc *uc.RankingRebuildService) *RankingRebuildHandler {
+	return &RankingRebuildHandler{svc: svc}
+}
+
+// Rebuild repopulates rankings from MySQL; an empty body rebuilds all of them.
+func (h *RankingRebuildHandler) Rebuild(w http.ResponseWriter, r *http.Request) {
+	var req uc.RankingRebuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	res, err := h.svc.Rebuild(r.Context(), req.URI)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, map[string]any{"items": res})
+}
+
+// rankingWindow reads ?window= (daily, weekly, monthly, season or all) and
+// ?windowKey=; no window selects the all-time ranking.
+func rankingWindow(r *http.Request) d.RankingWindow {
+	return d.RankingWindow{Period: d.RankingPeriod(r.URL.Query().Get("window")), Key: r.URL.Query().Get("windowKey")}
+}
+
+type SeasonsHandler struct{ svc *uc.SeasonService }
+
+func NewSeasonsHandler(svc *uc.SeasonService) *SeasonsHandler { return &SeasonsHandler{svc: svc} }
+
+func (h *SeasonsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req uc.RankingSeasonCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	season, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, season)
+}
+
+func (h *SeasonsHandler) List(w http.ResponseWriter, r *http.Request) {
+	seasons, err := h.svc.List(r.Context())
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, map[string]any{"items": seasons})
+}
+
+type RankingSnapshotsHandler struct{ svc *uc.RankingSnapshotService }
+
+func NewRankingSnapshotsHandler(svc *uc.RankingSnapshotService) *RankingSnapshotsHandler {
+	return &RankingSnapshotsHandler{svc: svc}
+}
+
+func (h *RankingSnapshotsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req uc.RankingSnapshotCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	snap, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, snap)
+}
+
+// List returns snapshots newest first, filtered by ?pointTypeName=.
+func (h *RankingSnapshotsHandler) List(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	page, err := h.svc.List(r.Context(), r.URL.Query().Get("pointTypeName"), limit, offset)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, page)
+}
+
+// Get returns a snapshot with a page of its entries; ?userId= selects one user.
+func (h *RankingSnapshotsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := actoHttp.GetPathVars(r)["snapshotId"]
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	view, err := h.svc.Get(r.Context(), id, r.URL.Query().Get("userId"), limit, offset)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, view)
 }
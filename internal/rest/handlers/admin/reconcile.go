@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/usual2970/acto/internal/rest/handlers"
+	uc "github.com/usual2970/acto/points"
+)
+
+type ReconcileHandler struct{ svc *uc.ReconcileService }
+
+func NewReconcileHandler(svc *uc.ReconcileService) *ReconcileHandler {
+	return &ReconcileHandler{svc: svc}
+}
+
+// Run reconciles synchronously and returns the report. An empty body checks
+// every point type without fixing anything.
+func (h *ReconcileHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req uc.ReconcileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	rep, err := h.svc.Reconcile(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, rep)
+}
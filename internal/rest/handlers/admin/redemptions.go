@@ -1,10 +1,14 @@
 package admin
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	d "github.com/usual2970/acto/domain/points"
 	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
 	uc "github.com/usual2970/acto/points"
 )
 
@@ -24,9 +28,58 @@ func (h *RedemptionsHandler) Redeem(w http.ResponseWriter, r *http.Request) {
 		handlers.WriteError(w, 1000, "missing userId or rewardId")
 		return
 	}
-	if err := h.svc.Redeem(r.Context(), req); err != nil {
+	rec, err := h.svc.Redeem(r.Context(), req)
+	if err != nil {
 		handlers.WriteDomainError(w, err)
 		return
 	}
-	handlers.WriteSuccess(w, nil)
+	handlers.WriteSuccess(w, rec)
+}
+
+// List filters orders by ?userId=, ?rewardId= and ?status=.
+func (h *RedemptionsHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := uc.RedemptionFilter{UserID: q.Get("userId"), RewardID: q.Get("rewardId"), Status: d.RedemptionStatus(q.Get("status"))}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	f.Offset, _ = strconv.Atoi(q.Get("offset"))
+	page, err := h.svc.List(r.Context(), f)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, page)
+}
+
+func (h *RedemptionsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	rec, err := h.svc.Get(r.Context(), actoHttp.GetPathVars(r)["redemptionId"])
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, rec)
+}
+
+func (h *RedemptionsHandler) Fulfill(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.svc.Fulfill)
+}
+
+// Cancel refunds the costs of a pending order and restocks the reward.
+func (h *RedemptionsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.svc.Cancel)
+}
+
+func (h *RedemptionsHandler) transition(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, id string, req uc.RedemptionTransitionRequest) (*d.RedemptionRecord, error)) {
+	var req uc.RedemptionTransitionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handlers.WriteError(w, 1000, "bad request")
+			return
+		}
+	}
+	rec, err := fn(r.Context(), actoHttp.GetPathVars(r)["redemptionId"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, rec)
 }
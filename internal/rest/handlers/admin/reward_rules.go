@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
+	uc "github.com/usual2970/acto/points"
+)
+
+// RewardRulesHandler manages the reward rules of the point type {name}.
+type RewardRulesHandler struct{ svc *uc.RewardRuleService }
+
+func NewRewardRulesHandler(svc *uc.RewardRuleService) *RewardRulesHandler {
+	return &RewardRulesHandler{svc: svc}
+}
+
+func (h *RewardRulesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req uc.RewardRuleCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	req.URI = actoHttp.GetPathVars(r)["name"]
+	rule, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, rule)
+}
+
+func (h *RewardRulesHandler) List(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.svc.List(r.Context(), actoHttp.GetPathVars(r)["name"])
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, map[string]any{"items": rules})
+}
+
+func (h *RewardRulesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	vars := actoHttp.GetPathVars(r)
+	var req uc.RewardRuleUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	rule, err := h.svc.Update(r.Context(), vars["name"], vars["ruleId"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, rule)
+}
+
+func (h *RewardRulesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := actoHttp.GetPathVars(r)
+	if err := h.svc.Delete(r.Context(), vars["name"], vars["ruleId"]); err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, nil)
+}
+
+// FundPool adds to a reward pool of the point type {name}.
+func (h *RewardRulesHandler) FundPool(w http.ResponseWriter, r *http.Request) {
+	var req uc.RewardPoolFundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	pool, err := h.svc.FundPool(r.Context(), actoHttp.GetPathVars(r)["name"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, pool)
+}
+
+func (h *RewardRulesHandler) ListPools(w http.ResponseWriter, r *http.Request) {
+	pools, err := h.svc.ListPools(r.Context(), actoHttp.GetPathVars(r)["name"])
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, map[string]any{"items": pools})
+}
@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
+	uc "github.com/usual2970/acto/points"
+)
+
+// RewardsHandler manages the catalog rewards priced in the point type {name}.
+type RewardsHandler struct{ svc *uc.RewardCatalogService }
+
+func NewRewardsHandler(svc *uc.RewardCatalogService) *RewardsHandler {
+	return &RewardsHandler{svc: svc}
+}
+
+// List filters by ?q= (name), ?enabled=, ?inStock=true and ?archived=true,
+// which includes archived rewards.
+func (h *RewardsHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := uc.RewardListRequest{Query: q.Get("q")}
+	req.Limit, _ = strconv.Atoi(q.Get("limit"))
+	req.Offset, _ = strconv.Atoi(q.Get("offset"))
+	req.InStock, _ = strconv.ParseBool(q.Get("inStock"))
+	req.IncludeArchived, _ = strconv.ParseBool(q.Get("archived"))
+	if v, err := strconv.ParseBool(q.Get("enabled")); err == nil {
+		req.Enabled = &v
+	}
+	page, err := h.svc.List(r.Context(), actoHttp.GetPathVars(r)["name"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, page)
+}
+
+func (h *RewardsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req uc.RewardCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	reward, err := h.svc.Create(r.Context(), actoHttp.GetPathVars(r)["name"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, reward)
+}
+
+func (h *RewardsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	vars := actoHttp.GetPathVars(r)
+	var req uc.RewardUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	reward, err := h.svc.Update(r.Context(), vars["name"], vars["rewardId"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, reward)
+}
+
+// Archive is the delete of the catalog: the reward is kept for the
+// redemption records pointing at it.
+func (h *RewardsHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	vars := actoHttp.GetPathVars(r)
+	if err := h.svc.Archive(r.Context(), vars["name"], vars["rewardId"]); err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, nil)
+}
+
+func (h *RewardsHandler) Restock(w http.ResponseWriter, r *http.Request) {
+	vars := actoHttp.GetPathVars(r)
+	var req uc.RewardRestockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	reward, err := h.svc.Restock(r.Context(), vars["name"], vars["rewardId"], req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, reward)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/usual2970/acto/internal/rest/handlers"
 	actoHttp "github.com/usual2970/acto/pkg/http"
@@ -11,6 +12,9 @@ import (
 	// path vars are read via request context to stay framework-agnostic
 )
 
+// idempotencyKeyHeader may carry the idempotency key when the body omits it.
+const idempotencyKeyHeader = "Idempotency-Key"
+
 type BalancesHandler struct {
 	svc *uc.BalanceService
 }
@@ -25,11 +29,15 @@ func (h *BalancesHandler) Credit(w http.ResponseWriter, r *http.Request) {
 		handlers.WriteError(w, 1000, "bad request")
 		return
 	}
-	if err := h.svc.Credit(r.Context(), req); err != nil {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
+	}
+	tx, err := h.svc.Credit(r.Context(), req)
+	if err != nil {
 		handlers.WriteDomainError(w, err)
 		return
 	}
-	handlers.WriteSuccess(w, nil)
+	handlers.WriteSuccess(w, tx)
 }
 
 func (h *BalancesHandler) Debit(w http.ResponseWriter, r *http.Request) {
@@ -38,11 +46,89 @@ func (h *BalancesHandler) Debit(w http.ResponseWriter, r *http.Request) {
 		handlers.WriteError(w, 1000, "bad request")
 		return
 	}
-	if err := h.svc.Debit(r.Context(), req); err != nil {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get(idempotencyKeyHeader)
+	}
+	tx, err := h.svc.Debit(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, tx)
+}
+
+func (h *BalancesHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	var req uc.BalanceTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	res, err := h.svc.Transfer(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, res)
+}
+
+func (h *BalancesHandler) Hold(w http.ResponseWriter, r *http.Request) {
+	var req uc.BalanceHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.WriteError(w, 1000, "bad request")
+		return
+	}
+	hold, err := h.svc.Hold(r.Context(), req)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, hold)
+}
+
+func (h *BalancesHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	holdID := actoHttp.GetPathVars(r)["holdId"]
+	if holdID == "" {
+		handlers.WriteError(w, 1000, "missing holdId")
+		return
+	}
+	tx, err := h.svc.Capture(r.Context(), holdID)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, tx)
+}
+
+func (h *BalancesHandler) Release(w http.ResponseWriter, r *http.Request) {
+	holdID := actoHttp.GetPathVars(r)["holdId"]
+	if holdID == "" {
+		handlers.WriteError(w, 1000, "missing holdId")
+		return
+	}
+	tx, err := h.svc.Release(r.Context(), holdID)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, tx)
+}
+
+func (h *BalancesHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	vars := actoHttp.GetPathVars(r)
+	userID := vars["userId"]
+	pointTypeName := r.URL.Query().Get("pointTypeName")
+	if userID == "" || pointTypeName == "" {
+		handlers.WriteError(w, 1000, "missing userId or pointTypeName")
+		return
+	}
+	// expiringDays controls the "expiring soon" window reported with the balance
+	expiringDays, _ := strconv.Atoi(r.URL.Query().Get("expiringDays"))
+	res, err := h.svc.GetBalance(r.Context(), userID, pointTypeName, time.Duration(expiringDays)*24*time.Hour)
+	if err != nil {
 		handlers.WriteDomainError(w, err)
 		return
 	}
-	handlers.WriteSuccess(w, nil)
+	handlers.WriteSuccess(w, res)
 }
 
 func (h *BalancesHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
@@ -20,7 +20,8 @@ func (h *DistributionsHandler) Execute(w http.ResponseWriter, r *http.Request) {
 		handlers.WriteError(w, 1000, "bad request")
 		return
 	}
-	if req.TopN <= 0 {
+	// a snapshot is rewarded in full unless topN is given
+	if req.TopN <= 0 && req.SnapshotID == "" {
 		req.TopN = 100
 	}
 	if err := h.svc.Execute(r.Context(), req); err != nil {
@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
+	d "github.com/usual2970/acto/domain/points"
 	"github.com/usual2970/acto/internal/rest/handlers"
+	actoHttp "github.com/usual2970/acto/pkg/http"
 	uc "github.com/usual2970/acto/points"
 )
 
@@ -20,10 +22,44 @@ func (h *RankingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	ptName := r.URL.Query().Get("pointTypeName")
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	users, err := h.svc.GetTop(r.Context(), ptName, limit, offset)
+	tie := d.TiePolicy(r.URL.Query().Get("tiePolicy"))
+	page, err := h.svc.GetTop(r.Context(), ptName, rankingWindow(r), limit, offset, tie)
 	if err != nil {
 		handlers.WriteDomainError(w, err)
 		return
 	}
-	handlers.WriteSuccess(w, map[string]any{"items": users, "limit": limit, "offset": offset})
+	handlers.WriteSuccess(w, page)
+}
+
+// GetUser returns the rank and score of one user.
+func (h *RankingsHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID := actoHttp.GetPathVars(r)["userId"]
+	ptName := r.URL.Query().Get("pointTypeName")
+	tie := d.TiePolicy(r.URL.Query().Get("tiePolicy"))
+	view, err := h.svc.GetUserRank(r.Context(), ptName, rankingWindow(r), userID, tie)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, view)
+}
+
+// GetAround returns a user with ?n= neighbours above and below.
+func (h *RankingsHandler) GetAround(w http.ResponseWriter, r *http.Request) {
+	userID := actoHttp.GetPathVars(r)["userId"]
+	ptName := r.URL.Query().Get("pointTypeName")
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	tie := d.TiePolicy(r.URL.Query().Get("tiePolicy"))
+	view, err := h.svc.GetAround(r.Context(), ptName, rankingWindow(r), userID, n, tie)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, view)
+}
+
+// rankingWindow reads ?window= (daily, weekly, monthly, season or all) and
+// ?windowKey=; no window selects the all-time ranking.
+func rankingWindow(r *http.Request) d.RankingWindow {
+	return d.RankingWindow{Period: d.RankingPeriod(r.URL.Query().Get("window")), Key: r.URL.Query().Get("windowKey")}
 }
@@ -24,9 +24,10 @@ func (h *RedemptionsHandler) Redeem(w http.ResponseWriter, r *http.Request) {
 		handlers.WriteError(w, 1000, "missing userId or rewardId")
 		return
 	}
-	if err := h.svc.Redeem(r.Context(), req); err != nil {
+	rec, err := h.svc.Redeem(r.Context(), req)
+	if err != nil {
 		handlers.WriteDomainError(w, err)
 		return
 	}
-	handlers.WriteSuccess(w, nil)
+	handlers.WriteSuccess(w, rec)
 }
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/usual2970/acto/internal/rest/handlers"
+	uc "github.com/usual2970/acto/points"
+)
+
+type RewardsHandler struct{ svc *uc.RewardCatalogService }
+
+func NewRewardsHandler(svc *uc.RewardCatalogService) *RewardsHandler {
+	return &RewardsHandler{svc: svc}
+}
+
+// Catalog lists the rewards users can redeem now, optionally only those
+// priced in ?pointTypeName=.
+func (h *RewardsHandler) Catalog(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	page, err := h.svc.Catalog(r.Context(), r.URL.Query().Get("pointTypeName"), limit, offset)
+	if err != nil {
+		handlers.WriteDomainError(w, err)
+		return
+	}
+	handlers.WriteSuccess(w, page)
+}
@@ -18,6 +18,84 @@ func WriteDomainError(w http.ResponseWriter, err error) {
 		WriteError(w, 1003, "reward out of stock")
 	case d.ErrUnauthorizedOperation:
 		WriteError(w, 1004, "forbidden")
+	case d.ErrIdempotencyKeyConflict:
+		WriteError(w, 1005, "idempotency key conflict")
+	case d.ErrTransferDisabled:
+		WriteError(w, 1006, "transfers disabled for point type")
+	case d.ErrInvalidTransfer:
+		WriteError(w, 1007, "invalid transfer")
+	case d.ErrInvalidExpiryPolicy:
+		WriteError(w, 1008, "invalid expiry policy")
+	case d.ErrHoldNotFound:
+		WriteError(w, 1009, "hold not found")
+	case d.ErrHoldNotActive:
+		WriteError(w, 1010, "hold is not active")
+	case d.ErrTransactionNotFound:
+		WriteError(w, 1011, "transaction not found")
+	case d.ErrTransactionReversed:
+		WriteError(w, 1012, "transaction already reversed")
+	case d.ErrTransactionIrreversible:
+		WriteError(w, 1013, "transaction cannot be reversed")
+	case d.ErrInvalidBatch:
+		WriteError(w, 1014, "invalid batch request")
+	case d.ErrImportJobNotFound:
+		WriteError(w, 1015, "import job not found")
+	case d.ErrInvalidImport:
+		WriteError(w, 1016, "invalid import file")
+	case d.ErrInvalidExport:
+		WriteError(w, 1017, "invalid export request")
+	case d.ErrInvalidTiePolicy:
+		WriteError(w, 1018, "invalid tie policy")
+	case d.ErrUserNotRanked:
+		WriteError(w, 1019, "user not ranked")
+	case d.ErrInvalidRankingWindow:
+		WriteError(w, 1020, "invalid ranking window")
+	case d.ErrInvalidSeason:
+		WriteError(w, 1021, "invalid ranking season")
+	case d.ErrSeasonNotFound:
+		WriteError(w, 1022, "ranking season not found")
+	case d.ErrInvalidRankingMetric:
+		WriteError(w, 1023, "invalid ranking metric")
+	case d.ErrDistributionAlreadyDone:
+		WriteError(w, 1024, "distribution already executed for period")
+	case d.ErrInvalidSchedule:
+		WriteError(w, 1025, "invalid distribution schedule")
+	case d.ErrScheduleNotFound:
+		WriteError(w, 1026, "distribution schedule not found")
+	case d.ErrInvalidSnapshot:
+		WriteError(w, 1027, "invalid ranking snapshot")
+	case d.ErrSnapshotNotFound:
+		WriteError(w, 1028, "ranking snapshot not found")
+	case d.ErrPlanChanged:
+		WriteError(w, 1029, "distribution plan changed")
+	case d.ErrDistributionNotFound:
+		WriteError(w, 1030, "distribution not found")
+	case d.ErrDistributionIncomplete:
+		WriteError(w, 1031, "distribution has failed payouts")
+	case d.ErrInvalidRewardRule:
+		WriteError(w, 1032, "invalid reward rule")
+	case d.ErrRewardRuleOverlap:
+		WriteError(w, 1033, "reward rule overlaps another rule")
+	case d.ErrRewardRuleNotFound:
+		WriteError(w, 1034, "reward rule not found")
+	case d.ErrPointTypeNotFound:
+		WriteError(w, 1035, "point type not found")
+	case d.ErrInvalidRewardPool:
+		WriteError(w, 1036, "invalid reward pool amount")
+	case d.ErrInvalidReward:
+		WriteError(w, 1037, "invalid reward")
+	case d.ErrRewardNotFound:
+		WriteError(w, 1038, "reward not found")
+	case d.ErrRedemptionNotFound:
+		WriteError(w, 1039, "redemption not found")
+	case d.ErrRedemptionTransition:
+		WriteError(w, 1040, "invalid redemption status transition")
+	case d.ErrRedemptionLimitReached:
+		WriteError(w, 1041, "redemption limit reached")
+	case d.ErrRewardUnavailable:
+		WriteError(w, 1042, "reward unavailable")
+	case d.ErrRankTooDeep:
+		WriteError(w, 1043, "rank too deep for dense ties")
 	default:
 		WriteError(w, 1500, err.Error())
 	}
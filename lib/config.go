@@ -6,6 +6,8 @@ import (
 	"sync"
 
 	"github.com/usual2970/acto/auth"
+	repoMemory "github.com/usual2970/acto/internal/repository/memory"
+	repoMysql "github.com/usual2970/acto/internal/repository/mysql"
 	"github.com/usual2970/acto/points"
 
 	goRedis "github.com/redis/go-redis/v9"
@@ -94,6 +96,48 @@ func WithRepositoryOverrides(overrides RepositoryOverrides) SetupOption {
 				return err
 			}
 		}
+		if overrides.ImportJobRepo != nil {
+			if err := c.Provide(func() points.ImportJobRepository {
+				return overrides.ImportJobRepo
+			}); err != nil {
+				return err
+			}
+		}
+		if overrides.ReconcileRepo != nil {
+			if err := c.Provide(func() points.ReconciliationRepository {
+				return overrides.ReconcileRepo
+			}); err != nil {
+				return err
+			}
+		}
+		if overrides.SeasonRepo != nil {
+			if err := c.Provide(func() points.SeasonRepository {
+				return overrides.SeasonRepo
+			}); err != nil {
+				return err
+			}
+		}
+		if overrides.ScheduleRepo != nil {
+			if err := c.Provide(func() points.DistributionScheduleRepository {
+				return overrides.ScheduleRepo
+			}); err != nil {
+				return err
+			}
+		}
+		if overrides.SnapshotRepo != nil {
+			if err := c.Provide(func() points.SnapshotRepository {
+				return overrides.SnapshotRepo
+			}); err != nil {
+				return err
+			}
+		}
+		if overrides.Locker != nil {
+			if err := c.Provide(func() points.Locker {
+				return overrides.Locker
+			}); err != nil {
+				return err
+			}
+		}
 		if overrides.RankingRepo != nil {
 			if err := c.Provide(func() points.RankingRepository {
 				return overrides.RankingRepo
@@ -117,6 +161,26 @@ func SetupWithRepositories(overrides RepositoryOverrides) error {
 	if err != nil {
 		return err
 	}
+	// schedules need a lock; without one, lock within this process
+	if overrides.Locker == nil {
+		if err := c.Provide(func() points.Locker { return repoMemory.NewLocker() }); err != nil {
+			return err
+		}
+	}
+	// balances and rankings look up seasons; without a store, keep them in memory
+	if overrides.SeasonRepo == nil {
+		if err := c.Provide(func() points.SeasonRepository { return repoMemory.NewSeasonRepository() }); err != nil {
+			return err
+		}
+	}
+	// distributions freeze rankings into snapshots
+	if overrides.SnapshotRepo == nil {
+		if err := c.Provide(func() points.SnapshotRepository { return repoMemory.NewSnapshotRepository() }); err != nil {
+			return err
+		}
+	}
+	// ImportJobRepo, ReconcileRepo and ScheduleRepo have no default: without
+	// them GetServices leaves imports, reconciliation and schedules nil
 
 	if err := provideServiceModule(c); err != nil {
 		return err
@@ -131,12 +195,36 @@ func SetupWithRepositories(overrides RepositoryOverrides) error {
 
 // Services holds references to all services
 type Services struct {
-	PointTypeService    *points.PointTypeService
-	BalanceService      *points.BalanceService
-	DistributionService *points.DistributionService
-	RedemptionService   *points.RedemptionService
-	RankingsService     points.RankingsService
-	AuthService         *auth.AuthService
+	PointTypeService      *points.PointTypeService
+	BalanceService        *points.BalanceService
+	DistributionService   *points.DistributionService
+	RedemptionService     *points.RedemptionService
+	RankingsService       points.RankingsService
+	ExpiryService         *points.ExpiryService
+	ImportService         *points.ImportService
+	ExportService         *points.ExportService
+	ReconcileService      *points.ReconcileService
+	RankingRebuildService *points.RankingRebuildService
+	SeasonService         *points.SeasonService
+	// DistributionScheduleService runs scheduled distributions
+	DistributionScheduleService *points.DistributionScheduleService
+	// RankingSnapshotService freezes rankings for distributions
+	RankingSnapshotService *points.RankingSnapshotService
+	RewardRuleService      *points.RewardRuleService
+	RewardCatalogService   *points.RewardCatalogService
+	AuthService            *auth.AuthService
+}
+
+// NewMemoryRankingRepository returns the in-process ranking storage, e.g. for
+// RepositoryOverrides.RankingRepo in tests. Rankings are lost on restart.
+func NewMemoryRankingRepository() points.RankingRepository {
+	return repoMemory.NewRankingRepository()
+}
+
+// NewMySQLRankingRepository returns the ranking storage that queries MySQL
+// instead of Redis.
+func NewMySQLRankingRepository(db *sql.DB) points.RankingRepository {
+	return repoMysql.NewRankingRepository(db)
 }
 
 // RepositoryOverrides enables injecting custom repository implementations without exposing DI.
@@ -146,27 +234,65 @@ type RepositoryOverrides struct {
 	RewardRepo     points.RewardRepository
 	RedemptionRepo points.RedemptionRepository
 	RankingRepo    points.RankingRepository
+	// ImportJobRepo, ReconcileRepo and ScheduleRepo are optional; services
+	// needing a missing one are left nil in Services
+	ImportJobRepo points.ImportJobRepository
+	ReconcileRepo points.ReconciliationRepository
+	ScheduleRepo  points.DistributionScheduleRepository
+	// SeasonRepo and SnapshotRepo default to storage in memory
+	SeasonRepo   points.SeasonRepository
+	SnapshotRepo points.SnapshotRepository
+	// Locker defaults to a lock within this process
+	Locker points.Locker
+}
+
+// serviceParams lists the services GetServices resolves. Imports,
+// reconciliation and schedules need repositories SetupWithRepositories may
+// not have been given, so they stay nil without them.
+type serviceParams struct {
+	dig.In
+
+	PointTypeService      *points.PointTypeService
+	BalanceService        *points.BalanceService
+	DistributionService   *points.DistributionService
+	RedemptionService     *points.RedemptionService
+	RankingsService       points.RankingsService
+	ExpiryService         *points.ExpiryService
+	ImportService         *points.ImportService `optional:"true"`
+	ExportService         *points.ExportService
+	ReconcileService      *points.ReconcileService `optional:"true"`
+	RankingRebuildService *points.RankingRebuildService
+	SeasonService         *points.SeasonService
+
+	DistributionScheduleService *points.DistributionScheduleService `optional:"true"`
+	RankingSnapshotService      *points.RankingSnapshotService
+	RewardRuleService           *points.RewardRuleService
+	RewardCatalogService        *points.RewardCatalogService
+
+	AuthService *auth.AuthService
 }
 
 func GetServices() (*Services, error) {
 	c := getGlobalContainer()
 	var svc Services
-	err := c.Invoke(func(
-		pointTypeSvc *points.PointTypeService,
-		balanceSvc *points.BalanceService,
-		distributionSvc *points.DistributionService,
-		redemptionSvc *points.RedemptionService,
-		rankingsSvc points.RankingsService,
-
-		authSvc *auth.AuthService,
-	) {
+	err := c.Invoke(func(p serviceParams) {
 		svc = Services{
-			PointTypeService:    pointTypeSvc,
-			BalanceService:      balanceSvc,
-			DistributionService: distributionSvc,
-			RedemptionService:   redemptionSvc,
-			RankingsService:     rankingsSvc,
-			AuthService:         authSvc,
+			PointTypeService:            p.PointTypeService,
+			BalanceService:              p.BalanceService,
+			DistributionService:         p.DistributionService,
+			RedemptionService:           p.RedemptionService,
+			RankingsService:             p.RankingsService,
+			ExpiryService:               p.ExpiryService,
+			ImportService:               p.ImportService,
+			ExportService:               p.ExportService,
+			ReconcileService:            p.ReconcileService,
+			RankingRebuildService:       p.RankingRebuildService,
+			SeasonService:               p.SeasonService,
+			DistributionScheduleService: p.DistributionScheduleService,
+			RankingSnapshotService:      p.RankingSnapshotService,
+			RewardRuleService:           p.RewardRuleService,
+			RewardCatalogService:        p.RewardCatalogService,
+			AuthService:                 p.AuthService,
 		}
 	})
 	if err != nil {
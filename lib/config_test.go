@@ -0,0 +1,53 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/usual2970/acto/lib"
+	"github.com/usual2970/acto/points"
+)
+
+// The stubs only satisfy the interfaces; setup never calls them.
+type (
+	pointTypeRepo  struct{ points.PointTypeRepository }
+	balanceRepo    struct{ points.BalanceRepository }
+	rewardRepo     struct{ points.RewardRepository }
+	redemptionRepo struct{ points.RedemptionRepository }
+)
+
+// TestSetupWithOriginalRepositories sets up with only the repositories
+// RepositoryOverrides first had: the services built on them resolve, and
+// those needing a repository that was not given are left nil.
+func TestSetupWithOriginalRepositories(t *testing.T) {
+	err := lib.SetupWithRepositories(lib.RepositoryOverrides{
+		PointTypeRepo:  pointTypeRepo{},
+		BalanceRepo:    balanceRepo{},
+		RewardRepo:     rewardRepo{},
+		RedemptionRepo: redemptionRepo{},
+		RankingRepo:    lib.NewMemoryRankingRepository(),
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	svc, err := lib.GetServices()
+	if err != nil {
+		t.Fatalf("get services: %v", err)
+	}
+	for name, ok := range map[string]bool{
+		"PointTypeService":       svc.PointTypeService != nil,
+		"BalanceService":         svc.BalanceService != nil,
+		"DistributionService":    svc.DistributionService != nil,
+		"RedemptionService":      svc.RedemptionService != nil,
+		"RankingsService":        svc.RankingsService != nil,
+		"SeasonService":          svc.SeasonService != nil,
+		"RankingSnapshotService": svc.RankingSnapshotService != nil,
+	} {
+		if !ok {
+			t.Errorf("%s is nil", name)
+		}
+	}
+	if svc.ImportService != nil || svc.ReconcileService != nil || svc.DistributionScheduleService != nil {
+		t.Errorf("got import %v, reconcile %v and schedule %v services without their repositories, want nil",
+			svc.ImportService, svc.ReconcileService, svc.DistributionScheduleService)
+	}
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appcfg "github.com/usual2970/acto/internal/config"
+	"github.com/usual2970/acto/internal/log"
+)
+
+// JobOption adjusts the jobs StartBackgroundJobs launches.
+type JobOption func(*jobOptions)
+
+type jobOptions struct {
+	expiryInterval time.Duration
+}
+
+// WithDefaultExpiryInterval expires points every d unless EXPIRY_INTERVAL is
+// set. Without it, expiry only runs when EXPIRY_INTERVAL is set.
+func WithDefaultExpiryInterval(d time.Duration) JobOption {
+	return func(o *jobOptions) { o.expiryInterval = d }
+}
+
+// StartBackgroundJobs launches the library's periodic jobs, and the startup
+// ranking rebuild when enabled or rankings are kept in memory, in their own
+// goroutines. Jobs stop when ctx is cancelled; a job whose interval is unset
+// or "0" is not started. Import jobs interrupted by a restart are always
+// failed, so their status does not stay running.
+func StartBackgroundJobs(ctx context.Context, opts ...JobOption) error {
+	svc, err := GetServices()
+	if err != nil {
+		return fmt.Errorf("failed to get services: %w", err)
+	}
+	cfg := appcfg.Load()
+	var o jobOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if cfg.ExpiryInterval != "" {
+		o.expiryInterval = parseInterval(cfg.ExpiryInterval)
+	}
+
+	if interval := o.expiryInterval; interval > 0 && svc.ExpiryService != nil {
+		go svc.ExpiryService.Run(ctx, interval)
+	}
+	if interval := parseInterval(cfg.DistributionInterval); interval > 0 && svc.DistributionScheduleService != nil {
+		go svc.DistributionScheduleService.Run(ctx, interval)
+	}
+	if svc.ImportService != nil {
+		go svc.ImportService.Run(ctx)
+	}
+	rebuild := cfg.RankingRebuildOnStart || cfg.RankingBackend == appcfg.RankingBackendMemory
+	if rebuild && svc.RankingRebuildService != nil {
+		go func() {
+			if _, err := svc.RankingRebuildService.RebuildMissing(ctx); err != nil {
+				log.Errorf("ranking rebuild: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+func parseInterval(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
@@ -2,9 +2,11 @@ package lib
 
 import (
 	"database/sql"
+	"fmt"
 
 	authUsecase "github.com/usual2970/acto/auth"
 	appcfg "github.com/usual2970/acto/internal/config"
+	repoMemory "github.com/usual2970/acto/internal/repository/memory"
 	repoMysql "github.com/usual2970/acto/internal/repository/mysql"
 	repoRedis "github.com/usual2970/acto/internal/repository/redis"
 	adminHandlers "github.com/usual2970/acto/internal/rest/handlers/admin"
@@ -22,7 +24,8 @@ func provideConfigModule(c *dig.Container) error {
 	return c.Provide(appcfg.Load)
 }
 
-// InfraModule provides infrastructure dependencies (DB, Redis)
+// InfraModule provides infrastructure dependencies (DB, Redis). The Redis
+// client is nil unless rankings are stored in it.
 func provideInfraModule(c *dig.Container) error {
 	// DB
 	if err := c.Provide(func(cfg appcfg.Config) (*sql.DB, error) {
@@ -32,6 +35,9 @@ func provideInfraModule(c *dig.Container) error {
 	}
 	// Redis
 	if err := c.Provide(func(cfg appcfg.Config) *goRedis.Client {
+		if cfg.RankingBackend != "" && cfg.RankingBackend != appcfg.RankingBackendRedis {
+			return nil
+		}
 		return goRedis.NewClient(&goRedis.Options{Addr: cfg.RedisAddr})
 	}); err != nil {
 		return err
@@ -54,12 +60,63 @@ func provideRepoModule(c *dig.Container) error {
 	if err := c.Provide(repoMysql.NewRedemptionRepository, dig.As(new(points.RedemptionRepository))); err != nil {
 		return err
 	}
-	if err := c.Provide(repoRedis.NewRankingRepository, dig.As(new(points.RankingRepository))); err != nil {
+	if err := c.Provide(repoMysql.NewImportJobRepository, dig.As(new(points.ImportJobRepository))); err != nil {
+		return err
+	}
+	if err := c.Provide(repoMysql.NewSeasonRepository, dig.As(new(points.SeasonRepository))); err != nil {
+		return err
+	}
+	if err := c.Provide(repoMysql.NewReconciliationRepository, dig.As(new(points.ReconciliationRepository))); err != nil {
+		return err
+	}
+	if err := c.Provide(repoMysql.NewDistributionScheduleRepository, dig.As(new(points.DistributionScheduleRepository))); err != nil {
+		return err
+	}
+	if err := c.Provide(repoMysql.NewSnapshotRepository, dig.As(new(points.SnapshotRepository))); err != nil {
+		return err
+	}
+	if err := c.Provide(newRankingRepository); err != nil {
+		return err
+	}
+	if err := c.Provide(newLocker); err != nil {
 		return err
 	}
 	return nil
 }
 
+type backendParams struct {
+	dig.In
+
+	Config appcfg.Config
+	DB     *sql.DB
+	Redis  *goRedis.Client `optional:"true"`
+}
+
+// newRankingRepository picks the ranking storage configured by RANKING_BACKEND.
+func newRankingRepository(p backendParams) (points.RankingRepository, error) {
+	switch p.Config.RankingBackend {
+	case "", appcfg.RankingBackendRedis:
+		if p.Redis == nil {
+			return nil, fmt.Errorf("ranking backend %q needs a redis client", appcfg.RankingBackendRedis)
+		}
+		return repoRedis.NewRankingRepository(p.Redis), nil
+	case appcfg.RankingBackendMemory:
+		return repoMemory.NewRankingRepository(), nil
+	case appcfg.RankingBackendMySQL:
+		return repoMysql.NewRankingRepository(p.DB), nil
+	}
+	return nil, fmt.Errorf("unknown ranking backend %q", p.Config.RankingBackend)
+}
+
+// newLocker shares locks through Redis when rankings live there, and through
+// MySQL otherwise.
+func newLocker(p backendParams) points.Locker {
+	if p.Redis != nil {
+		return repoRedis.NewLocker(p.Redis)
+	}
+	return repoMysql.NewLocker(p.DB)
+}
+
 // ServiceModule provides business services
 func provideServiceModule(c *dig.Container) error {
 	providers := []func() error{
@@ -68,6 +125,16 @@ func provideServiceModule(c *dig.Container) error {
 		func() error { return c.Provide(usecases.NewDistributionService) },
 		func() error { return c.Provide(usecases.NewRedemptionService) },
 		func() error { return c.Provide(usecases.NewRankingsService) },
+		func() error { return c.Provide(usecases.NewExpiryService) },
+		func() error { return c.Provide(usecases.NewImportService) },
+		func() error { return c.Provide(usecases.NewExportService) },
+		func() error { return c.Provide(usecases.NewReconcileService) },
+		func() error { return c.Provide(usecases.NewRankingRebuildService) },
+		func() error { return c.Provide(usecases.NewSeasonService) },
+		func() error { return c.Provide(usecases.NewDistributionScheduleService) },
+		func() error { return c.Provide(usecases.NewRankingSnapshotService) },
+		func() error { return c.Provide(usecases.NewRewardRuleService) },
+		func() error { return c.Provide(usecases.NewRewardCatalogService) },
 
 		// admin services can be added here
 		func() error { return c.Provide(authUsecase.NewAuthService) },
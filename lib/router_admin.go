@@ -61,17 +61,94 @@ func RegisterAdminRoutes(
 		reg.Handle(http.MethodPatch, basePath+"/point-types/{name}", requireAdmin(wrap(pt.Update, true)))
 		reg.Handle(http.MethodDelete, basePath+"/point-types/{name}", requireAdmin(wrap(pt.Delete, true)))
 	}
+	if svc.RewardRuleService != nil {
+		rr := handlers.NewRewardRulesHandler(svc.RewardRuleService)
+		reg.Handle(http.MethodPost, basePath+"/point-types/{name}/reward-rules", requireAdmin(wrap(rr.Create, true)))
+		reg.Handle(http.MethodGet, basePath+"/point-types/{name}/reward-rules", requireAdmin(wrap(rr.List, true)))
+		reg.Handle(http.MethodPatch, basePath+"/point-types/{name}/reward-rules/{ruleId}", requireAdmin(wrap(rr.Update, true)))
+		reg.Handle(http.MethodDelete, basePath+"/point-types/{name}/reward-rules/{ruleId}", requireAdmin(wrap(rr.Delete, true)))
+		reg.Handle(http.MethodPost, basePath+"/point-types/{name}/reward-pools", requireAdmin(wrap(rr.FundPool, true)))
+		reg.Handle(http.MethodGet, basePath+"/point-types/{name}/reward-pools", requireAdmin(wrap(rr.ListPools, true)))
+	}
+	if svc.RewardCatalogService != nil {
+		rw := handlers.NewRewardsHandler(svc.RewardCatalogService)
+		reg.Handle(http.MethodGet, basePath+"/point-types/{name}/rewards", requireAdmin(wrap(rw.List, true)))
+		reg.Handle(http.MethodPost, basePath+"/point-types/{name}/rewards", requireAdmin(wrap(rw.Create, true)))
+		reg.Handle(http.MethodPatch, basePath+"/point-types/{name}/rewards/{rewardId}", requireAdmin(wrap(rw.Update, true)))
+		reg.Handle(http.MethodDelete, basePath+"/point-types/{name}/rewards/{rewardId}", requireAdmin(wrap(rw.Archive, true)))
+		reg.Handle(http.MethodPost, basePath+"/point-types/{name}/rewards/{rewardId}/restock", requireAdmin(wrap(rw.Restock, true)))
+	}
+	if svc.RedemptionService != nil {
+		rd := handlers.NewRedemptionsHandler(svc.RedemptionService)
+		reg.Handle(http.MethodPost, basePath+"/redemptions", requireAdmin(http.HandlerFunc(rd.Redeem)))
+		reg.Handle(http.MethodGet, basePath+"/redemptions", requireAdmin(http.HandlerFunc(rd.List)))
+		reg.Handle(http.MethodGet, basePath+"/redemptions/{redemptionId}", requireAdmin(wrap(rd.Get, true)))
+		reg.Handle(http.MethodPost, basePath+"/redemptions/{redemptionId}/fulfill", requireAdmin(wrap(rd.Fulfill, true)))
+		reg.Handle(http.MethodPost, basePath+"/redemptions/{redemptionId}/cancel", requireAdmin(wrap(rd.Cancel, true)))
+	}
 	if svc.BalanceService != nil {
 		b := handlers.NewBalancesHandler(svc.BalanceService)
 		reg.Handle(http.MethodPost, basePath+"/users/balance/credit", requireAdmin(http.HandlerFunc(b.Credit)))
 		reg.Handle(http.MethodPost, basePath+"/users/balance/debit", requireAdmin(http.HandlerFunc(b.Debit)))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/transfer", requireAdmin(http.HandlerFunc(b.Transfer)))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/batch", requireAdmin(http.HandlerFunc(b.Batch)))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/holds", requireAdmin(http.HandlerFunc(b.Hold)))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/holds/{holdId}/capture", requireAdmin(wrap(b.Capture, true)))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/holds/{holdId}/release", requireAdmin(wrap(b.Release, true)))
+		reg.Handle(http.MethodGet, basePath+"/users/{userId}/balance", requireAdmin(wrap(b.GetBalance, true)))
 		reg.Handle(http.MethodGet, basePath+"/users/{userId}/transactions", requireAdmin(wrap(b.ListTransactions, true)))
+		reg.Handle(http.MethodPost, basePath+"/transactions/{transactionId}/reverse", requireAdmin(wrap(b.Reverse, true)))
+	}
+
+	if svc.ImportService != nil {
+		im := handlers.NewImportsHandler(svc.ImportService)
+		reg.Handle(http.MethodPost, basePath+"/imports", requireAdmin(http.HandlerFunc(im.Create)))
+		reg.Handle(http.MethodGet, basePath+"/imports/{jobId}", requireAdmin(wrap(im.Get, true)))
+		reg.Handle(http.MethodGet, basePath+"/imports/{jobId}/errors", requireAdmin(wrap(im.ListErrors, true)))
+	}
+	if svc.ExportService != nil {
+		ex := handlers.NewExportsHandler(svc.ExportService)
+		reg.Handle(http.MethodGet, basePath+"/exports/transactions", requireAdmin(http.HandlerFunc(ex.Transactions)))
+		reg.Handle(http.MethodGet, basePath+"/exports/balances", requireAdmin(http.HandlerFunc(ex.Balances)))
+	}
+	if svc.ReconcileService != nil {
+		rc := handlers.NewReconcileHandler(svc.ReconcileService)
+		reg.Handle(http.MethodPost, basePath+"/reconcile", requireAdmin(http.HandlerFunc(rc.Run)))
 	}
 
 	if svc.RankingsService != nil {
 		rk := handlers.NewRankingsHandler(svc.RankingsService)
 		reg.Handle(http.MethodGet, basePath+"/rankings", requireAdmin(http.HandlerFunc(rk.Get)))
 	}
+	if svc.RankingRebuildService != nil {
+		rb := handlers.NewRankingRebuildHandler(svc.RankingRebuildService)
+		reg.Handle(http.MethodPost, basePath+"/rankings/rebuild", requireAdmin(http.HandlerFunc(rb.Rebuild)))
+	}
+	if svc.SeasonService != nil {
+		se := handlers.NewSeasonsHandler(svc.SeasonService)
+		reg.Handle(http.MethodPost, basePath+"/rankings/seasons", requireAdmin(http.HandlerFunc(se.Create)))
+		reg.Handle(http.MethodGet, basePath+"/rankings/seasons", requireAdmin(http.HandlerFunc(se.List)))
+	}
+	if svc.RankingSnapshotService != nil {
+		sn := handlers.NewRankingSnapshotsHandler(svc.RankingSnapshotService)
+		reg.Handle(http.MethodPost, basePath+"/rankings/snapshots", requireAdmin(http.HandlerFunc(sn.Create)))
+		reg.Handle(http.MethodGet, basePath+"/rankings/snapshots", requireAdmin(http.HandlerFunc(sn.List)))
+		reg.Handle(http.MethodGet, basePath+"/rankings/snapshots/{snapshotId}", requireAdmin(wrap(sn.Get, true)))
+	}
+	if svc.DistributionService != nil {
+		ds := handlers.NewDistributionsHandler(svc.DistributionService)
+		reg.Handle(http.MethodPost, basePath+"/distributions/execute", requireAdmin(http.HandlerFunc(ds.Execute)))
+		reg.Handle(http.MethodPost, basePath+"/distributions/preview", requireAdmin(http.HandlerFunc(ds.Preview)))
+		reg.Handle(http.MethodGet, basePath+"/distributions/runs/{distributionId}", requireAdmin(wrap(ds.Get, true)))
+		reg.Handle(http.MethodPost, basePath+"/distributions/runs/{distributionId}/retry", requireAdmin(wrap(ds.Retry, true)))
+	}
+	if svc.DistributionScheduleService != nil {
+		sc := handlers.NewDistributionSchedulesHandler(svc.DistributionScheduleService)
+		reg.Handle(http.MethodPost, basePath+"/distributions/schedules", requireAdmin(http.HandlerFunc(sc.Create)))
+		reg.Handle(http.MethodGet, basePath+"/distributions/schedules", requireAdmin(http.HandlerFunc(sc.List)))
+		reg.Handle(http.MethodPatch, basePath+"/distributions/schedules/{scheduleId}", requireAdmin(wrap(sc.Update, true)))
+		reg.Handle(http.MethodDelete, basePath+"/distributions/schedules/{scheduleId}", requireAdmin(wrap(sc.Delete, true)))
+	}
 
 	return nil
 }
@@ -54,12 +54,34 @@ func RegisterApiRoutes(
 		b := handlers.NewBalancesHandler(svc.BalanceService)
 		reg.Handle(http.MethodPost, basePath+"/users/balance/credit", http.HandlerFunc(b.Credit))
 		reg.Handle(http.MethodPost, basePath+"/users/balance/debit", http.HandlerFunc(b.Debit))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/transfer", http.HandlerFunc(b.Transfer))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/holds", http.HandlerFunc(b.Hold))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/holds/{holdId}/capture", wrap(b.Capture, true))
+		reg.Handle(http.MethodPost, basePath+"/users/balance/holds/{holdId}/release", wrap(b.Release, true))
+		reg.Handle(http.MethodGet, basePath+"/users/{userId}/balance", wrap(b.GetBalance, true))
 		reg.Handle(http.MethodGet, basePath+"/users/{userId}/transactions", wrap(b.ListTransactions, true))
 	}
 
 	if svc.RankingsService != nil {
 		rk := handlers.NewRankingsHandler(svc.RankingsService)
 		reg.Handle(http.MethodGet, basePath+"/rankings", http.HandlerFunc(rk.Get))
+		reg.Handle(http.MethodGet, basePath+"/rankings/users/{userId}", wrap(rk.GetUser, true))
+		reg.Handle(http.MethodGet, basePath+"/rankings/around/{userId}", wrap(rk.GetAround, true))
+	}
+
+	if svc.RewardCatalogService != nil {
+		rw := handlers.NewRewardsHandler(svc.RewardCatalogService)
+		reg.Handle(http.MethodGet, basePath+"/rewards", http.HandlerFunc(rw.Catalog))
+	}
+
+	if svc.RedemptionService != nil {
+		rd := handlers.NewRedemptionsHandler(svc.RedemptionService)
+		reg.Handle(http.MethodPost, basePath+"/redemptions", http.HandlerFunc(rd.Redeem))
+	}
+
+	if svc.DistributionService != nil {
+		ds := handlers.NewDistributionsHandler(svc.DistributionService)
+		reg.Handle(http.MethodPost, basePath+"/distributions/execute", http.HandlerFunc(ds.Execute))
 	}
 
 	return nil
@@ -0,0 +1,263 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+const (
+	// MaxBatchItems bounds the number of items accepted by one Batch call.
+	MaxBatchItems = 50000
+	// batchChunkSize is the number of items written per multi-row statement.
+	batchChunkSize = 500
+)
+
+// errBatchAborted rolls back an atomic batch once any item has failed.
+var errBatchAborted = errors.New("batch aborted")
+
+// Batch credits or debits many balances. Items are applied in chunks using
+// multi-row statements. In atomic mode every chunk runs in one transaction and
+// a single failing item rolls back the whole batch; in best-effort mode each
+// chunk commits on its own and failing items are skipped.
+func (s *BalanceService) Batch(ctx context.Context, req BalanceBatchRequest) (*BalanceBatchResult, error) {
+	if req.Type != d.TransactionCredit && req.Type != d.TransactionDebit {
+		return nil, d.ErrInvalidBatch
+	}
+	if req.Mode == "" {
+		req.Mode = BatchAtomic
+	}
+	if req.Mode != BatchAtomic && req.Mode != BatchBestEffort {
+		return nil, d.ErrInvalidBatch
+	}
+	if len(req.Items) == 0 || len(req.Items) > MaxBatchItems {
+		return nil, d.ErrInvalidBatch
+	}
+
+	res := &BalanceBatchResult{Mode: req.Mode, Items: make([]BalanceBatchItemResult, len(req.Items))}
+	pts := map[string]*d.PointType{}
+	for i, it := range req.Items {
+		res.Items[i] = BalanceBatchItemResult{Index: i, UserID: it.UserID, URI: it.URI}
+		if it.UserID == "" || it.Amount <= 0 {
+			res.Items[i].Error = d.ErrInvalidBatch.Error()
+			continue
+		}
+		if _, ok := pts[it.URI]; !ok {
+			pt, err := s.pointTypes.GetPointTypeByURI(ctx, it.URI)
+			if err != nil {
+				pt = nil
+			}
+			pts[it.URI] = pt
+		}
+		if pts[it.URI] == nil {
+			res.Items[i].Error = d.ErrPointTypeNotFound.Error()
+		}
+	}
+
+	var chunks [][]int
+	for start := 0; start < len(req.Items); start += batchChunkSize {
+		idx := make([]int, 0, batchChunkSize)
+		for i := start; i < min(start+batchChunkSize, len(req.Items)); i++ {
+			idx = append(idx, i)
+		}
+		chunks = append(chunks, idx)
+	}
+
+	if req.Mode == BatchAtomic {
+		if res.countFailed() > 0 {
+			res.abort()
+			return res, nil
+		}
+		var applied []batchApplied
+		err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+			for _, idx := range chunks {
+				a, err := s.applyBatchChunk(ctx, req, idx, pts, res)
+				if err != nil {
+					return err
+				}
+				if res.countFailed() > 0 {
+					return errBatchAborted
+				}
+				applied = append(applied, a)
+			}
+			return nil
+		})
+		if errors.Is(err, errBatchAborted) {
+			res.abort()
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range applied {
+			s.commitBatchChunk(ctx, a, res)
+		}
+	} else {
+		for _, idx := range chunks {
+			var a batchApplied
+			err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+				var err error
+				a, err = s.applyBatchChunk(ctx, req, idx, pts, res)
+				return err
+			})
+			if err != nil {
+				for _, i := range idx {
+					if res.Items[i].Error == "" {
+						res.Items[i].Error = err.Error()
+					}
+				}
+				continue
+			}
+			s.commitBatchChunk(ctx, a, res)
+		}
+	}
+	res.Failed = res.countFailed()
+	res.Succeeded = len(res.Items) - res.Failed
+	return res, nil
+}
+
+// batchApplied describes the writes of one chunk, reported once it commits.
+type batchApplied struct {
+	items  []int
+	txIDs  []string
+	txs    []d.Transaction
+	scores []ScoreUpdate
+}
+
+// applyBatchChunk locks the balances touched by the chunk, applies every
+// valid item in order and writes the results with multi-row statements.
+// Items that fail validation get an error in res and are not written.
+func (s *BalanceService) applyBatchChunk(ctx context.Context, req BalanceBatchRequest, idx []int, pts map[string]*d.PointType, res *BalanceBatchResult) (batchApplied, error) {
+	var a batchApplied
+	seen := map[BalanceKey]bool{}
+	var keys []BalanceKey
+	for _, i := range idx {
+		if res.Items[i].Error != "" {
+			continue
+		}
+		k := BalanceKey{UserID: req.Items[i].UserID, PointTypeID: pts[req.Items[i].URI].ID}
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return a, nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].UserID != keys[j].UserID {
+			return keys[i].UserID < keys[j].UserID
+		}
+		return keys[i].PointTypeID < keys[j].PointTypeID
+	})
+	rows, err := s.repo.GetUserBalancesForUpdate(ctx, keys)
+	if err != nil {
+		return a, err
+	}
+	balances := make(map[BalanceKey]*d.UserBalance, len(keys))
+	for i := range rows {
+		balances[BalanceKey{UserID: rows[i].UserID, PointTypeID: rows[i].PointTypeID}] = &rows[i]
+	}
+
+	var txs []d.Transaction
+	for _, i := range idx {
+		if res.Items[i].Error != "" {
+			continue
+		}
+		it := req.Items[i]
+		pt := pts[it.URI]
+		k := BalanceKey{UserID: it.UserID, PointTypeID: pt.ID}
+		ub, ok := balances[k]
+		if !ok {
+			ub = &d.UserBalance{UserID: it.UserID, PointTypeID: pt.ID}
+			balances[k] = ub
+		}
+		tx := d.Transaction{UserID: it.UserID, PointTypeID: pt.ID, Amount: it.Amount, Type: req.Type, Reason: it.Reason, Before: ub.Balance}
+		if req.Type == d.TransactionDebit {
+			if ub.Available() < it.Amount {
+				res.Items[i].Error = d.ErrInsufficientBalance.Error()
+				continue
+			}
+			if err := consumeLots(ctx, s.repo, ub.UserID, ub.PointTypeID, ub.Balance, it.Amount); err != nil {
+				return a, err
+			}
+			ub.Balance -= it.Amount
+		} else {
+			ub.Balance += it.Amount
+		}
+		tx.After = ub.Balance
+		txs = append(txs, tx)
+		a.items = append(a.items, i)
+	}
+	if len(txs) == 0 {
+		return a, nil
+	}
+
+	changed := make([]d.UserBalance, 0, len(keys))
+	for _, k := range keys {
+		if ub, ok := balances[k]; ok {
+			changed = append(changed, *ub)
+			a.scores = append(a.scores, ScoreUpdate{PointTypeID: ub.PointTypeID, UserID: ub.UserID, Score: ub.Balance})
+		}
+	}
+	if err := s.repo.UpsertUserBalances(ctx, changed); err != nil {
+		return a, err
+	}
+	if a.txIDs, err = s.repo.InsertTransactions(ctx, txs); err != nil {
+		return a, err
+	}
+	a.txs = txs
+	if req.Type == d.TransactionCredit {
+		now := time.Now()
+		var lots []d.BalanceLot
+		for n, tx := range txs {
+			pt := pts[req.Items[a.items[n]].URI]
+			if pt.ExpiryPolicy == "" || pt.ExpiryPolicy == d.ExpiryNone {
+				continue
+			}
+			lots = append(lots, d.BalanceLot{UserID: tx.UserID, PointTypeID: tx.PointTypeID, TransactionID: a.txIDs[n], Amount: tx.Amount, Remaining: tx.Amount, EarnedAt: now.Unix(), ExpiresAt: pt.LotExpiry(now)})
+		}
+		if err := s.repo.InsertLots(ctx, lots); err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// commitBatchChunk marks the items of a committed chunk as successful and
+// pushes the new balances and earned points to the rankings.
+func (s *BalanceService) commitBatchChunk(ctx context.Context, a batchApplied, res *BalanceBatchResult) {
+	for n, i := range a.items {
+		res.Items[i].Success = true
+		res.Items[i].TransactionID = a.txIDs[n]
+	}
+	s.scores.setBalances(ctx, a.scores)
+	s.scores.committed(ctx, nil, a.txs...)
+	s.windows.record(ctx, a.txs, time.Now())
+}
+
+func (r *BalanceBatchResult) countFailed() int {
+	var n int
+	for _, it := range r.Items {
+		if it.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// abort reports an atomic batch that was rolled back because of failing items.
+func (r *BalanceBatchResult) abort() {
+	for i := range r.Items {
+		r.Items[i].Success = false
+		r.Items[i].TransactionID = ""
+		if r.Items[i].Error == "" {
+			r.Items[i].Error = errBatchAborted.Error()
+		}
+	}
+	r.Failed = r.countFailed()
+	r.Succeeded = 0
+}
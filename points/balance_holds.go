@@ -0,0 +1,135 @@
+package points
+
+import (
+	"context"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// DefaultHoldTTL is how long a hold reserves points when the request does not
+// set a timeout.
+const DefaultHoldTTL = 15 * time.Minute
+
+// Hold reserves req.Amount of the user's available balance. The reserved
+// points stay in the balance until the hold is captured, released, or expires.
+func (s *BalanceService) Hold(ctx context.Context, req BalanceHoldRequest) (*d.BalanceHold, error) {
+	if req.Amount <= 0 {
+		return nil, nil
+	}
+	pt, err := s.pointTypes.GetPointTypeByURI(ctx, req.URI)
+	if err != nil {
+		return nil, err
+	}
+	ttl := DefaultHoldTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	now := time.Now()
+	hold := &d.BalanceHold{ID: newID(), UserID: req.UserID, PointTypeID: pt.ID, Amount: req.Amount, Reason: req.Reason, Status: d.HoldActive, ExpiresAt: now.Add(ttl).Unix(), CreatedAt: now.Unix(), UpdatedAt: now.Unix()}
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		ub, err := s.repo.GetUserBalanceForUpdate(ctx, req.UserID, pt.ID)
+		if err != nil {
+			return err
+		}
+		if ub.Available() < req.Amount {
+			return d.ErrInsufficientBalance
+		}
+		ub.Held += req.Amount
+		if err := s.repo.UpsertUserBalance(ctx, *ub); err != nil {
+			return err
+		}
+		if err := s.repo.InsertHold(ctx, *hold); err != nil {
+			return err
+		}
+		_, err = s.repo.InsertTransaction(ctx, d.Transaction{UserID: req.UserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionHold, Reason: req.Reason, Before: ub.Balance, After: ub.Balance, HoldID: hold.ID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// Capture debits the full amount of an active hold.
+func (s *BalanceService) Capture(ctx context.Context, holdID string) (*d.Transaction, error) {
+	var res *d.Transaction
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		hold, err := s.repo.GetHoldForUpdate(ctx, holdID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != d.HoldActive || hold.ExpiresAt <= time.Now().Unix() {
+			return d.ErrHoldNotActive
+		}
+		ub, err := s.repo.GetUserBalanceForUpdate(ctx, hold.UserID, hold.PointTypeID)
+		if err != nil {
+			return err
+		}
+		if err := consumeLots(ctx, s.repo, hold.UserID, hold.PointTypeID, ub.Balance, hold.Amount); err != nil {
+			return err
+		}
+		before := ub.Balance
+		ub.Balance -= hold.Amount
+		ub.Held -= hold.Amount
+		if err := s.repo.UpsertUserBalance(ctx, *ub); err != nil {
+			return err
+		}
+		if err := s.repo.UpdateHoldStatus(ctx, hold.ID, d.HoldCaptured); err != nil {
+			return err
+		}
+		tx := d.Transaction{UserID: hold.UserID, PointTypeID: hold.PointTypeID, Amount: hold.Amount, Type: d.TransactionCapture, Reason: hold.Reason, Before: before, After: ub.Balance, HoldID: hold.ID}
+		if tx.ID, err = s.repo.InsertTransaction(ctx, tx); err != nil {
+			return err
+		}
+		s.scores.setBalance(ctx, hold.PointTypeID, hold.UserID, ub.Balance)
+		res = &tx
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.scores.committed(ctx, nil, *res)
+	return res, nil
+}
+
+// Release returns the amount of an active hold to the available balance.
+func (s *BalanceService) Release(ctx context.Context, holdID string) (*d.Transaction, error) {
+	var res *d.Transaction
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		hold, err := s.repo.GetHoldForUpdate(ctx, holdID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != d.HoldActive {
+			return d.ErrHoldNotActive
+		}
+		res, err = releaseHold(ctx, s.repo, hold, d.HoldReleased)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// releaseHold clears a locked, active hold and records a release entry. It
+// must run inside BalanceRepository.WithTx.
+func releaseHold(ctx context.Context, repo BalanceRepository, hold *d.BalanceHold, status d.BalanceHoldStatus) (*d.Transaction, error) {
+	ub, err := repo.GetUserBalanceForUpdate(ctx, hold.UserID, hold.PointTypeID)
+	if err != nil {
+		return nil, err
+	}
+	ub.Held -= hold.Amount
+	if err := repo.UpsertUserBalance(ctx, *ub); err != nil {
+		return nil, err
+	}
+	if err := repo.UpdateHoldStatus(ctx, hold.ID, status); err != nil {
+		return nil, err
+	}
+	tx := d.Transaction{UserID: hold.UserID, PointTypeID: hold.PointTypeID, Amount: hold.Amount, Type: d.TransactionRelease, Reason: string(status), Before: ub.Balance, After: ub.Balance, HoldID: hold.ID}
+	if tx.ID, err = repo.InsertTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
@@ -0,0 +1,80 @@
+package points
+
+import (
+	"context"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// Reverse posts a compensating entry for a credit, debit or capture and links
+// it to the original. A transaction can be reversed at most once; reversing a
+// credit the user has already spent fails with ErrInsufficientBalance.
+func (s *BalanceService) Reverse(ctx context.Context, transactionID, reason string) (*d.Transaction, error) {
+	orig, err := s.repo.GetTransaction(ctx, transactionID, false)
+	if err != nil {
+		return nil, err
+	}
+	if !orig.Reversible() {
+		return nil, d.ErrTransactionIrreversible
+	}
+	pt, err := s.pointTypes.GetPointTypeByID(ctx, orig.PointTypeID)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		reason = "reversal"
+	}
+	var res *d.Transaction
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		// lock the balance before the original entry, as every other ledger write does
+		ub, err := s.repo.GetUserBalanceForUpdate(ctx, orig.UserID, orig.PointTypeID)
+		if err != nil {
+			return err
+		}
+		orig, err := s.repo.GetTransaction(ctx, transactionID, true)
+		if err != nil {
+			return err
+		}
+		if orig.ReversedBy != "" {
+			return d.ErrTransactionReversed
+		}
+		rev := d.Transaction{UserID: orig.UserID, PointTypeID: orig.PointTypeID, Amount: orig.Amount, Reason: reason, Before: ub.Balance, ReversalOf: orig.ID}
+		if orig.Type == d.TransactionCredit {
+			if ub.Available() < orig.Amount {
+				return d.ErrInsufficientBalance
+			}
+			if err := consumeLots(ctx, s.repo, ub.UserID, ub.PointTypeID, ub.Balance, orig.Amount); err != nil {
+				return err
+			}
+			rev.Type = d.TransactionDebit
+			ub.Balance -= orig.Amount
+		} else {
+			rev.Type = d.TransactionCredit
+			ub.Balance += orig.Amount
+		}
+		rev.After = ub.Balance
+		if err := s.repo.UpsertUserBalance(ctx, *ub); err != nil {
+			return err
+		}
+		if rev.ID, err = s.repo.InsertTransaction(ctx, rev); err != nil {
+			return err
+		}
+		if err := s.repo.MarkTransactionReversed(ctx, orig.ID, rev.ID); err != nil {
+			return err
+		}
+		if rev.Type == d.TransactionCredit {
+			if err := addLot(ctx, s.repo, pt, rev, time.Now()); err != nil {
+				return err
+			}
+		}
+		s.scores.setBalance(ctx, ub.PointTypeID, ub.UserID, ub.Balance)
+		res = &rev
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.scores.committed(ctx, orig, *res)
+	return res, nil
+}
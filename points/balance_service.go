@@ -2,79 +2,230 @@ package points
 
 import (
 	"context"
+	"time"
 
 	d "github.com/usual2970/acto/domain/points"
 )
 
 type BalanceService struct {
 	repo       BalanceRepository
-	ranking    RankingRepository
 	pointTypes PointTypeRepository
+	scores     *scoreWriter
+	windows    *windowScores
 }
 
-func NewBalanceService(repo BalanceRepository, ranking RankingRepository, pts PointTypeRepository) *BalanceService {
-	return &BalanceService{repo: repo, ranking: ranking, pointTypes: pts}
+func NewBalanceService(repo BalanceRepository, ranking RankingRepository, pts PointTypeRepository, seasons SeasonRepository) *BalanceService {
+	return &BalanceService{repo: repo, pointTypes: pts, scores: newScoreWriter(ranking, pts), windows: newWindowScores(ranking, seasons)}
 }
 
-func (s *BalanceService) Credit(ctx context.Context, req BalanceCreditRequest) error {
+// Credit adds points to a user balance. When req.IdempotencyKey is set and was
+// already used for the same credit, the original transaction is returned and
+// the balance is left untouched.
+func (s *BalanceService) Credit(ctx context.Context, req BalanceCreditRequest) (*d.Transaction, error) {
 	if req.Amount <= 0 {
-		return nil
+		return nil, nil
 	}
 	pt, err := s.pointTypes.GetPointTypeByURI(ctx, req.URI)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+	var res *d.Transaction
+	var replayed bool
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
 		ub, err := s.repo.GetUserBalanceForUpdate(ctx, req.UserID, pt.ID)
 		if err != nil {
 			return err
 		}
+		want := d.Transaction{UserID: req.UserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionCredit, Reason: req.Reason, IdempotencyKey: req.IdempotencyKey}
+		if prev, err := s.replay(ctx, want); err != nil || prev != nil {
+			res, replayed = prev, prev != nil
+			return err
+		}
 		before := ub.Balance
 		ub.Balance += req.Amount
 		if err := s.repo.UpsertUserBalance(ctx, *ub); err != nil {
 			return err
 		}
-		_, err = s.repo.InsertTransaction(ctx, d.Transaction{UserID: req.UserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionCredit, Reason: req.Reason, Before: before, After: ub.Balance})
+		want.Before, want.After = before, ub.Balance
+		want.ID, err = s.repo.InsertTransaction(ctx, want)
 		if err != nil {
 			return err
 		}
-		if s.ranking != nil {
-			_ = s.ranking.UpdateUserScore(ctx, pt.ID, req.UserID, ub.Balance)
+		if err := addLot(ctx, s.repo, pt, want, time.Now()); err != nil {
+			return err
 		}
+		s.scores.setBalance(ctx, pt.ID, req.UserID, ub.Balance)
+		res = &want
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	if !replayed {
+		s.scores.committed(ctx, nil, *res)
+		s.windows.record(ctx, []d.Transaction{*res}, time.Now())
+	}
+	return res, nil
 }
 
-func (s *BalanceService) Debit(ctx context.Context, req BalanceDebitRequest) error {
+// Debit removes points from a user balance. Idempotency follows Credit.
+func (s *BalanceService) Debit(ctx context.Context, req BalanceDebitRequest) (*d.Transaction, error) {
 	if req.Amount <= 0 {
-		return nil
+		return nil, nil
 	}
 	pt, err := s.pointTypes.GetPointTypeByURI(ctx, req.URI)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+	var res *d.Transaction
+	var replayed bool
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
 		ub, err := s.repo.GetUserBalanceForUpdate(ctx, req.UserID, pt.ID)
 		if err != nil {
 			return err
 		}
-		if ub.Balance < req.Amount {
+		want := d.Transaction{UserID: req.UserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionDebit, Reason: req.Reason, IdempotencyKey: req.IdempotencyKey}
+		if prev, err := s.replay(ctx, want); err != nil || prev != nil {
+			res, replayed = prev, prev != nil
+			return err
+		}
+		if ub.Available() < req.Amount {
 			return d.ErrInsufficientBalance
 		}
+		if err := consumeLots(ctx, s.repo, req.UserID, pt.ID, ub.Balance, req.Amount); err != nil {
+			return err
+		}
 		before := ub.Balance
 		ub.Balance -= req.Amount
 		if err := s.repo.UpsertUserBalance(ctx, *ub); err != nil {
 			return err
 		}
-		_, err = s.repo.InsertTransaction(ctx, d.Transaction{UserID: req.UserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionDebit, Reason: req.Reason, Before: before, After: ub.Balance})
+		want.Before, want.After = before, ub.Balance
+		want.ID, err = s.repo.InsertTransaction(ctx, want)
 		if err != nil {
 			return err
 		}
-		if s.ranking != nil {
-			_ = s.ranking.UpdateUserScore(ctx, pt.ID, req.UserID, ub.Balance)
+		s.scores.setBalance(ctx, pt.ID, req.UserID, ub.Balance)
+		res = &want
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !replayed {
+		s.scores.committed(ctx, nil, *res)
+	}
+	return res, nil
+}
+
+// Transfer atomically moves req.Amount of one point type from req.FromUserID to
+// req.ToUserID. Both balance rows are locked in user ID order so concurrent
+// transfers in opposite directions cannot deadlock.
+func (s *BalanceService) Transfer(ctx context.Context, req BalanceTransferRequest) (*BalanceTransferResult, error) {
+	if req.Amount <= 0 || req.FromUserID == "" || req.ToUserID == "" || req.FromUserID == req.ToUserID {
+		return nil, d.ErrInvalidTransfer
+	}
+	pt, err := s.pointTypes.GetPointTypeByURI(ctx, req.URI)
+	if err != nil {
+		return nil, err
+	}
+	if !pt.TransferEnabled {
+		return nil, d.ErrTransferDisabled
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "transfer"
+	}
+	res := &BalanceTransferResult{TransferID: newID()}
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		first, second := req.FromUserID, req.ToUserID
+		if second < first {
+			first, second = second, first
+		}
+		locked := map[string]*d.UserBalance{}
+		for _, userID := range []string{first, second} {
+			ub, err := s.repo.GetUserBalanceForUpdate(ctx, userID, pt.ID)
+			if err != nil {
+				return err
+			}
+			locked[userID] = ub
+		}
+		from, to := locked[req.FromUserID], locked[req.ToUserID]
+		if from.Available() < req.Amount {
+			return d.ErrInsufficientBalance
+		}
+		if err := consumeLots(ctx, s.repo, from.UserID, pt.ID, from.Balance, req.Amount); err != nil {
+			return err
+		}
+
+		res.Debit = d.Transaction{UserID: req.FromUserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionDebit, Reason: reason, Before: from.Balance, TransferID: res.TransferID}
+		from.Balance -= req.Amount
+		res.Debit.After = from.Balance
+		res.Credit = d.Transaction{UserID: req.ToUserID, PointTypeID: pt.ID, Amount: req.Amount, Type: d.TransactionCredit, Reason: reason, Before: to.Balance, TransferID: res.TransferID}
+		to.Balance += req.Amount
+		res.Credit.After = to.Balance
+
+		for _, ub := range []*d.UserBalance{from, to} {
+			if err := s.repo.UpsertUserBalance(ctx, *ub); err != nil {
+				return err
+			}
+		}
+		if res.Debit.ID, err = s.repo.InsertTransaction(ctx, res.Debit); err != nil {
+			return err
+		}
+		if res.Credit.ID, err = s.repo.InsertTransaction(ctx, res.Credit); err != nil {
+			return err
 		}
+		if err := addLot(ctx, s.repo, pt, res.Credit, time.Now()); err != nil {
+			return err
+		}
+		s.scores.setBalances(ctx, []ScoreUpdate{{PointTypeID: pt.ID, UserID: from.UserID, Score: from.Balance}, {PointTypeID: pt.ID, UserID: to.UserID, Score: to.Balance}})
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	s.scores.committed(ctx, nil, res.Debit, res.Credit)
+	return res, nil
+}
+
+// replay looks up a previous transaction recorded under want.IdempotencyKey.
+// It returns the stored transaction when the request matches it, and
+// ErrIdempotencyKeyConflict when the key was used for a different operation.
+// Callers must hold the user balance row lock so duplicates are serialized.
+func (s *BalanceService) replay(ctx context.Context, want d.Transaction) (*d.Transaction, error) {
+	if want.IdempotencyKey == "" {
+		return nil, nil
+	}
+	prev, err := s.repo.GetTransactionByIdempotencyKey(ctx, want.IdempotencyKey)
+	if err != nil || prev == nil {
+		return nil, err
+	}
+	if prev.UserID != want.UserID || prev.PointTypeID != want.PointTypeID || prev.Amount != want.Amount || prev.Type != want.Type {
+		return nil, d.ErrIdempotencyKeyConflict
+	}
+	return prev, nil
+}
+
+// GetBalance returns a user's balance of one point type together with the
+// amount that expires within the given window (0 disables the lookup).
+func (s *BalanceService) GetBalance(ctx context.Context, userID, uri string, expiringWithin time.Duration) (*UserBalanceView, error) {
+	pt, err := s.pointTypes.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	ub, err := s.repo.GetUserBalance(ctx, userID, pt.ID)
+	if err != nil {
+		return nil, err
+	}
+	view := &UserBalanceView{UserBalance: *ub}
+	if expiringWithin > 0 && pt.ExpiryPolicy != "" && pt.ExpiryPolicy != d.ExpiryNone {
+		view.ExpiringBefore = time.Now().Add(expiringWithin).Unix()
+		if view.ExpiringSoon, err = s.repo.SumExpiringLots(ctx, userID, pt.ID, view.ExpiringBefore); err != nil {
+			return nil, err
+		}
+	}
+	return view, nil
 }
 
 // ListTransactions returns transactions for a user with optional filters
@@ -0,0 +1,177 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	"github.com/usual2970/acto/internal/log"
+)
+
+// scheduleLockTTL bounds how long a crashed replica can block a scheduled
+// distribution; a run is expected to finish well within it.
+const scheduleLockTTL = 10 * time.Minute
+
+// DistributionScheduleService manages distribution schedules and runs the
+// due ones. Every replica may run the scheduler: a shared lock per schedule
+// and period lets one of them distribute, and the period key recorded with
+// the distribution rejects any second run.
+type DistributionScheduleService struct {
+	repo   DistributionScheduleRepository
+	dist   *DistributionService
+	points PointTypeRepository
+	locker Locker
+}
+
+func NewDistributionScheduleService(repo DistributionScheduleRepository, dist *DistributionService, pts PointTypeRepository, locker Locker) *DistributionScheduleService {
+	return &DistributionScheduleService{repo: repo, dist: dist, points: pts, locker: locker}
+}
+
+func (s *DistributionScheduleService) Create(ctx context.Context, req DistributionScheduleCreateRequest) (*d.DistributionSchedule, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+	if err != nil {
+		return nil, err
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	now := time.Now()
+	sch := d.DistributionSchedule{PointTypeID: pt.ID, Period: req.Period, Cron: req.Cron, TopN: req.TopN, Timezone: req.Timezone, Window: req.Window, Enabled: true, CreatedAt: now.Unix()}
+	if (sch.Period == "") == (sch.Cron == "") {
+		return nil, d.ErrInvalidSchedule
+	}
+	if err := sch.Validate(); err != nil {
+		return nil, err
+	}
+	next, err := sch.Next(now)
+	if err != nil {
+		return nil, err
+	}
+	sch.NextRunAt = next.Unix()
+	if sch.ID, err = s.repo.CreateSchedule(ctx, sch); err != nil {
+		return nil, err
+	}
+	return &sch, nil
+}
+
+func (s *DistributionScheduleService) List(ctx context.Context) ([]d.DistributionSchedule, error) {
+	return s.repo.ListSchedules(ctx)
+}
+
+// Update enables or disables a schedule. A re-enabled schedule resumes at its
+// next run; the periods it missed are not distributed.
+func (s *DistributionScheduleService) Update(ctx context.Context, id int64, req DistributionScheduleUpdateRequest) (*d.DistributionSchedule, error) {
+	sch, err := s.repo.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Enabled != nil && *req.Enabled != sch.Enabled {
+		sch.Enabled = *req.Enabled
+		if sch.Enabled {
+			next, err := sch.Next(time.Now())
+			if err != nil {
+				return nil, err
+			}
+			sch.NextRunAt = next.Unix()
+		}
+	}
+	if err := s.repo.UpdateSchedule(ctx, *sch); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+func (s *DistributionScheduleService) Delete(ctx context.Context, id int64) error {
+	return s.repo.DeleteSchedule(ctx, id)
+}
+
+// Run calls RunDue every interval until ctx is cancelled. Errors are logged,
+// including those before a schedule could record its LastError.
+func (s *DistributionScheduleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.RunDue(ctx, now); err != nil {
+				log.Errorf("distribution schedules: %v", err)
+			}
+		}
+	}
+}
+
+// RunDue runs every schedule due at now and returns how many this replica
+// ran. Runs missed while no scheduler was up collapse into one.
+func (s *DistributionScheduleService) RunDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.ListDueSchedules(ctx, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	var ran int
+	var errs []error
+	for _, sch := range due {
+		ok, err := s.runLocked(ctx, sch, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %d: %w", sch.ID, err))
+		}
+		if ok {
+			ran++
+		}
+	}
+	return ran, errors.Join(errs...)
+}
+
+// runLocked runs one due schedule under its period lock. The schedule is
+// re-read under the lock, since another replica may have just run it.
+func (s *DistributionScheduleService) runLocked(ctx context.Context, due d.DistributionSchedule, now time.Time) (bool, error) {
+	fireAt := time.Unix(due.NextRunAt, 0)
+	if _, end, err := due.RankingWindow(fireAt).Bounds(); err == nil && end.After(now) {
+		// the UTC ranking of the window is still open; retry on a later tick
+		return false, nil
+	}
+	key := due.PeriodKey(fireAt)
+	release, ok, err := s.locker.TryLock(ctx, fmt.Sprintf("distribution:%d:%s", due.ID, key), scheduleLockTTL)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer release()
+	sch, err := s.repo.GetSchedule(ctx, due.ID)
+	if err != nil {
+		return false, err
+	}
+	if !sch.Enabled || sch.NextRunAt != due.NextRunAt {
+		return false, nil
+	}
+
+	rd := d.RewardDistribution{PointTypeID: sch.PointTypeID, ScheduleID: sch.ID, PeriodKey: key}
+	runErr := s.dist.distribute(ctx, rd, sch.TopN, sch.RankingWindow(fireAt), "")
+	sch.LastRunAt, sch.LastError = now.Unix(), ""
+	if errors.Is(runErr, d.ErrDistributionIncomplete) {
+		// the distribution is recorded; its failed payouts are retried on
+		// request and must not hold back later periods
+		sch.LastError = runErr.Error()
+	} else if runErr != nil && !errors.Is(runErr, d.ErrDistributionAlreadyDone) {
+		// keep NextRunAt so the run is retried on the next tick
+		sch.LastError = runErr.Error()
+		if err := s.repo.UpdateSchedule(ctx, *sch); err != nil {
+			return true, err
+		}
+		return true, runErr
+	}
+	next, err := sch.Next(now)
+	if err != nil {
+		return true, err
+	}
+	sch.NextRunAt, sch.LastPeriodKey = next.Unix(), key
+	if err := s.repo.UpdateSchedule(ctx, *sch); err != nil {
+		return true, err
+	}
+	if errors.Is(runErr, d.ErrDistributionIncomplete) {
+		return true, runErr
+	}
+	return true, nil
+}
@@ -1,7 +1,15 @@
 package points
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	d "github.com/usual2970/acto/domain/points"
 )
@@ -9,84 +17,397 @@ import (
 type DistributionService struct {
 	rewards RewardRepository
 	balance BalanceRepository
-	ranking RankingRepository
 	points  PointTypeRepository
+	seasons SeasonRepository
+	snaps   *RankingSnapshotService
+	scores  *scoreWriter
+	windows *windowScores
 }
 
-func NewDistributionService(rew RewardRepository, bal BalanceRepository, rank RankingRepository, pts PointTypeRepository) *DistributionService {
-	return &DistributionService{rewards: rew, balance: bal, ranking: rank, points: pts}
+func NewDistributionService(rew RewardRepository, bal BalanceRepository, rank RankingRepository, pts PointTypeRepository, seasons SeasonRepository, snaps *RankingSnapshotService) *DistributionService {
+	return &DistributionService{rewards: rew, balance: bal, points: pts, seasons: seasons, snaps: snaps, scores: newScoreWriter(rank, pts), windows: newWindowScores(rank, seasons)}
 }
 
-// rankingsService implements RankingsService using repositories.
-type rankingsService struct {
-	ranking RankingRepository
-	points  PointTypeRepository
+// Execute runs a distribution for a point type using current ranking top N and active rules.
+// req.Window selects the ranking, e.g. last week's to reward weekly winners; the
+// zero window uses the all-time ranking. A repeated req.PeriodKey resumes the
+// earlier run if it did not complete and fails with ErrDistributionAlreadyDone
+// otherwise. With req.SnapshotID the standings of that
+// snapshot are rewarded instead, limited to req.TopN when it is set. With
+// req.PlanHash the run fails with ErrPlanChanged unless it would pay out
+// exactly the previewed plan.
+func (s *DistributionService) Execute(ctx context.Context, req DistirbutionsExecuteRequest) error {
+	pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+	if err != nil {
+		return err
+	}
+	snap, topN, window, err := s.source(ctx, pt.ID, req)
+	if err != nil {
+		return err
+	}
+	rd := d.RewardDistribution{PointTypeID: pt.ID, PeriodKey: req.PeriodKey}
+	if snap != nil {
+		rd.SnapshotID = snap.ID
+	}
+	return s.distribute(ctx, rd, topN, window, req.PlanHash)
 }
 
-func NewRankingsService(rank RankingRepository, pts PointTypeRepository) RankingsService {
-	return &rankingsService{ranking: rank, points: pts}
+// Preview evaluates the active rules like Execute would, without writing
+// anything. Previewing a snapshot gives a plan that Execute can reproduce
+// exactly while the pools stay as they are; a plan of the live ranking holds
+// only while the ranking is still.
+func (s *DistributionService) Preview(ctx context.Context, req DistirbutionsExecuteRequest) (*DistributionPlan, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+	if err != nil {
+		return nil, err
+	}
+	snap, topN, window, err := s.source(ctx, pt.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.activeRules(ctx, pt.ID)
+	if err != nil {
+		return nil, err
+	}
+	var entries []d.RankingEntry
+	var participants int64
+	var snapshotID string
+	if snap != nil {
+		snapshotID, participants = snap.ID, snap.Participants
+		entries, err = s.snaps.entries(ctx, snap.ID, topN)
+	} else if topN <= 0 || topN > MaxSnapshotSize {
+		err = d.ErrInvalidSnapshot
+	} else if entries, err = s.snaps.ranks.ranking.GetTop(ctx, pt.ID, window, 0, int64(topN-1)); err == nil {
+		// rank as distribute's snapshot will
+		if err = s.snaps.ranks.assignRanks(ctx, board{pointTypeID: pt.ID, window: window}, entries, 0, d.TieStandard); err == nil {
+			participants, err = s.snaps.ranks.ranking.Count(ctx, pt.ID, window)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.plan(ctx, pt.ID, rules, entries, participants)
+	if err != nil {
+		return nil, err
+	}
+	plan.URI, plan.Window, plan.SnapshotID, plan.TopN = pt.URI, window, snapshotID, topN
+	return plan, nil
 }
 
-func (s *rankingsService) GetTop(ctx context.Context, uri string, limit, offset int) ([]string, error) {
-	var ptID int64
-	if uri != "" && s.points != nil {
-		if pt, err := s.points.GetPointTypeByURI(ctx, uri); err == nil && pt != nil {
-			ptID = pt.ID
+// source resolves the standings a request rewards: an existing snapshot of
+// the point type, or the top N of a ranking window when snap is nil.
+func (s *DistributionService) source(ctx context.Context, pointTypeID int64, req DistirbutionsExecuteRequest) (snap *d.RankingSnapshot, topN int, window d.RankingWindow, err error) {
+	if req.SnapshotID != "" {
+		if snap, err = s.snaps.repo.GetSnapshot(ctx, req.SnapshotID); err != nil {
+			return nil, 0, window, err
+		}
+		if snap.PointTypeID != pointTypeID {
+			return nil, 0, window, d.ErrInvalidSnapshot
 		}
+		topN = snap.Entries
+		if req.TopN > 0 {
+			topN = min(req.TopN, topN)
+		}
+		return snap, topN, snap.Window, nil
 	}
-	if limit <= 0 {
-		limit = 100
+	if window, err = resolveRankingWindow(ctx, s.seasons, req.Window, time.Now()); err != nil {
+		return nil, 0, window, err
 	}
-	start := int64(offset)
-	stop := int64(offset + limit - 1)
-	return s.ranking.GetTop(ctx, ptID, start, stop)
+	return nil, req.TopN, window, nil
 }
 
-// Execute runs a distribution for a point type using current ranking top N and active rules.
-func (s *DistributionService) Execute(ctx context.Context, req DistirbutionsExecuteRequest) error {
-	pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+// distribute rewards the top N of a ranking. Unless rd already names a
+// snapshot, the standings are frozen in a new one first, so the result can be
+// reproduced from the snapshot later. The distribution and one item per
+// recipient are recorded before anything is credited. A second run for the
+// same schedule and period key pays nothing new: it resumes the first run when
+// that one did not complete, and fails with ErrDistributionAlreadyDone
+// otherwise; its snapshot is kept for inspection. A non-empty planHash must
+// match the plan about to be paid.
+func (s *DistributionService) distribute(ctx context.Context, rd d.RewardDistribution, topN int, window d.RankingWindow, planHash string) error {
+	pointTypeID := rd.PointTypeID
+	rules, err := s.activeRules(ctx, pointTypeID)
 	if err != nil {
 		return err
 	}
-	pointTypeID := pt.ID
-	rules, err := s.rewards.ListRules(ctx, pointTypeID)
+	if len(rules) == 0 {
+		return nil
+	}
+	var snap *d.RankingSnapshot
+	if rd.SnapshotID == "" {
+		snap, err = s.snaps.capture(ctx, board{pointTypeID: pointTypeID, window: window}, topN, d.TieStandard)
+		if err != nil {
+			return err
+		}
+		rd.SnapshotID = snap.ID
+	} else if snap, err = s.snaps.repo.GetSnapshot(ctx, rd.SnapshotID); err != nil {
+		return err
+	}
+	top, err := s.snaps.entries(ctx, rd.SnapshotID, topN)
 	if err != nil {
 		return err
 	}
-	if len(rules) == 0 {
-		return nil
+	plan, err := s.plan(ctx, pointTypeID, rules, top, snap.Participants)
+	if err != nil {
+		return err
+	}
+	if planHash != "" && planHash != plan.Hash {
+		return d.ErrPlanChanged
+	}
+	rd.ID, rd.Status = newID(), d.DistributionPending
+	items := make([]d.RewardDistributionItem, len(plan.Lines))
+	for i, line := range plan.Lines {
+		items[i] = d.RewardDistributionItem{DistributionID: rd.ID, Position: line.Position, UserID: line.UserID, RuleID: line.RuleID, RewardPointTypeID: line.RewardPointTypeID, Amount: line.Amount}
 	}
-	users, err := s.ranking.GetTop(ctx, pointTypeID, 0, int64(req.TopN-1))
+	err = s.balance.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := s.rewards.CreateDistribution(ctx, rd); err != nil {
+			return err
+		}
+		// take the pool shares out with the items, so a resumed run does
+		// not take them again
+		for _, pool := range plan.Pools {
+			if pool.Paid == 0 {
+				continue
+			}
+			locked, err := s.rewards.LockRewardPool(ctx, pointTypeID, pool.RewardPointTypeID)
+			if err != nil {
+				return err
+			}
+			if locked.Balance != pool.Balance {
+				return d.ErrPlanChanged
+			}
+			if err := s.rewards.AddToRewardPool(ctx, pointTypeID, pool.RewardPointTypeID, -pool.Paid); err != nil {
+				return err
+			}
+		}
+		return s.rewards.CreateDistributionItems(ctx, items)
+	})
+	if errors.Is(err, d.ErrDistributionAlreadyDone) && rd.PeriodKey != "" {
+		prev, err := s.rewards.GetDistributionByPeriod(ctx, rd.PointTypeID, rd.ScheduleID, rd.PeriodKey)
+		if err != nil {
+			return err
+		}
+		if prev.Status == d.DistributionCompleted {
+			return d.ErrDistributionAlreadyDone
+		}
+		return s.pay(ctx, prev.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.pay(ctx, rd.ID)
+}
+
+// Retry pays the items of a failed or interrupted distribution that are not
+// paid yet.
+func (s *DistributionService) Retry(ctx context.Context, distributionID string) error {
+	rd, err := s.rewards.GetDistribution(ctx, distributionID)
 	if err != nil {
 		return err
 	}
-	distID, err := s.rewards.CreateDistribution(ctx, d.RewardDistribution{SnapshotID: "now", Status: d.DistributionPending})
+	if rd.Status == d.DistributionCompleted {
+		return d.ErrDistributionAlreadyDone
+	}
+	return s.pay(ctx, rd.ID)
+}
+
+// Get returns a distribution with all its items.
+func (s *DistributionService) Get(ctx context.Context, distributionID string) (*DistributionView, error) {
+	rd, err := s.rewards.GetDistribution(ctx, distributionID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.rewards.ListDistributionItems(ctx, distributionID)
+	if err != nil {
+		return nil, err
+	}
+	return &DistributionView{Distribution: *rd, Items: items}, nil
+}
+
+// pay credits the unpaid items of a distribution, each in its own
+// transaction. A failed credit is recorded on its item and leaves the
+// distribution failed with ErrDistributionIncomplete, so it can be retried.
+func (s *DistributionService) pay(ctx context.Context, distributionID string) error {
+	items, err := s.rewards.ListDistributionItems(ctx, distributionID, d.DistributionItemPending, d.DistributionItemFailed)
 	if err != nil {
 		return err
 	}
-	// naive application: apply rewards by index rank (1-based)
-	rank := 1
-	for _, user := range users {
-		for _, rule := range rules {
-			if rank >= rule.MinRank && rank <= rule.MaxRank {
-				// credit reward to user in rule.RewardPointTypeID
-				_ = s.balance.WithTx(ctx, func(ctx context.Context) error {
-					ub, err := s.balance.GetUserBalanceForUpdate(ctx, user, rule.RewardPointTypeID)
-					if err != nil {
-						return err
-					}
-					before := ub.Balance
-					ub.Balance += rule.RewardAmount
-					if err := s.balance.UpsertUserBalance(ctx, *ub); err != nil {
-						return err
-					}
-					_, err = s.balance.InsertTransaction(ctx, d.Transaction{UserID: user, PointTypeID: rule.RewardPointTypeID, Amount: rule.RewardAmount, Type: d.TransactionCredit, Reason: "rank reward", Before: before, After: ub.Balance})
-					return err
-				})
-				break
+	rewardTypes := map[int64]*d.PointType{}
+	var failed int
+	for _, it := range items {
+		var err error
+		pt, ok := rewardTypes[it.RewardPointTypeID]
+		if !ok {
+			pt, err = s.points.GetPointTypeByID(ctx, it.RewardPointTypeID)
+			if err == nil {
+				rewardTypes[it.RewardPointTypeID] = pt
 			}
 		}
-		rank++
+		if err == nil {
+			err = s.payItem(ctx, it.ID, pt)
+		}
+		if err != nil {
+			if err := s.rewards.MarkDistributionItemFailed(ctx, it.ID, err.Error()); err != nil {
+				return err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		if err := s.rewards.MarkDistributionFailed(ctx, distributionID); err != nil {
+			return err
+		}
+		return d.ErrDistributionIncomplete
+	}
+	return s.rewards.MarkDistributionCompleted(ctx, distributionID)
+}
+
+// payItem credits one item under its row lock and links the credit to it in
+// the same transaction, so concurrent or repeated runs pay an item once. The
+// rankings count the credit like any other once it committed.
+func (s *DistributionService) payItem(ctx context.Context, itemID int64, pt *d.PointType) error {
+	var paid *d.Transaction
+	err := s.balance.WithTx(ctx, func(ctx context.Context) error {
+		it, err := s.rewards.LockDistributionItem(ctx, itemID)
+		if err != nil || it.Status == d.DistributionItemPaid {
+			return err
+		}
+		ub, err := s.balance.GetUserBalanceForUpdate(ctx, it.UserID, it.RewardPointTypeID)
+		if err != nil {
+			return err
+		}
+		before := ub.Balance
+		ub.Balance += it.Amount
+		if err := s.balance.UpsertUserBalance(ctx, *ub); err != nil {
+			return err
+		}
+		tx := d.Transaction{UserID: it.UserID, PointTypeID: it.RewardPointTypeID, Amount: it.Amount, Type: d.TransactionCredit, Reason: "rank reward", Before: before, After: ub.Balance}
+		if tx.ID, err = s.balance.InsertTransaction(ctx, tx); err != nil {
+			return err
+		}
+		if err := addLot(ctx, s.balance, pt, tx, time.Now()); err != nil {
+			return err
+		}
+		if err := s.rewards.MarkDistributionItemPaid(ctx, it.ID, tx.ID); err != nil {
+			return err
+		}
+		s.scores.setBalance(ctx, it.RewardPointTypeID, it.UserID, ub.Balance)
+		paid = &tx
+		return nil
+	})
+	if err != nil || paid == nil {
+		return err
+	}
+	s.scores.committed(ctx, nil, *paid)
+	s.windows.record(ctx, []d.Transaction{*paid}, time.Now())
+	return nil
+}
+
+// activeRules returns the rules of a point type that apply now.
+func (s *DistributionService) activeRules(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error) {
+	rules, err := s.rewards.ListRules(ctx, pointTypeID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	res := rules[:0]
+	for _, rule := range rules {
+		if rule.ActiveAt(now) {
+			res = append(res, rule)
+		}
+	}
+	return res, nil
+}
+
+// plan rewards each entry, by its frozen rank, with the first matching rule:
+// rules in order of precedence, threshold rules from the highest threshold
+// down, so no entry is rewarded twice. Entries tied on a rank share its
+// reward; only a tie cut by the top N follows the ranking order. A rule's
+// pool share is split evenly among its recipients, rounding down, and the
+// remainder stays in the pool.
+func (s *DistributionService) plan(ctx context.Context, pointTypeID int64, rules []d.RewardRule, entries []d.RankingEntry, participants int64) (*DistributionPlan, error) {
+	rewardTypes := map[int64]*d.PointType{}
+	pools := map[int64]int64{}
+	for _, rule := range rules {
+		if _, ok := rewardTypes[rule.RewardPointTypeID]; ok {
+			continue
+		}
+		pt, err := s.points.GetPointTypeByID(ctx, rule.RewardPointTypeID)
+		if err != nil {
+			return nil, err
+		}
+		rewardTypes[rule.RewardPointTypeID] = pt
+	}
+	if slices.ContainsFunc(rules, func(r d.RewardRule) bool { return r.ShareBps > 0 }) {
+		balances, err := s.rewards.ListRewardPools(ctx, pointTypeID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range balances {
+			pools[p.RewardPointTypeID] = p.Balance
+		}
+	}
+	rules = slices.Clone(rules)
+	slices.SortStableFunc(rules, func(a, b d.RewardRule) int {
+		if c := cmp.Compare(a.Precedence(), b.Precedence()); c != 0 {
+			return c
+		}
+		if a.Mode == d.RuleThreshold {
+			return cmp.Compare(b.MinScore, a.MinScore)
+		}
+		return cmp.Or(cmp.Compare(a.MinRank, b.MinRank), cmp.Compare(a.PercentileFrom, b.PercentileFrom), strings.Compare(a.ID, b.ID))
+	})
+
+	// match every entry first, since a share depends on how many recipients
+	// its rule has
+	matched := make([]int, len(entries))
+	recipients := make([]int64, len(rules))
+	for i, entry := range entries {
+		matched[i] = slices.IndexFunc(rules, func(r d.RewardRule) bool { return r.Matches(entry.Rank, entry.Score, participants) })
+		if matched[i] >= 0 {
+			recipients[matched[i]]++
+		}
+	}
+	amounts := make([]int64, len(rules))
+	for j, rule := range rules {
+		amounts[j] = rule.RewardAmount
+		if rule.ShareBps > 0 && recipients[j] > 0 {
+			amounts[j] = pools[rule.RewardPointTypeID] * int64(rule.ShareBps) / d.MaxShareBps / recipients[j]
+		}
+	}
+
+	plan := &DistributionPlan{Lines: []DistributionLine{}, Totals: []DistributionTotal{}, Pools: []DistributionPool{}}
+	totals := map[int64]int{}
+	paid := map[int64]int64{}
+	h := sha256.New()
+	for i, entry := range entries {
+		j := matched[i]
+		if j < 0 || amounts[j] == 0 {
+			continue
+		}
+		rule, reward, position := rules[j], rewardTypes[rules[j].RewardPointTypeID], i+1
+		plan.Lines = append(plan.Lines, DistributionLine{Position: position, Rank: entry.Rank, UserID: entry.UserID, Score: entry.Score, RuleID: rule.ID, RewardPointTypeID: reward.ID, RewardURI: reward.URI, Amount: amounts[j]})
+		k, ok := totals[reward.ID]
+		if !ok {
+			k = len(plan.Totals)
+			totals[reward.ID] = k
+			plan.Totals = append(plan.Totals, DistributionTotal{RewardPointTypeID: reward.ID, RewardURI: reward.URI})
+		}
+		plan.Totals[k].Amount += amounts[j]
+		plan.Totals[k].Users++
+		if rule.ShareBps > 0 {
+			paid[reward.ID] += amounts[j]
+		}
+		fmt.Fprintf(h, "%d\t%s\t%d\t%d\n", position, entry.UserID, reward.ID, amounts[j])
+	}
+	for _, rule := range rules {
+		if rule.ShareBps == 0 || slices.ContainsFunc(plan.Pools, func(p DistributionPool) bool { return p.RewardPointTypeID == rule.RewardPointTypeID }) {
+			continue
+		}
+		reward := rewardTypes[rule.RewardPointTypeID]
+		balance := pools[reward.ID]
+		plan.Pools = append(plan.Pools, DistributionPool{RewardPointTypeID: reward.ID, RewardURI: reward.URI, Balance: balance, Paid: paid[reward.ID], Remainder: balance - paid[reward.ID]})
 	}
-	return s.rewards.MarkDistributionCompleted(ctx, distID)
+	plan.Hash = hex.EncodeToString(h.Sum(nil))
+	return plan, nil
 }
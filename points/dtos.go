@@ -1,38 +1,418 @@
 package points
 
+import d "github.com/usual2970/acto/domain/points"
+
 type BalanceCreditRequest struct {
-	UserID string `json:"userId"`
-	URI    string `json:"uri"`
-	Reason string `json:"reason"`
-	Amount int64  `json:"amount"`
+	UserID         string `json:"userId"`
+	URI            string `json:"uri"`
+	Reason         string `json:"reason"`
+	Amount         int64  `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 type BalanceDebitRequest struct {
+	UserID         string `json:"userId"`
+	URI            string `json:"uri"`
+	Reason         string `json:"reason"`
+	Amount         int64  `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// UserBalanceView is a balance as reported to callers, including the amount
+// that expires before ExpiringBefore.
+type UserBalanceView struct {
+	d.UserBalance
+	ExpiringSoon   int64 `json:"expiringSoon"`
+	ExpiringBefore int64 `json:"expiringBefore,omitempty"`
+}
+
+type BalanceHoldRequest struct {
+	UserID     string `json:"userId"`
+	URI        string `json:"uri"`
+	Reason     string `json:"reason"`
+	Amount     int64  `json:"amount"`
+	TTLSeconds int64  `json:"ttlSeconds,omitempty"` // defaults to DefaultHoldTTL
+}
+
+type BalanceTransferRequest struct {
+	FromUserID string `json:"fromUserId"`
+	ToUserID   string `json:"toUserId"`
+	URI        string `json:"uri"`
+	Reason     string `json:"reason"`
+	Amount     int64  `json:"amount"`
+}
+
+// BalanceTransferResult holds the paired ledger entries written by a transfer.
+type BalanceTransferResult struct {
+	TransferID string        `json:"transferId"`
+	Debit      d.Transaction `json:"debit"`
+	Credit     d.Transaction `json:"credit"`
+}
+
+// BalanceBatchMode selects how a batch handles failing items
+type BalanceBatchMode string
+
+const (
+	BatchAtomic     BalanceBatchMode = "atomic"      // all items are applied or none
+	BatchBestEffort BalanceBatchMode = "best_effort" // failing items are skipped
+)
+
+type BalanceBatchItem struct {
 	UserID string `json:"userId"`
 	URI    string `json:"uri"`
-	Reason string `json:"reason"`
 	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
 }
 
+type BalanceBatchRequest struct {
+	Type  d.TransactionType  `json:"type"` // credit or debit
+	Mode  BalanceBatchMode   `json:"mode"` // defaults to atomic
+	Items []BalanceBatchItem `json:"items"`
+}
+
+type BalanceBatchItemResult struct {
+	Index         int    `json:"index"`
+	UserID        string `json:"userId"`
+	URI           string `json:"uri"`
+	Success       bool   `json:"success"`
+	TransactionID string `json:"transactionId,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+type BalanceBatchResult struct {
+	Mode      BalanceBatchMode         `json:"mode"`
+	Succeeded int                      `json:"succeeded"`
+	Failed    int                      `json:"failed"`
+	Items     []BalanceBatchItemResult `json:"items"`
+}
+
+type TransactionReverseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DistirbutionsExecuteRequest runs a distribution on request. With a
+// PeriodKey it runs at most once per point type and key. SnapshotID rewards
+// an existing snapshot of the point type instead of a new one of Window.
+// PlanHash, from a preview, makes the run pay out exactly that plan.
 type DistirbutionsExecuteRequest struct {
-	URI  string `json:"uri"`
-	TopN int    `json:"topN"`
+	URI        string          `json:"uri"`
+	TopN       int             `json:"topN"`
+	Window     d.RankingWindow `json:"window"`
+	PeriodKey  string          `json:"periodKey,omitempty"`
+	SnapshotID string          `json:"snapshotId,omitempty"`
+	PlanHash   string          `json:"planHash,omitempty"`
+}
+
+// DistributionPlan lists the rewards a distribution would pay. Hash
+// identifies the lines, so an approved preview can be executed as is.
+type DistributionPlan struct {
+	URI        string              `json:"uri"`
+	Window     d.RankingWindow     `json:"window"`
+	SnapshotID string              `json:"snapshotId,omitempty"`
+	TopN       int                 `json:"topN"`
+	Lines      []DistributionLine  `json:"lines"`
+	Totals     []DistributionTotal `json:"totals"`
+	Pools      []DistributionPool  `json:"pools"`
+	Hash       string              `json:"planHash"`
+}
+
+// DistributionLine is the reward of one ranked user.
+type DistributionLine struct {
+	Position          int    `json:"position"`
+	Rank              int64  `json:"rank"`
+	UserID            string `json:"userId"`
+	Score             int64  `json:"score"`
+	RuleID            string `json:"ruleId"`
+	RewardPointTypeID int64  `json:"rewardPointTypeId"`
+	RewardURI         string `json:"rewardUri"`
+	Amount            int64  `json:"amount"`
+}
+
+// DistributionPool shows how much of a reward pool a plan pays out; the
+// remainder stays in the pool.
+type DistributionPool struct {
+	RewardPointTypeID int64  `json:"rewardPointTypeId"`
+	RewardURI         string `json:"rewardUri"`
+	Balance           int64  `json:"balance"`
+	Paid              int64  `json:"paid"`
+	Remainder         int64  `json:"remainder"`
+}
+
+// DistributionView is a distribution with the payout state of each recipient.
+type DistributionView struct {
+	Distribution d.RewardDistribution       `json:"distribution"`
+	Items        []d.RewardDistributionItem `json:"items"`
+}
+
+// DistributionTotal sums a plan's rewards of one point type.
+type DistributionTotal struct {
+	RewardPointTypeID int64  `json:"rewardPointTypeId"`
+	RewardURI         string `json:"rewardUri"`
+	Amount            int64  `json:"amount"`
+	Users             int    `json:"users"`
+}
+
+// DistributionScheduleCreateRequest sets either Period or Cron. Timezone is
+// an IANA name and defaults to UTC; Window selects the ranking rewarded.
+type DistributionScheduleCreateRequest struct {
+	URI      string          `json:"uri"`
+	Period   d.RankingPeriod `json:"period,omitempty"`
+	Cron     string          `json:"cron,omitempty"`
+	TopN     int             `json:"topN"`
+	Timezone string          `json:"timezone,omitempty"`
+	Window   d.RankingPeriod `json:"window,omitempty"`
+}
+
+type DistributionScheduleUpdateRequest struct {
+	Enabled *bool `json:"enabled,omitempty"`
 }
 
 type PointTypeCreateRequest struct {
-	URI         string `json:"uri"`
-	DisplayName string `json:"displayName"`
-	Description string `json:"description"`
+	URI             string `json:"uri"`
+	DisplayName     string `json:"displayName"`
+	Description     string `json:"description"`
+	TransferEnabled bool   `json:"transferEnabled"`
+
+	ExpiryPolicy d.ExpiryPolicy `json:"expiryPolicy"`
+	ExpiryDays   int            `json:"expiryDays"`
+
+	RankingMetric d.RankingMetric `json:"rankingMetric"`
+}
+
+// RewardRuleCreateRequest adds a rule to the point type URI that pays in
+// RewardURI. Mode defaults to fixed; see d.RewardRuleMode for the fields
+// each mode uses.
+type RewardRuleCreateRequest struct {
+	URI            string           `json:"uri"`
+	Mode           d.RewardRuleMode `json:"mode,omitempty"`
+	MinRank        int              `json:"minRank,omitempty"`
+	MaxRank        int              `json:"maxRank,omitempty"`
+	PercentileFrom int              `json:"percentileFrom,omitempty"`
+	PercentileTo   int              `json:"percentileTo,omitempty"`
+	MinScore       int64            `json:"minScore,omitempty"`
+	RewardAmount   int64            `json:"rewardAmount,omitempty"`
+	ShareBps       int              `json:"shareBps,omitempty"`
+	RewardURI      string           `json:"rewardUri"`
+	Active         *bool            `json:"active,omitempty"`
+	StartsAt       int64            `json:"startsAt,omitempty"`
+	EndsAt         int64            `json:"endsAt,omitempty"`
+}
+
+// RewardRuleUpdateRequest changes the fields that are set.
+type RewardRuleUpdateRequest struct {
+	Mode           *d.RewardRuleMode `json:"mode,omitempty"`
+	MinRank        *int              `json:"minRank,omitempty"`
+	MaxRank        *int              `json:"maxRank,omitempty"`
+	PercentileFrom *int              `json:"percentileFrom,omitempty"`
+	PercentileTo   *int              `json:"percentileTo,omitempty"`
+	MinScore       *int64            `json:"minScore,omitempty"`
+	RewardAmount   *int64            `json:"rewardAmount,omitempty"`
+	ShareBps       *int              `json:"shareBps,omitempty"`
+	RewardURI      *string           `json:"rewardUri,omitempty"`
+	Active         *bool             `json:"active,omitempty"`
+	StartsAt       *int64            `json:"startsAt,omitempty"`
+	EndsAt         *int64            `json:"endsAt,omitempty"`
+}
+
+// RewardPoolFundRequest adds Amount of RewardURI to a point type's pool.
+type RewardPoolFundRequest struct {
+	RewardURI string `json:"rewardUri"`
+	Amount    int64  `json:"amount"`
 }
 
 // PointTypeUpdateRequest represents the request for updating a point type
 type PointTypeUpdateRequest struct {
-	DisplayName *string `json:"displayName,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Enabled     *bool   `json:"enabled,omitempty"`
+	DisplayName     *string `json:"displayName,omitempty"`
+	Description     *string `json:"description,omitempty"`
+	Enabled         *bool   `json:"enabled,omitempty"`
+	TransferEnabled *bool   `json:"transferEnabled,omitempty"`
+
+	ExpiryPolicy *d.ExpiryPolicy `json:"expiryPolicy,omitempty"`
+	ExpiryDays   *int            `json:"expiryDays,omitempty"`
+
+	RankingMetric *d.RankingMetric `json:"rankingMetric,omitempty"`
+}
+
+// RewardCreateRequest adds a reward to the catalog. Costs are keyed by point
+// type URI; Enabled defaults to true.
+type RewardCreateRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Costs       map[string]int64    `json:"costs"`
+	Quantity    int                 `json:"quantity"`
+	Enabled     *bool               `json:"enabled,omitempty"`
+	Limits      []d.RedemptionLimit `json:"limits,omitempty"`
+}
+
+// RewardUpdateRequest changes the fields that are set; Costs and Limits
+// replace all costs and limits, and an empty Limits removes them.
+type RewardUpdateRequest struct {
+	Name        *string              `json:"name,omitempty"`
+	Description *string              `json:"description,omitempty"`
+	Costs       map[string]int64     `json:"costs,omitempty"`
+	Enabled     *bool                `json:"enabled,omitempty"`
+	Limits      *[]d.RedemptionLimit `json:"limits,omitempty"`
+}
+
+type RewardRestockRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// RewardListRequest filters the rewards of a point type.
+type RewardListRequest struct {
+	Query           string
+	Enabled         *bool
+	InStock         bool
+	IncludeArchived bool
+	Limit           int
+	Offset          int
+}
+
+type RewardPage struct {
+	Items  []d.RedemptionReward `json:"items"`
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
 }
 
 type RedemptionRequest struct {
 	UserID   string `json:"userId"`
 	RewardID string `json:"rewardId"`
 }
+
+// RedemptionTransitionRequest fulfils or cancels an order; Note records the
+// delivery details or the cancellation reason.
+type RedemptionTransitionRequest struct {
+	Note string `json:"note"`
+}
+
+type RedemptionPage struct {
+	Items  []d.RedemptionRecord `json:"items"`
+	Total  int                  `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+// ExportFormat is the file format of a ledger export.
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+)
+
+// ExportTransactionsRequest selects transactions using the same fields as
+// ListTransactions. An empty UserID exports every user.
+type ExportTransactionsRequest struct {
+	Format    ExportFormat
+	UserID    string
+	URI       string
+	Op        string
+	StartTime int64
+	EndTime   int64
+}
+
+// ExportBalancesRequest selects balances; an empty URI exports every point type.
+type ExportBalancesRequest struct {
+	Format ExportFormat
+	URI    string
+}
+
+// ReconcileRequest selects the point type to reconcile; an empty URI checks
+// all of them. Fix repairs drifted balance rows and ranking scores.
+type ReconcileRequest struct {
+	URI string `json:"uri"`
+	Fix bool   `json:"fix"`
+}
+
+// ReconcileReport lists every mismatch found by a reconciliation run.
+type ReconcileReport struct {
+	RunID      string                     `json:"runId"`
+	Fix        bool                       `json:"fix"`
+	StartedAt  int64                      `json:"startedAt"`
+	FinishedAt int64                      `json:"finishedAt"`
+	PointTypes int                        `json:"pointTypes"`
+	Checked    int64                      `json:"checked"` // user and point type pairs compared
+	Mismatches []d.ReconciliationMismatch `json:"mismatches"`
+	Fixed      int                        `json:"fixed"`
+}
+
+// RankingRebuildRequest selects the ranking to rebuild; an empty URI
+// rebuilds all of them.
+type RankingRebuildRequest struct {
+	URI string `json:"uri"`
+}
+
+type RankingRebuildResult struct {
+	URI         string `json:"uri"`
+	PointTypeID int64  `json:"pointTypeId"`
+	Users       int64  `json:"users"`
+	DurationMs  int64  `json:"durationMs"`
+}
+
+// RankingPage is one page of a ranking. Total is the number of ranked users.
+type RankingPage struct {
+	PointType *RankingPointType `json:"pointType,omitempty"`
+	Window    d.RankingWindow   `json:"window"`
+	TiePolicy d.TiePolicy       `json:"tiePolicy"`
+	Items     []d.RankingEntry  `json:"items"`
+	Total     int64             `json:"total"`
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+}
+
+// RankingPointType is the display metadata of the ranked point type.
+type RankingPointType struct {
+	URI         string `json:"uri"`
+	DisplayName string `json:"displayName"`
+}
+
+// RankingUserView is a single user's position in a ranking.
+type RankingUserView struct {
+	PointType *RankingPointType `json:"pointType,omitempty"`
+	Window    d.RankingWindow   `json:"window"`
+	TiePolicy d.TiePolicy       `json:"tiePolicy"`
+	User      d.RankingEntry    `json:"user"`
+	Total     int64             `json:"total"`
+}
+
+// RankingAroundView is a user with their neighbours above and below.
+type RankingAroundView struct {
+	PointType *RankingPointType `json:"pointType,omitempty"`
+	Window    d.RankingWindow   `json:"window"`
+	TiePolicy d.TiePolicy       `json:"tiePolicy"`
+	User      d.RankingEntry    `json:"user"`
+	Items     []d.RankingEntry  `json:"items"`
+	Total     int64             `json:"total"`
+}
+
+// RankingSeasonCreateRequest defines a season by name and unix time bounds;
+// EndsAt is exclusive.
+type RankingSeasonCreateRequest struct {
+	Name     string `json:"name"`
+	StartsAt int64  `json:"startsAt"`
+	EndsAt   int64  `json:"endsAt"`
+}
+
+// RankingSnapshotCreateRequest freezes the top TopN of a ranking.
+type RankingSnapshotCreateRequest struct {
+	URI       string          `json:"uri"`
+	Window    d.RankingWindow `json:"window"`
+	TopN      int             `json:"topN"`
+	TiePolicy d.TiePolicy     `json:"tiePolicy"`
+}
+
+type RankingSnapshotPage struct {
+	Items  []d.RankingSnapshot `json:"items"`
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+// RankingSnapshotView is a snapshot with a page of its entries.
+type RankingSnapshotView struct {
+	Snapshot d.RankingSnapshot `json:"snapshot"`
+	Items    []d.RankingEntry  `json:"items"`
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+}
@@ -0,0 +1,150 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	"github.com/usual2970/acto/internal/log"
+)
+
+// ExpiredReason is the transaction reason used when lots are written off.
+const ExpiredReason = "expired"
+
+// expiryBatchSize bounds how many lots one ExpireDue pass loads at a time.
+const expiryBatchSize = 500
+
+// ExpiryService writes off the unspent remainder of lots past their expiry and
+// releases balance holds that were neither captured nor released in time.
+type ExpiryService struct {
+	balance BalanceRepository
+	scores  *scoreWriter
+}
+
+func NewExpiryService(bal BalanceRepository, rank RankingRepository, pts PointTypeRepository) *ExpiryService {
+	return &ExpiryService{balance: bal, scores: newScoreWriter(rank, pts)}
+}
+
+// Run calls ReleaseExpiredHolds and ExpireDue every interval until ctx is
+// cancelled. Holds go first, so points they free expire in the same pass.
+func (s *ExpiryService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.ReleaseExpiredHolds(ctx, now); err != nil {
+				log.Errorf("point expiry: release holds: %v", err)
+			}
+			if _, err := s.ExpireDue(ctx, now); err != nil {
+				log.Errorf("point expiry: %v", err)
+			}
+		}
+	}
+}
+
+// ExpireDue debits every lot that expired at or before now and returns the
+// number of lots written off. Failures on individual lots do not stop the pass.
+// Points of a lot that are held stay on the lot until the hold is captured,
+// which spends them, or released, after which a later pass writes them off.
+func (s *ExpiryService) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	var expired int
+	var errs []error
+	for {
+		lots, err := s.balance.ListExpiredLots(ctx, now.Unix(), expiryBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		progressed := false
+		for _, lot := range lots {
+			changed, err := s.expireLot(ctx, lot)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if changed {
+				progressed = true
+				expired++
+			}
+		}
+		if len(lots) < expiryBatchSize || !progressed {
+			return expired, errors.Join(errs...)
+		}
+	}
+}
+
+// expireLot debits the available part of a lot's remainder and reports
+// whether the lot changed. It locks the balance row before the lot, matching
+// the order used by debits, and re-reads the lot so a concurrent debit is
+// observed.
+func (s *ExpiryService) expireLot(ctx context.Context, expired d.BalanceLot) (bool, error) {
+	var written *d.Transaction
+	var changed bool
+	err := s.balance.WithTx(ctx, func(ctx context.Context) error {
+		ub, err := s.balance.GetUserBalanceForUpdate(ctx, expired.UserID, expired.PointTypeID)
+		if err != nil {
+			return err
+		}
+		lot, err := s.balance.GetLotForUpdate(ctx, expired.ID)
+		if err != nil {
+			return err
+		}
+		amount := max(min(lot.Remaining, ub.Available()), 0)
+		// the held rest stays on the lot for a later pass
+		if keep := min(lot.Remaining-amount, max(ub.Held, 0)); keep != lot.Remaining {
+			if err := s.balance.UpdateLotRemaining(ctx, lot.ID, keep); err != nil {
+				return err
+			}
+			changed = true
+		}
+		if amount == 0 {
+			return nil
+		}
+		before := ub.Balance
+		ub.Balance -= amount
+		if err := s.balance.UpsertUserBalance(ctx, *ub); err != nil {
+			return err
+		}
+		tx := d.Transaction{UserID: lot.UserID, PointTypeID: lot.PointTypeID, Amount: amount, Type: d.TransactionDebit, Reason: ExpiredReason, Before: before, After: ub.Balance}
+		if tx.ID, err = s.balance.InsertTransaction(ctx, tx); err != nil {
+			return err
+		}
+		s.scores.setBalance(ctx, lot.PointTypeID, lot.UserID, ub.Balance)
+		written = &tx
+		return nil
+	})
+	if err == nil && written != nil {
+		s.scores.committed(ctx, nil, *written)
+	}
+	return changed, err
+}
+
+// ReleaseExpiredHolds releases every active hold that expired at or before now
+// and returns the number of holds released.
+func (s *ExpiryService) ReleaseExpiredHolds(ctx context.Context, now time.Time) (int, error) {
+	holds, err := s.balance.ListExpiredHolds(ctx, now.Unix(), expiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var released int
+	var errs []error
+	for _, h := range holds {
+		err := s.balance.WithTx(ctx, func(ctx context.Context) error {
+			hold, err := s.balance.GetHoldForUpdate(ctx, h.ID)
+			if err != nil || hold.Status != d.HoldActive {
+				return err
+			}
+			_, err = releaseHold(ctx, s.balance, hold, d.HoldExpired)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		released++
+	}
+	return released, errors.Join(errs...)
+}
@@ -0,0 +1,185 @@
+package points
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// exportFlushRows is how many records are written between flushes.
+const exportFlushRows = 1000
+
+// ExportService streams the ledger and balances out of the database without
+// buffering them in memory. Point type IDs are written as URIs.
+type ExportService struct {
+	balance BalanceRepository
+	points  PointTypeRepository
+}
+
+func NewExportService(bal BalanceRepository, pts PointTypeRepository) *ExportService {
+	return &ExportService{balance: bal, points: pts}
+}
+
+type exportedTransaction struct {
+	ID             string `json:"id"`
+	UserID         string `json:"userId"`
+	URI            string `json:"uri"`
+	Amount         int64  `json:"amount"`
+	Type           string `json:"type"`
+	Reason         string `json:"reason"`
+	Before         int64  `json:"before"`
+	After          int64  `json:"after"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	TransferID     string `json:"transferId,omitempty"`
+	HoldID         string `json:"holdId,omitempty"`
+	ReversalOf     string `json:"reversalOf,omitempty"`
+	ReversedBy     string `json:"reversedBy,omitempty"`
+	CreatedAt      int64  `json:"createdAt"`
+}
+
+func (t exportedTransaction) csvRecord() []string {
+	return []string{t.ID, t.UserID, t.URI, strconv.FormatInt(t.Amount, 10), t.Type, t.Reason, strconv.FormatInt(t.Before, 10), strconv.FormatInt(t.After, 10), t.IdempotencyKey, t.TransferID, t.HoldID, t.ReversalOf, t.ReversedBy, strconv.FormatInt(t.CreatedAt, 10)}
+}
+
+var transactionCSVHeader = []string{"id", "user_id", "uri", "amount", "type", "reason", "before", "after", "idempotency_key", "transfer_id", "hold_id", "reversal_of", "reversed_by", "created_at"}
+
+type exportedBalance struct {
+	UserID    string `json:"userId"`
+	URI       string `json:"uri"`
+	Balance   int64  `json:"balance"`
+	Held      int64  `json:"held"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+func (b exportedBalance) csvRecord() []string {
+	return []string{b.UserID, b.URI, strconv.FormatInt(b.Balance, 10), strconv.FormatInt(b.Held, 10), strconv.FormatInt(b.UpdatedAt, 10)}
+}
+
+var balanceCSVHeader = []string{"user_id", "uri", "balance", "held", "updated_at"}
+
+// ExportTransactions validates req and then streams matching transactions to
+// the writer returned by open. open is called only once validation passed, so
+// callers can still report errors before committing to a response.
+func (s *ExportService) ExportTransactions(ctx context.Context, req ExportTransactionsRequest, open func() io.Writer) error {
+	var filter TransactionFilter
+	if req.URI != "" {
+		pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+		if err != nil {
+			return err
+		}
+		filter.PointTypeID = pt.ID
+	}
+	filter.OperationType, filter.StartTime, filter.EndTime = req.Op, req.StartTime, req.EndTime
+	enc, err := newExportEncoder(req.Format, transactionCSVHeader, open)
+	if err != nil {
+		return err
+	}
+	uris := s.uriResolver(ctx)
+	err = s.balance.StreamTransactions(ctx, req.UserID, filter, func(t d.Transaction) error {
+		return enc.write(exportedTransaction{ID: t.ID, UserID: t.UserID, URI: uris(t.PointTypeID), Amount: t.Amount, Type: string(t.Type), Reason: t.Reason, Before: t.Before, After: t.After, IdempotencyKey: t.IdempotencyKey, TransferID: t.TransferID, HoldID: t.HoldID, ReversalOf: t.ReversalOf, ReversedBy: t.ReversedBy, CreatedAt: t.CreatedAt})
+	})
+	if err != nil {
+		return err
+	}
+	return enc.flush()
+}
+
+// ExportBalances streams user balances like ExportTransactions.
+func (s *ExportService) ExportBalances(ctx context.Context, req ExportBalancesRequest, open func() io.Writer) error {
+	var pointTypeID int64
+	if req.URI != "" {
+		pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+		if err != nil {
+			return err
+		}
+		pointTypeID = pt.ID
+	}
+	enc, err := newExportEncoder(req.Format, balanceCSVHeader, open)
+	if err != nil {
+		return err
+	}
+	uris := s.uriResolver(ctx)
+	err = s.balance.StreamUserBalances(ctx, pointTypeID, func(ub d.UserBalance) error {
+		return enc.write(exportedBalance{UserID: ub.UserID, URI: uris(ub.PointTypeID), Balance: ub.Balance, Held: ub.Held, UpdatedAt: ub.UpdatedAt})
+	})
+	if err != nil {
+		return err
+	}
+	return enc.flush()
+}
+
+// uriResolver returns a cached point type ID to URI lookup. Unknown or
+// deleted point types are written as their numeric ID.
+func (s *ExportService) uriResolver(ctx context.Context) func(int64) string {
+	cache := map[int64]string{}
+	return func(id int64) string {
+		if uri, ok := cache[id]; ok {
+			return uri
+		}
+		uri := strconv.FormatInt(id, 10)
+		if pt, err := s.points.GetPointTypeByID(ctx, id); err == nil && pt != nil {
+			uri = pt.URI
+		}
+		cache[id] = uri
+		return uri
+	}
+}
+
+type csvRecorder interface {
+	csvRecord() []string
+}
+
+// exportEncoder writes records as CSV or JSONL and flushes periodically so
+// data reaches the client while the cursor is still open.
+type exportEncoder struct {
+	csv   *csv.Writer
+	json  *json.Encoder
+	out   io.Writer
+	count int
+}
+
+func newExportEncoder(format ExportFormat, header []string, open func() io.Writer) (*exportEncoder, error) {
+	switch format {
+	case ExportCSV:
+		w := open()
+		enc := &exportEncoder{csv: csv.NewWriter(w), out: w}
+		return enc, enc.csv.Write(header)
+	case ExportJSONL:
+		w := open()
+		return &exportEncoder{json: json.NewEncoder(w), out: w}, nil
+	}
+	return nil, d.ErrInvalidExport
+}
+
+func (e *exportEncoder) write(rec csvRecorder) error {
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(rec.csvRecord())
+	} else {
+		err = e.json.Encode(rec)
+	}
+	if err != nil {
+		return err
+	}
+	if e.count++; e.count%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := e.out.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package points
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// newID returns a random 128-bit identifier encoded as 32 hex characters.
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,331 @@
+package points
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+	"github.com/usual2970/acto/internal/log"
+)
+
+// importFlushRows is how many rows are processed between progress updates.
+const importFlushRows = 500
+
+const (
+	// importHeartbeat is how often a running job touches its updated_at.
+	importHeartbeat = 30 * time.Second
+	// importStaleAfter is how long a pending or running job may go without
+	// an update before it is taken as orphaned by a crash or restart.
+	importStaleAfter = 2 * time.Minute
+)
+
+// importInterrupted is the error of a job whose process went away. The
+// spooled file went with it; uploading the same file again is safe, as the
+// rows already credited are skipped by their idempotency keys.
+const importInterrupted = "import interrupted by a restart; upload the file again to finish it"
+
+// errImportRead marks errors reading the file itself, which fail the job
+// instead of a single row.
+var errImportRead = errors.New("read import file")
+
+// ImportService loads balances from uploaded CSV or JSONL files in the
+// background. Every row is credited through BalanceService with an
+// idempotency key derived from the row, so re-running a file never credits a
+// row twice. Jobs run in the process that accepted the upload; Run fails the
+// jobs a crashed or restarted process left behind, which are re-submitted by
+// uploading the file again.
+type ImportService struct {
+	jobs    ImportJobRepository
+	points  PointTypeRepository
+	balance *BalanceService
+}
+
+func NewImportService(jobs ImportJobRepository, pts PointTypeRepository, bal *BalanceService) *ImportService {
+	return &ImportService{jobs: jobs, points: pts, balance: bal}
+}
+
+// importRow is one record of an import file
+type importRow struct {
+	UserID     string `json:"userId"`
+	URI        string `json:"uri"`
+	Amount     int64  `json:"amount"`
+	Reason     string `json:"reason"`
+	ExternalID string `json:"externalId"`
+}
+
+// Start spools file to disk, records a pending job and processes it in the
+// background. The returned job can be polled with GetJob.
+func (s *ImportService) Start(ctx context.Context, format d.ImportFormat, file io.Reader) (*d.ImportJob, error) {
+	if format != d.ImportCSV && format != d.ImportJSONL {
+		return nil, d.ErrInvalidImport
+	}
+	tmp, err := os.CreateTemp("", "acto-import-*")
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), file); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	job := d.ImportJob{ID: newID(), Format: format, FileHash: hex.EncodeToString(h.Sum(nil)), Status: d.ImportPending}
+	if err := s.jobs.CreateImportJob(ctx, job); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	// the job outlives the upload request
+	go s.run(context.WithoutCancel(ctx), job, tmp.Name())
+	return &job, nil
+}
+
+func (s *ImportService) GetJob(ctx context.Context, jobID string) (*d.ImportJob, error) {
+	return s.jobs.GetImportJob(ctx, jobID)
+}
+
+func (s *ImportService) ListErrors(ctx context.Context, jobID string, limit, offset int) ([]d.ImportRowError, int, error) {
+	if _, err := s.jobs.GetImportJob(ctx, jobID); err != nil {
+		return nil, 0, err
+	}
+	return s.jobs.ListImportRowErrors(ctx, jobID, limit, offset)
+}
+
+// Run fails orphaned jobs at startup and then periodically, until ctx is
+// cancelled.
+func (s *ImportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(importStaleAfter)
+	defer ticker.Stop()
+	now := time.Now()
+	for {
+		if n, err := s.FailStale(ctx, now); err != nil {
+			log.Errorf("import jobs: fail stale: %v", err)
+		} else if n > 0 {
+			log.Infof("import jobs: failed %d interrupted jobs", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+// FailStale marks failed the pending and running jobs not updated for
+// importStaleAfter, whose process is gone, and returns how many there were.
+// Live jobs are never stale: run touches them every importHeartbeat.
+func (s *ImportService) FailStale(ctx context.Context, now time.Time) (int, error) {
+	return s.jobs.FailStaleImportJobs(ctx, now.Add(-importStaleAfter).Unix(), importInterrupted)
+}
+
+func (s *ImportService) run(ctx context.Context, job d.ImportJob, path string) {
+	defer os.Remove(path)
+	job.Status = d.ImportRunning
+	_ = s.jobs.UpdateImportJob(ctx, job)
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.heartbeat(ctx, job.ID, done)
+
+	if err := s.process(ctx, &job, path); err != nil {
+		job.Status = d.ImportFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = d.ImportCompleted
+	}
+	_ = s.jobs.UpdateImportJob(ctx, job)
+}
+
+// heartbeat keeps a running job from looking stale until done is closed.
+func (s *ImportService) heartbeat(ctx context.Context, jobID string, done <-chan struct{}) {
+	ticker := time.NewTicker(importHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_ = s.jobs.TouchImportJob(ctx, jobID)
+		}
+	}
+}
+
+func (s *ImportService) process(ctx context.Context, job *d.ImportJob, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var next func() (importRow, string, error)
+	if job.Format == d.ImportCSV {
+		next, err = csvRows(f)
+		if err != nil {
+			return err
+		}
+	} else {
+		next = jsonlRows(f)
+	}
+
+	pts := map[string]*d.PointType{}
+	var rowErrs []d.ImportRowError
+	flush := func() error {
+		if err := s.jobs.InsertImportRowErrors(ctx, rowErrs); err != nil {
+			return err
+		}
+		rowErrs = rowErrs[:0]
+		return s.jobs.UpdateImportJob(ctx, *job)
+	}
+	for {
+		row, raw, err := next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errImportRead) {
+			_ = flush()
+			return err
+		}
+		job.ProcessedRows++
+		if err == nil {
+			err = s.apply(ctx, job, pts, row)
+		}
+		if err != nil {
+			job.FailedRows++
+			rowErrs = append(rowErrs, d.ImportRowError{JobID: job.ID, Row: job.ProcessedRows, Data: raw, Error: err.Error()})
+		} else {
+			job.SucceededRows++
+		}
+		if job.ProcessedRows%importFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// apply validates a row against the point type catalog and credits it.
+func (s *ImportService) apply(ctx context.Context, job *d.ImportJob, pts map[string]*d.PointType, row importRow) error {
+	if row.UserID == "" || row.URI == "" {
+		return errors.New("missing user_id or uri")
+	}
+	if row.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	pt, ok := pts[row.URI]
+	if !ok {
+		pt, _ = s.points.GetPointTypeByURI(ctx, row.URI)
+		pts[row.URI] = pt
+	}
+	if pt == nil {
+		return d.ErrPointTypeNotFound
+	}
+	if !pt.Enabled {
+		return errors.New("point type disabled")
+	}
+	// the key identifies the row across re-runs of the same file, or across
+	// files when the source system provides its own ID
+	key := fmt.Sprintf("import:%s:%d", job.FileHash[:32], job.ProcessedRows)
+	if row.ExternalID != "" {
+		key = "import:" + row.ExternalID
+	}
+	if len(key) > 128 {
+		return errors.New("external_id too long")
+	}
+	reason := row.Reason
+	if reason == "" {
+		reason = "import"
+	}
+	_, err := s.balance.Credit(ctx, BalanceCreditRequest{UserID: row.UserID, URI: row.URI, Amount: row.Amount, Reason: reason, IdempotencyKey: key})
+	return err
+}
+
+// csvRows reads a CSV file whose first line names the columns. Column names
+// are matched case-insensitively, ignoring underscores: user_id, uri, amount,
+// reason and external_id.
+func csvRows(r io.Reader) (func() (importRow, string, error), error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, d.ErrInvalidImport
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", ""))] = i
+	}
+	for _, required := range []string{"userid", "uri", "amount"} {
+		if _, ok := cols[required]; !ok {
+			return nil, d.ErrInvalidImport
+		}
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	return func() (importRow, string, error) {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return importRow{}, "", io.EOF
+		}
+		raw := strings.Join(rec, ",")
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return importRow{}, raw, err
+		}
+		if err != nil {
+			return importRow{}, raw, fmt.Errorf("%w: %v", errImportRead, err)
+		}
+		amount, err := strconv.ParseInt(field(rec, "amount"), 10, 64)
+		if err != nil {
+			return importRow{}, raw, errors.New("invalid amount")
+		}
+		return importRow{
+			UserID:     field(rec, "userid"),
+			URI:        field(rec, "uri"),
+			Amount:     amount,
+			Reason:     field(rec, "reason"),
+			ExternalID: field(rec, "externalid"),
+		}, raw, nil
+	}, nil
+}
+
+// jsonlRows reads one JSON object per line, skipping blank lines.
+func jsonlRows(r io.Reader) func() (importRow, string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	return func() (importRow, string, error) {
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			var row importRow
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				return importRow{}, line, errors.New("invalid json")
+			}
+			return row, line, nil
+		}
+		if err := sc.Err(); err != nil {
+			return importRow{}, "", fmt.Errorf("%w: %v", errImportRead, err)
+		}
+		return importRow{}, "", io.EOF
+	}
+}
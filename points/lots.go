@@ -0,0 +1,55 @@
+package points
+
+import (
+	"context"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// addLot records a credit as a lot when the point type has an expiry policy.
+// It must run inside BalanceRepository.WithTx together with the credit.
+func addLot(ctx context.Context, repo BalanceRepository, pt *d.PointType, tx d.Transaction, now time.Time) error {
+	if pt == nil || pt.ExpiryPolicy == "" || pt.ExpiryPolicy == d.ExpiryNone {
+		return nil
+	}
+	_, err := repo.InsertLot(ctx, d.BalanceLot{
+		UserID:        tx.UserID,
+		PointTypeID:   tx.PointTypeID,
+		TransactionID: tx.ID,
+		Amount:        tx.Amount,
+		Remaining:     tx.Amount,
+		EarnedAt:      now.Unix(),
+		ExpiresAt:     pt.LotExpiry(now),
+	})
+	return err
+}
+
+// consumeLots takes amount from the user's open lots oldest-first. balance is
+// the balance before the debit; any part of it not covered by lots was credited
+// while no expiry policy applied and is treated as the oldest and spent first.
+// It must run inside BalanceRepository.WithTx after the balance row is locked.
+func consumeLots(ctx context.Context, repo BalanceRepository, userID string, pointTypeID int64, balance, amount int64) error {
+	lots, err := repo.ListOpenLotsForUpdate(ctx, userID, pointTypeID)
+	if err != nil || len(lots) == 0 {
+		return err
+	}
+	var tracked int64
+	for _, l := range lots {
+		tracked += l.Remaining
+	}
+	if untracked := balance - tracked; untracked > 0 {
+		amount -= min(untracked, amount)
+	}
+	for _, l := range lots {
+		if amount <= 0 {
+			break
+		}
+		take := min(l.Remaining, amount)
+		if err := repo.UpdateLotRemaining(ctx, l.ID, l.Remaining-take); err != nil {
+			return err
+		}
+		amount -= take
+	}
+	return nil
+}
@@ -21,11 +21,24 @@ func (s *PointTypeService) Create(ctx context.Context, req PointTypeCreateReques
 	if uri == "" {
 		return "", errors.New("uri cannot be empty")
 	}
+	if err := validateExpiry(req.ExpiryPolicy, req.ExpiryDays); err != nil {
+		return "", err
+	}
+	if req.RankingMetric == "" {
+		req.RankingMetric = d.MetricBalance
+	}
+	if !req.RankingMetric.Valid() {
+		return "", d.ErrInvalidRankingMetric
+	}
 	rs, err := s.repo.CreatePointType(ctx, d.PointType{
-		URI:         uri,
-		DisplayName: strings.TrimSpace(req.DisplayName),
-		Description: strings.TrimSpace(req.Description),
-		Enabled:     true,
+		URI:             uri,
+		DisplayName:     strings.TrimSpace(req.DisplayName),
+		Description:     strings.TrimSpace(req.Description),
+		Enabled:         true,
+		TransferEnabled: req.TransferEnabled,
+		ExpiryPolicy:    req.ExpiryPolicy,
+		ExpiryDays:      req.ExpiryDays,
+		RankingMetric:   req.RankingMetric,
 	})
 	if err != nil {
 		return "", errors.New("create point type failed")
@@ -54,6 +67,26 @@ func (s *PointTypeService) Update(ctx context.Context, uri string, updates Point
 	if updates.Enabled != nil {
 		updated.Enabled = *updates.Enabled
 	}
+	if updates.TransferEnabled != nil {
+		updated.TransferEnabled = *updates.TransferEnabled
+	}
+	if updates.ExpiryPolicy != nil {
+		updated.ExpiryPolicy = *updates.ExpiryPolicy
+	}
+	if updates.ExpiryDays != nil {
+		updated.ExpiryDays = *updates.ExpiryDays
+	}
+	if err := validateExpiry(updated.ExpiryPolicy, updated.ExpiryDays); err != nil {
+		return err
+	}
+	// a new metric takes effect for new ledger entries; rebuild the ranking
+	// to rescore the existing ones
+	if updates.RankingMetric != nil {
+		if !updates.RankingMetric.Valid() {
+			return d.ErrInvalidRankingMetric
+		}
+		updated.RankingMetric = *updates.RankingMetric
+	}
 
 	return s.repo.UpdatePointType(ctx, updated)
 }
@@ -86,6 +119,14 @@ func (s *PointTypeService) Delete(ctx context.Context, uri string) error {
 	return s.repo.SoftDeletePointType(ctx, uri)
 }
 
+// validateExpiry checks an expiry policy and its TTL; changes apply to new credits only.
+func validateExpiry(policy d.ExpiryPolicy, days int) error {
+	if !policy.Valid() || days < 0 || (policy == d.ExpiryTTL && days == 0) {
+		return d.ErrInvalidExpiryPolicy
+	}
+	return nil
+}
+
 func (s *PointTypeService) GetByID(ctx context.Context, id int64) (*d.PointType, error) {
 	return s.repo.GetPointTypeByID(ctx, id)
 }
@@ -93,3 +134,19 @@ func (s *PointTypeService) GetByID(ctx context.Context, id int64) (*d.PointType,
 func (s *PointTypeService) List(ctx context.Context, limit, offset int) ([]d.PointType, error) {
 	return s.repo.ListPointTypes(ctx, limit, offset)
 }
+
+// listAllPointTypes pages through every point type that is not deleted.
+func listAllPointTypes(ctx context.Context, repo PointTypeRepository) ([]d.PointType, error) {
+	const page = 100
+	var res []d.PointType
+	for offset := 0; ; offset += page {
+		pts, err := repo.ListPointTypes(ctx, page, offset)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pts...)
+		if len(pts) < page {
+			return res, nil
+		}
+	}
+}
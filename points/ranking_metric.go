@@ -0,0 +1,143 @@
+package points
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// metricCacheTTL bounds how long the score writers keep using a point type's
+// previous ranking metric after it was changed.
+const metricCacheTTL = time.Minute
+
+// scoreWriter keeps the all-time rankings current. Under the balance metric a
+// score is the stored balance and is set while the balance row is locked.
+// The other metrics add each entry's contribution with ZINCRBY once the
+// ledger committed, so a rolled back write never reaches the ranking.
+type scoreWriter struct {
+	ranking RankingRepository
+	points  PointTypeRepository
+
+	mu      sync.Mutex
+	metrics map[int64]cachedMetric
+}
+
+type cachedMetric struct {
+	metric   d.RankingMetric
+	loadedAt time.Time
+}
+
+func newScoreWriter(rank RankingRepository, pts PointTypeRepository) *scoreWriter {
+	return &scoreWriter{ranking: rank, points: pts, metrics: map[int64]cachedMetric{}}
+}
+
+// metric returns the ranking metric of a point type from a short-lived cache.
+// ok is false when it cannot be looked up; the write is then skipped rather
+// than scored under the wrong metric.
+func (w *scoreWriter) metric(ctx context.Context, pointTypeID int64) (d.RankingMetric, bool) {
+	now := time.Now()
+	w.mu.Lock()
+	c, found := w.metrics[pointTypeID]
+	w.mu.Unlock()
+	if found && now.Sub(c.loadedAt) <= metricCacheTTL {
+		return c.metric, true
+	}
+	if w.points == nil {
+		return d.MetricBalance, true
+	}
+	pt, err := w.points.GetPointTypeByID(ctx, pointTypeID)
+	if err != nil {
+		return "", false
+	}
+	m := pt.RankingMetric
+	if m == "" {
+		m = d.MetricBalance
+	}
+	w.mu.Lock()
+	w.metrics[pointTypeID] = cachedMetric{metric: m, loadedAt: now}
+	w.mu.Unlock()
+	return m, true
+}
+
+// setBalance scores a user's new balance under the balance metric.
+func (w *scoreWriter) setBalance(ctx context.Context, pointTypeID int64, userID string, balance int64) {
+	w.setBalances(ctx, []ScoreUpdate{{PointTypeID: pointTypeID, UserID: userID, Score: balance}})
+}
+
+// setBalances is the batch form of setBalance.
+func (w *scoreWriter) setBalances(ctx context.Context, balances []ScoreUpdate) {
+	if w == nil || w.ranking == nil {
+		return
+	}
+	var updates []ScoreUpdate
+	for _, u := range balances {
+		if m, ok := w.metric(ctx, u.PointTypeID); ok && m == d.MetricBalance {
+			updates = append(updates, u)
+		}
+	}
+	if len(updates) > 0 {
+		_ = w.ranking.UpdateUserScores(ctx, updates)
+	}
+}
+
+// committed adds committed ledger entries to the rankings of point types
+// with an incremental metric. reversed is the entry that txs reverse, if any.
+// It must run once per entry, and only after the ledger committed.
+func (w *scoreWriter) committed(ctx context.Context, reversed *d.Transaction, txs ...d.Transaction) {
+	if w == nil || w.ranking == nil {
+		return
+	}
+	var updates []ScoreUpdate
+	for _, tx := range txs {
+		m, ok := w.metric(ctx, tx.PointTypeID)
+		if !ok {
+			continue
+		}
+		if delta := scoreDelta(m, tx, reversed); delta != 0 {
+			updates = append(updates, ScoreUpdate{PointTypeID: tx.PointTypeID, UserID: tx.UserID, Score: delta})
+		}
+	}
+	if len(updates) > 0 {
+		_ = w.ranking.IncrementUserScores(ctx, updates)
+	}
+}
+
+// scoreDelta is what one ledger entry adds to a score under an incremental
+// metric; the balance metric is not incremental and always yields zero.
+// Lifetime credits count credits except received transfers, net earned counts
+// every balance change except redemptions. A reversal undoes whatever its
+// original contributed.
+func scoreDelta(m d.RankingMetric, tx d.Transaction, reversed *d.Transaction) int64 {
+	switch m {
+	case d.MetricLifetimeCredits:
+		if tx.ReversalOf == "" {
+			if tx.Type == d.TransactionCredit && tx.TransferID == "" {
+				return tx.Amount
+			}
+			return 0
+		}
+		if reversed != nil && reversed.Type == d.TransactionCredit && reversed.TransferID == "" {
+			return -tx.Amount
+		}
+	case d.MetricNetEarned:
+		if isRedemption(tx) || (tx.ReversalOf != "" && reversed != nil && isRedemption(*reversed)) {
+			return 0
+		}
+		switch tx.Type {
+		case d.TransactionCredit:
+			return tx.Amount
+		case d.TransactionDebit, d.TransactionCapture:
+			return -tx.Amount
+		}
+	}
+	return 0
+}
+
+// isRedemption reports whether tx is a debit paying for a redemption order.
+// Only RedemptionService links debits to orders, so a debit posted with the
+// same reason through the balance API still counts.
+func isRedemption(tx d.Transaction) bool {
+	return tx.Type == d.TransactionDebit && tx.RedemptionID != "" && tx.ReversalOf == ""
+}
@@ -0,0 +1,81 @@
+package points
+
+import (
+	"context"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// RankingRebuildService repopulates the Redis rankings from MySQL, e.g. after
+// Redis was flushed or replaced, or a point type's ranking metric changed.
+// Balance rankings are read from user_balances, the others are replayed from
+// the transactions table.
+type RankingRebuildService struct {
+	balance BalanceRepository
+	points  PointTypeRepository
+	ranking RankingRepository
+}
+
+func NewRankingRebuildService(bal BalanceRepository, pts PointTypeRepository, rank RankingRepository) *RankingRebuildService {
+	return &RankingRebuildService{balance: bal, points: pts, ranking: rank}
+}
+
+// Rebuild rebuilds the ranking of one point type, or of every point type when
+// uri is empty.
+func (s *RankingRebuildService) Rebuild(ctx context.Context, uri string) ([]RankingRebuildResult, error) {
+	if uri != "" {
+		pt, err := s.points.GetPointTypeByURI(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		res, err := s.rebuild(ctx, *pt)
+		if err != nil {
+			return nil, err
+		}
+		return []RankingRebuildResult{res}, nil
+	}
+	return s.rebuildAll(ctx, false)
+}
+
+// RebuildMissing rebuilds only the rankings whose key does not exist. It is
+// cheap enough to run at startup.
+func (s *RankingRebuildService) RebuildMissing(ctx context.Context) ([]RankingRebuildResult, error) {
+	return s.rebuildAll(ctx, true)
+}
+
+func (s *RankingRebuildService) rebuildAll(ctx context.Context, missingOnly bool) ([]RankingRebuildResult, error) {
+	pts, err := listAllPointTypes(ctx, s.points)
+	if err != nil {
+		return nil, err
+	}
+	res := []RankingRebuildResult{}
+	for _, pt := range pts {
+		if missingOnly {
+			exists, err := s.ranking.Exists(ctx, pt.ID)
+			if err != nil {
+				return res, err
+			}
+			if exists {
+				continue
+			}
+		}
+		r, err := s.rebuild(ctx, pt)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (s *RankingRebuildService) rebuild(ctx context.Context, pt d.PointType) (RankingRebuildResult, error) {
+	start := time.Now()
+	n, err := s.ranking.Rebuild(ctx, pt.ID, func(add func(userID string, score int64) error) error {
+		return s.balance.StreamRankingScores(ctx, pt.ID, pt.RankingMetric, add)
+	})
+	if err != nil {
+		return RankingRebuildResult{}, err
+	}
+	return RankingRebuildResult{URI: pt.URI, PointTypeID: pt.ID, Users: n, DurationMs: time.Since(start).Milliseconds()}, nil
+}
@@ -0,0 +1,102 @@
+package points
+
+import (
+	"context"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// MaxSnapshotSize bounds the entries frozen by one snapshot.
+const MaxSnapshotSize = 10000
+
+// RankingSnapshotService freezes the top of rankings so distributions run
+// against fixed standings that can be inspected afterwards.
+type RankingSnapshotService struct {
+	repo  SnapshotRepository
+	ranks *rankingsService
+}
+
+func NewRankingSnapshotService(repo SnapshotRepository, rank RankingRepository, pts PointTypeRepository, seasons SeasonRepository) *RankingSnapshotService {
+	return &RankingSnapshotService{repo: repo, ranks: &rankingsService{ranking: rank, points: pts, seasons: seasons}}
+}
+
+// Create freezes the top req.TopN entries of a point type's ranking.
+func (s *RankingSnapshotService) Create(ctx context.Context, req RankingSnapshotCreateRequest) (*d.RankingSnapshot, error) {
+	if req.URI == "" {
+		return nil, d.ErrInvalidSnapshot
+	}
+	b, _, tie, err := s.ranks.resolve(ctx, req.URI, req.Window, req.TiePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return s.capture(ctx, b, req.TopN, tie)
+}
+
+// capture reads the top N with a single range read, so the entries come from
+// one state of the ranking, and stores them with their ranks.
+func (s *RankingSnapshotService) capture(ctx context.Context, b board, topN int, tie d.TiePolicy) (*d.RankingSnapshot, error) {
+	if topN <= 0 || topN > MaxSnapshotSize {
+		return nil, d.ErrInvalidSnapshot
+	}
+	items, err := s.ranks.ranking.GetTop(ctx, b.pointTypeID, b.window, 0, int64(topN-1))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ranks.assignRanks(ctx, b, items, 0, tie); err != nil {
+		return nil, err
+	}
+	participants, err := s.ranks.ranking.Count(ctx, b.pointTypeID, b.window)
+	if err != nil {
+		return nil, err
+	}
+	snap := d.RankingSnapshot{ID: newID(), PointTypeID: b.pointTypeID, Window: b.window, TiePolicy: tie, TopN: topN, Entries: len(items), Participants: max(participants, int64(len(items))), CreatedAt: time.Now().Unix()}
+	if err := s.repo.CreateSnapshot(ctx, snap, items); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// List returns snapshots newest first, of one point type when uri is set.
+func (s *RankingSnapshotService) List(ctx context.Context, uri string, limit, offset int) (*RankingSnapshotPage, error) {
+	var pointTypeID int64
+	if uri != "" {
+		pt, err := s.ranks.points.GetPointTypeByURI(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		pointTypeID = pt.ID
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	offset = max(offset, 0)
+	items, total, err := s.repo.ListSnapshots(ctx, pointTypeID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &RankingSnapshotPage{Items: items, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// Get returns a snapshot with a page of its entries, or only the entry of
+// userID when set.
+func (s *RankingSnapshotService) Get(ctx context.Context, id, userID string, limit, offset int) (*RankingSnapshotView, error) {
+	snap, err := s.repo.GetSnapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	offset = max(offset, 0)
+	items, err := s.repo.ListSnapshotEntries(ctx, id, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &RankingSnapshotView{Snapshot: *snap, Items: items, Limit: limit, Offset: offset}, nil
+}
+
+// entries returns the first n entries of a snapshot.
+func (s *RankingSnapshotService) entries(ctx context.Context, id string, n int) ([]d.RankingEntry, error) {
+	return s.repo.ListSnapshotEntries(ctx, id, "", n, 0)
+}
@@ -0,0 +1,141 @@
+package points
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+const (
+	// WindowRetention is how long a windowed ranking is kept after its window
+	// ends, so distributions can still reward the previous period.
+	WindowRetention = 35 * 24 * time.Hour
+	// seasonCacheTTL bounds how long a new season can go unnoticed by the
+	// score writers.
+	seasonCacheTTL = time.Minute
+)
+
+// windowPeriods are the calendar windows every earned point is counted in.
+var windowPeriods = []d.RankingPeriod{d.PeriodDaily, d.PeriodWeekly, d.PeriodMonthly}
+
+var seasonNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// resolveRankingWindow validates w. Calendar windows without a key select the
+// window containing now; seasons must exist. "all" is accepted for all-time.
+func resolveRankingWindow(ctx context.Context, seasons SeasonRepository, w d.RankingWindow, now time.Time) (d.RankingWindow, error) {
+	switch w.Period {
+	case d.PeriodAllTime, "all":
+		if w.Key != "" {
+			return w, d.ErrInvalidRankingWindow
+		}
+		return d.RankingWindow{}, nil
+	case d.PeriodDaily, d.PeriodWeekly, d.PeriodMonthly:
+		if w.Key == "" {
+			return d.WindowAt(w.Period, now), nil
+		}
+		if _, _, err := w.Bounds(); err != nil {
+			return w, err
+		}
+		return w, nil
+	case d.PeriodSeason:
+		if w.Key == "" || seasons == nil {
+			return w, d.ErrInvalidRankingWindow
+		}
+		if _, err := seasons.GetSeason(ctx, w.Key); err != nil {
+			return w, err
+		}
+		return w, nil
+	}
+	return w, d.ErrInvalidRankingWindow
+}
+
+// windowScores counts earned points in the windowed rankings: the current
+// day, ISO week and month, and every running season. Only credits count as
+// earned; transfers and reversals move existing points and are skipped.
+type windowScores struct {
+	ranking RankingRepository
+	seasons SeasonRepository
+
+	mu       sync.Mutex
+	cached   []d.RankingSeason
+	loadedAt time.Time
+}
+
+func newWindowScores(rank RankingRepository, seasons SeasonRepository) *windowScores {
+	return &windowScores{ranking: rank, seasons: seasons}
+}
+
+// record adds the earned transactions to their windows. Like the all-time
+// ranking update it is best effort and must run after the ledger committed.
+func (w *windowScores) record(ctx context.Context, txs []d.Transaction, now time.Time) {
+	if w == nil || w.ranking == nil {
+		return
+	}
+	seasons := w.runningSeasons(ctx, now)
+	var incs []WindowIncrement
+	for _, tx := range txs {
+		if tx.Type != d.TransactionCredit || tx.TransferID != "" || tx.ReversalOf != "" {
+			continue
+		}
+		for _, p := range windowPeriods {
+			win := d.WindowAt(p, now)
+			_, end, _ := win.Bounds()
+			incs = append(incs, WindowIncrement{PointTypeID: tx.PointTypeID, Window: win, UserID: tx.UserID, Amount: tx.Amount, ExpireAt: end.Add(WindowRetention).Unix()})
+		}
+		for _, season := range seasons {
+			win := d.RankingWindow{Period: d.PeriodSeason, Key: season.Name}
+			incs = append(incs, WindowIncrement{PointTypeID: tx.PointTypeID, Window: win, UserID: tx.UserID, Amount: tx.Amount, ExpireAt: time.Unix(season.EndsAt, 0).Add(WindowRetention).Unix()})
+		}
+	}
+	_ = w.ranking.IncrementWindowScores(ctx, incs)
+}
+
+// runningSeasons returns the seasons containing now from a short-lived cache.
+func (w *windowScores) runningSeasons(ctx context.Context, now time.Time) []d.RankingSeason {
+	if w.seasons == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.loadedAt.IsZero() || now.Sub(w.loadedAt) > seasonCacheTTL {
+		if seasons, err := w.seasons.ListUnfinishedSeasons(ctx, now.Unix()); err == nil {
+			w.cached, w.loadedAt = seasons, now
+		}
+	}
+	var res []d.RankingSeason
+	for _, s := range w.cached {
+		if s.Contains(now.Unix()) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// SeasonService manages the named windows of seasonal rankings.
+type SeasonService struct {
+	repo SeasonRepository
+}
+
+func NewSeasonService(repo SeasonRepository) *SeasonService {
+	return &SeasonService{repo: repo}
+}
+
+// Create adds a season. Seasons may overlap; points earned while several are
+// running count in each of them.
+func (s *SeasonService) Create(ctx context.Context, req RankingSeasonCreateRequest) (*d.RankingSeason, error) {
+	if !seasonNameRe.MatchString(req.Name) || req.StartsAt <= 0 || req.EndsAt <= req.StartsAt {
+		return nil, d.ErrInvalidSeason
+	}
+	season := d.RankingSeason{Name: req.Name, StartsAt: req.StartsAt, EndsAt: req.EndsAt, CreatedAt: time.Now().Unix()}
+	if err := s.repo.CreateSeason(ctx, season); err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+func (s *SeasonService) List(ctx context.Context) ([]d.RankingSeason, error) {
+	return s.repo.ListSeasons(ctx)
+}
@@ -0,0 +1,215 @@
+package points
+
+import (
+	"context"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+const (
+	// rankScanChunk is the page size used when counting distinct higher
+	// scores for dense ranks, and maxRankScanChunks bounds that scan for
+	// repositories that are not a DistinctScoreCounter.
+	rankScanChunk     = 1000
+	maxRankScanChunks = 100
+	// DefaultTopLimit and MaxTopLimit bound the page size of GetTop.
+	DefaultTopLimit = 100
+	MaxTopLimit     = 1000
+	// DefaultAroundNeighbours and MaxAroundNeighbours bound the window of
+	// GetAround on each side of the user.
+	DefaultAroundNeighbours = 5
+	MaxAroundNeighbours     = 50
+)
+
+// rankingsService implements RankingsService using repositories.
+type rankingsService struct {
+	ranking RankingRepository
+	points  PointTypeRepository
+	seasons SeasonRepository
+}
+
+func NewRankingsService(rank RankingRepository, pts PointTypeRepository, seasons SeasonRepository) RankingsService {
+	return &rankingsService{ranking: rank, points: pts, seasons: seasons}
+}
+
+// board identifies one ranking: a point type and a time window.
+type board struct {
+	pointTypeID int64
+	window      d.RankingWindow
+}
+
+// GetUserRank returns a user's rank and score.
+func (s *rankingsService) GetUserRank(ctx context.Context, uri string, w d.RankingWindow, userID string, tie d.TiePolicy) (*RankingUserView, error) {
+	b, meta, tie, err := s.resolve(ctx, uri, w, tie)
+	if err != nil {
+		return nil, err
+	}
+	pos, score, err := s.ranking.GetUserPosition(ctx, b.pointTypeID, b.window, userID)
+	if err != nil {
+		return nil, err
+	}
+	entry := []d.RankingEntry{{UserID: userID, Score: score}}
+	if err := s.assignRanks(ctx, b, entry, int(pos), tie); err != nil {
+		return nil, err
+	}
+	total, err := s.ranking.Count(ctx, b.pointTypeID, b.window)
+	if err != nil {
+		return nil, err
+	}
+	return &RankingUserView{PointType: meta, Window: b.window, TiePolicy: tie, User: entry[0], Total: total}, nil
+}
+
+// GetAround returns the window of positions around a user, so the user is
+// in the middle unless they are near the top or bottom of the ranking.
+func (s *rankingsService) GetAround(ctx context.Context, uri string, w d.RankingWindow, userID string, n int, tie d.TiePolicy) (*RankingAroundView, error) {
+	b, meta, tie, err := s.resolve(ctx, uri, w, tie)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		n = DefaultAroundNeighbours
+	}
+	n = min(n, MaxAroundNeighbours)
+	pos, _, err := s.ranking.GetUserPosition(ctx, b.pointTypeID, b.window, userID)
+	if err != nil {
+		return nil, err
+	}
+	start := max(pos-int64(n), 0)
+	items, err := s.ranking.GetTop(ctx, b.pointTypeID, b.window, start, pos+int64(n))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.assignRanks(ctx, b, items, int(start), tie); err != nil {
+		return nil, err
+	}
+	view := &RankingAroundView{PointType: meta, Window: b.window, TiePolicy: tie, Items: append([]d.RankingEntry{}, items...)}
+	for _, e := range items {
+		if e.UserID == userID {
+			view.User = e
+		}
+	}
+	if view.Total, err = s.ranking.Count(ctx, b.pointTypeID, b.window); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// resolve validates the tie policy, defaulting to standard ranking, resolves
+// the window and looks up the point type. An empty uri selects point type 0.
+func (s *rankingsService) resolve(ctx context.Context, uri string, w d.RankingWindow, tie d.TiePolicy) (board, *RankingPointType, d.TiePolicy, error) {
+	if tie == "" {
+		tie = d.TieStandard
+	}
+	if !tie.Valid() {
+		return board{}, nil, tie, d.ErrInvalidTiePolicy
+	}
+	w, err := resolveRankingWindow(ctx, s.seasons, w, time.Now())
+	if err != nil {
+		return board{}, nil, tie, err
+	}
+	if uri == "" || s.points == nil {
+		return board{window: w}, nil, tie, nil
+	}
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return board{}, nil, tie, err
+	}
+	return board{pointTypeID: pt.ID, window: w}, &RankingPointType{URI: pt.URI, DisplayName: pt.DisplayName}, tie, nil
+}
+
+func (s *rankingsService) GetTop(ctx context.Context, uri string, w d.RankingWindow, limit, offset int, tie d.TiePolicy) (*RankingPage, error) {
+	b, meta, tie, err := s.resolve(ctx, uri, w, tie)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultTopLimit
+	}
+	limit = min(limit, MaxTopLimit)
+	if offset < 0 {
+		offset = 0
+	}
+	page := &RankingPage{PointType: meta, Window: b.window, TiePolicy: tie, Limit: limit, Offset: offset}
+	start := int64(offset)
+	stop := int64(offset + limit - 1)
+	items, err := s.ranking.GetTop(ctx, b.pointTypeID, b.window, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.assignRanks(ctx, b, items, offset, tie); err != nil {
+		return nil, err
+	}
+	if page.Total, err = s.ranking.Count(ctx, b.pointTypeID, b.window); err != nil {
+		return nil, err
+	}
+	page.Items = append([]d.RankingEntry{}, items...)
+	return page, nil
+}
+
+// assignRanks ranks a page of entries starting at position offset. Only the
+// first entry needs a lookup; the rest follow from the scores in the page.
+func (s *rankingsService) assignRanks(ctx context.Context, b board, items []d.RankingEntry, offset int, tie d.TiePolicy) error {
+	if len(items) == 0 {
+		return nil
+	}
+	var rank int64
+	if tie == d.TieDense {
+		distinct, err := s.distinctAbove(ctx, b, items[0].Score)
+		if err != nil {
+			return err
+		}
+		rank = distinct + 1
+	} else {
+		above, err := s.ranking.CountAbove(ctx, b.pointTypeID, b.window, items[0].Score)
+		if err != nil {
+			return err
+		}
+		rank = above + 1
+	}
+	items[0].Rank = rank
+	for i := 1; i < len(items); i++ {
+		if items[i].Score != items[i-1].Score {
+			if tie == d.TieDense {
+				rank++
+			} else {
+				rank = int64(offset + i + 1)
+			}
+		}
+		items[i].Rank = rank
+	}
+	return nil
+}
+
+// distinctAbove counts the distinct scores above score. Without a
+// DistinctScoreCounter it walks the ranking from the top, so its cost grows
+// with the position being ranked; past maxRankScanChunks it fails with
+// ErrRankTooDeep.
+func (s *rankingsService) distinctAbove(ctx context.Context, b board, score int64) (int64, error) {
+	if c, ok := s.ranking.(DistinctScoreCounter); ok {
+		return c.CountDistinctAbove(ctx, b.pointTypeID, b.window, score)
+	}
+	var distinct int64
+	var last int64
+	for start := int64(0); ; start += rankScanChunk {
+		if start >= maxRankScanChunks*rankScanChunk {
+			return 0, d.ErrRankTooDeep
+		}
+		chunk, err := s.ranking.GetTop(ctx, b.pointTypeID, b.window, start, start+rankScanChunk-1)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range chunk {
+			if e.Score <= score {
+				return distinct, nil
+			}
+			if distinct == 0 || e.Score != last {
+				distinct++
+				last = e.Score
+			}
+		}
+		if len(chunk) < rankScanChunk {
+			return distinct, nil
+		}
+	}
+}
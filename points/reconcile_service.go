@@ -0,0 +1,292 @@
+package points
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// reconcileBatchSize is how many users are compared per ranking lookup.
+const reconcileBatchSize = 500
+
+// errLedgerInconsistent stops a balance repair when the ledger disagrees with
+// itself, since there is then no trustworthy value to repair to.
+var errLedgerInconsistent = errors.New("ledger is inconsistent, repair manually")
+
+// ReconcileService proves that balances, the ledger and the rankings agree.
+// The ledger is the source of truth: balance rows are repaired to the replayed
+// ledger total and ranking scores to the stored balance, or under the other
+// ranking metrics to the score replayed from the ledger.
+type ReconcileService struct {
+	balance BalanceRepository
+	recon   ReconciliationRepository
+	points  PointTypeRepository
+	ranking RankingRepository
+}
+
+func NewReconcileService(bal BalanceRepository, recon ReconciliationRepository, pts PointTypeRepository, rank RankingRepository) *ReconcileService {
+	return &ReconcileService{balance: bal, recon: recon, points: pts, ranking: rank}
+}
+
+// Reconcile compares, per user and point type, the stored balance, the
+// replayed ledger total, the after_balance of the last transaction and the
+// ranking score, and reports every mismatch. The comparison reads without
+// locks, so activity during the run can show up as mismatches; in fix mode
+// each one is re-checked before it is repaired, and every repair is recorded
+// under the report's run ID.
+func (s *ReconcileService) Reconcile(ctx context.Context, req ReconcileRequest) (*ReconcileReport, error) {
+	var pts []d.PointType
+	if req.URI != "" {
+		pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+		if err != nil {
+			return nil, err
+		}
+		pts = append(pts, *pt)
+	} else {
+		var err error
+		if pts, err = listAllPointTypes(ctx, s.points); err != nil {
+			return nil, err
+		}
+	}
+
+	rep := &ReconcileReport{RunID: newID(), Fix: req.Fix, StartedAt: time.Now().Unix(), Mismatches: []d.ReconciliationMismatch{}}
+	for _, pt := range pts {
+		found, err := s.comparePointType(ctx, pt, rep)
+		if err != nil {
+			return nil, err
+		}
+		if req.Fix {
+			s.fix(ctx, rep.RunID, pt, found)
+		}
+		rep.PointTypes++
+		rep.Mismatches = append(rep.Mismatches, found...)
+	}
+	for _, m := range rep.Mismatches {
+		if m.Fixed {
+			rep.Fixed++
+		}
+	}
+	rep.FinishedAt = time.Now().Unix()
+	return rep, nil
+}
+
+// comparePointType streams the ledger totals of one point type, looks up the
+// ranking scores in batches, and finally scans the ranking for members
+// without a balance row or transactions.
+func (s *ReconcileService) comparePointType(ctx context.Context, pt d.PointType, rep *ReconcileReport) ([]d.ReconciliationMismatch, error) {
+	var found []d.ReconciliationMismatch
+	seen := map[string]bool{}
+	batch := make([]LedgerTotal, 0, reconcileBatchSize)
+	check := func() error {
+		users := make([]string, len(batch))
+		for i, lt := range batch {
+			users[i] = lt.UserID
+		}
+		scores, err := s.ranking.GetUserScores(ctx, pt.ID, users)
+		if err != nil {
+			return err
+		}
+		expected, err := s.expectedScores(ctx, pt, batch)
+		if err != nil {
+			return err
+		}
+		for _, lt := range batch {
+			m := d.ReconciliationMismatch{UserID: lt.UserID, PointTypeID: pt.ID, URI: pt.URI, Balance: lt.Balance, LedgerTotal: lt.Total, LastAfter: lt.LastAfter}
+			if score, ok := scores[lt.UserID]; ok {
+				m.RankingScore = &score
+			}
+			if lt.Entries > 0 && lt.Total != lt.LastAfter {
+				m.Kind = d.ReconcileLedger
+				found = append(found, m)
+			}
+			if lt.Balance != lt.Total {
+				m.Kind = d.ReconcileBalance
+				found = append(found, m)
+			}
+			if lt.HasBalance && !scoreMatches(pt, m.RankingScore, expected[lt.UserID]) {
+				m.Kind = d.ReconcileRanking
+				found = append(found, m)
+			}
+			if !lt.HasBalance && m.RankingScore != nil {
+				m.Kind = d.ReconcileRankingOrphan
+				found = append(found, m)
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := s.recon.StreamLedgerTotals(ctx, pt.ID, func(lt LedgerTotal) error {
+		seen[lt.UserID] = true
+		rep.Checked++
+		if batch = append(batch, lt); len(batch) == reconcileBatchSize {
+			return check()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = check()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.ranking.ScanUserScores(ctx, pt.ID, func(userID string, score int64) error {
+		if seen[userID] {
+			return nil
+		}
+		found = append(found, d.ReconciliationMismatch{Kind: d.ReconcileRankingOrphan, UserID: userID, PointTypeID: pt.ID, URI: pt.URI, RankingScore: &score})
+		return nil
+	})
+	return found, err
+}
+
+// fix repairs balance rows before rankings, so ranking scores are set from
+// the repaired balance.
+func (s *ReconcileService) fix(ctx context.Context, runID string, pt d.PointType, found []d.ReconciliationMismatch) {
+	inconsistent, repaired := map[string]bool{}, map[string]bool{}
+	for _, m := range found {
+		if m.Kind == d.ReconcileLedger {
+			inconsistent[m.UserID] = true
+		}
+	}
+	for i := range found {
+		m := &found[i]
+		if m.Kind != d.ReconcileBalance {
+			continue
+		}
+		if inconsistent[m.UserID] {
+			m.FixError = errLedgerInconsistent.Error()
+			continue
+		}
+		fixed, err := s.fixBalance(ctx, runID, pt, m.UserID)
+		m.Fixed, repaired[m.UserID] = fixed, fixed && err == nil
+		if err != nil {
+			m.FixError = err.Error()
+		}
+	}
+	for i := range found {
+		m := &found[i]
+		if m.Kind != d.ReconcileRanking && m.Kind != d.ReconcileRankingOrphan {
+			continue
+		}
+		if repaired[m.UserID] {
+			// the balance repair already reset the score
+			m.Fixed = true
+			continue
+		}
+		fixed, err := s.fixRanking(ctx, runID, pt, m.UserID)
+		m.Fixed = fixed
+		if err != nil {
+			m.FixError = err.Error()
+		}
+	}
+}
+
+// fixBalance resets a balance row to its ledger total. The ledger is re-read
+// under the balance lock, so a mismatch caused by a concurrent write is left
+// alone. The ranking score follows the repaired balance.
+func (s *ReconcileService) fixBalance(ctx context.Context, runID string, pt d.PointType, userID string) (bool, error) {
+	pointTypeID := pt.ID
+	var ub *d.UserBalance
+	err := s.balance.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if ub, err = s.balance.GetUserBalanceForUpdate(ctx, userID, pointTypeID); err != nil {
+			return err
+		}
+		lt, err := s.recon.GetLedgerTotal(ctx, userID, pointTypeID)
+		if err != nil {
+			return err
+		}
+		if lt.Entries > 0 && lt.Total != lt.LastAfter {
+			return errLedgerInconsistent
+		}
+		if ub.Balance == lt.Total {
+			ub = nil
+			return nil
+		}
+		old, repaired := ub.Balance, lt.Total
+		ub.Balance = repaired
+		if err := s.balance.UpsertUserBalance(ctx, *ub); err != nil {
+			return err
+		}
+		return s.recon.InsertReconciliationFixes(ctx, []d.ReconciliationFix{{RunID: runID, Kind: d.ReconcileBalance, UserID: userID, PointTypeID: pointTypeID, OldValue: &old, NewValue: &repaired, CreatedAt: time.Now().Unix()}})
+	})
+	if err != nil || ub == nil {
+		return false, err
+	}
+	if _, err := s.fixRanking(ctx, runID, pt, userID); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// fixRanking sets the ranking score to its expected value, or removes the
+// member when the user has no balance row.
+func (s *ReconcileService) fixRanking(ctx context.Context, runID string, pt d.PointType, userID string) (bool, error) {
+	pointTypeID := pt.ID
+	lt, err := s.recon.GetLedgerTotal(ctx, userID, pointTypeID)
+	if err != nil {
+		return false, err
+	}
+	expected, err := s.expectedScores(ctx, pt, []LedgerTotal{lt})
+	if err != nil {
+		return false, err
+	}
+	scores, err := s.ranking.GetUserScores(ctx, pointTypeID, []string{userID})
+	if err != nil {
+		return false, err
+	}
+	fix := d.ReconciliationFix{RunID: runID, Kind: d.ReconcileRanking, UserID: userID, PointTypeID: pointTypeID, CreatedAt: time.Now().Unix()}
+	if score, ok := scores[userID]; ok {
+		fix.OldValue = &score
+	}
+	if !lt.HasBalance {
+		if fix.OldValue == nil {
+			return false, nil
+		}
+		fix.Kind = d.ReconcileRankingOrphan
+		if err := s.ranking.RemoveUsers(ctx, pointTypeID, userID); err != nil {
+			return false, err
+		}
+	} else {
+		if scoreMatches(pt, fix.OldValue, expected[userID]) {
+			return false, nil
+		}
+		score := expected[userID]
+		fix.NewValue = &score
+		if err := s.ranking.UpdateUserScore(ctx, pointTypeID, userID, score); err != nil {
+			return false, err
+		}
+	}
+	return true, s.recon.InsertReconciliationFixes(ctx, []d.ReconciliationFix{fix})
+}
+
+// expectedScores returns the ranking score each user should have: the stored
+// balance, or under the other metrics the score replayed from the ledger,
+// which is zero for users absent from the result.
+func (s *ReconcileService) expectedScores(ctx context.Context, pt d.PointType, totals []LedgerTotal) (map[string]int64, error) {
+	if pt.RankingMetric == "" || pt.RankingMetric == d.MetricBalance {
+		res := make(map[string]int64, len(totals))
+		for _, lt := range totals {
+			res[lt.UserID] = lt.Balance
+		}
+		return res, nil
+	}
+	users := make([]string, len(totals))
+	for i, lt := range totals {
+		users[i] = lt.UserID
+	}
+	return s.balance.GetRankingScores(ctx, pt.ID, pt.RankingMetric, users)
+}
+
+// scoreMatches reports whether a ranking score is the expected one. Under the
+// incremental metrics an unranked user is as good as a zero score.
+func scoreMatches(pt d.PointType, score *int64, expected int64) bool {
+	if score == nil {
+		return expected == 0 && pt.RankingMetric != "" && pt.RankingMetric != d.MetricBalance
+	}
+	return *score == expected
+}
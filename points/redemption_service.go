@@ -3,67 +3,228 @@ package points
 import (
 	"context"
 	"errors"
+	"maps"
+	"slices"
+	"time"
 
 	d "github.com/usual2970/acto/domain/points"
 )
 
+// RedemptionReason is the transaction reason of the debits paying for a
+// reward. Rankings by net earned points skip them by their RedemptionID, not
+// by this reason.
+const RedemptionReason = "redemption"
+
 type RedemptionService struct {
 	rewards RedemptionRepository
 	balance BalanceRepository
+	points  PointTypeRepository
+	scores  *scoreWriter
 }
 
-func NewRedemptionService(rew RedemptionRepository, bal BalanceRepository) *RedemptionService {
-	return &RedemptionService{rewards: rew, balance: bal}
+func NewRedemptionService(rew RedemptionRepository, bal BalanceRepository, rank RankingRepository, pts PointTypeRepository) *RedemptionService {
+	return &RedemptionService{rewards: rew, balance: bal, points: pts, scores: newScoreWriter(rank, pts)}
 }
 
-// Redeem performs a redemption by deducting required point type costs and creating a record.
-func (s *RedemptionService) Redeem(ctx context.Context, req RedemptionRequest) error {
+// RedemptionRefundReason is the transaction reason of the credits refunding
+// a cancelled redemption; each reverses one debit paying for it.
+const RedemptionRefundReason = "redemption refund"
+
+// Redeem pays the costs of a reward and creates a pending order for it.
+func (s *RedemptionService) Redeem(ctx context.Context, req RedemptionRequest) (*d.RedemptionRecord, error) {
 	reward, err := s.rewards.GetRewardByID(ctx, req.RewardID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !reward.Enabled {
-		return d.ErrUnauthorizedOperation
+	if !reward.Enabled || reward.ArchivedAt != nil {
+		return nil, d.ErrRewardUnavailable
 	}
-	if len(reward.Costs) == 0 {
-		return nil
+	if reward.Quantity <= 0 {
+		return nil, d.ErrRewardOutOfStock
+	}
+	costs := make(map[int64]int64, len(reward.Costs))
+	for uri, cost := range reward.Costs {
+		pt, err := s.points.GetPointTypeByURI(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		costs[pt.ID] = cost
 	}
+	// lock balances in point type order so concurrent redemptions cannot deadlock
+	ptIDs := slices.Sorted(maps.Keys(costs))
 
-	return s.balance.WithTx(ctx, func(ctx context.Context) error {
+	rec := d.RedemptionRecord{ID: newID(), UserID: req.UserID, RewardID: req.RewardID, Status: d.RedemptionPending}
+	err = s.balance.WithTx(ctx, func(ctx context.Context) error {
+		// Reserve the stock first: the reward row lock orders concurrent
+		// redemptions, and a sold out reward fails before any balance is locked.
+		if err := s.rewards.DecrementInventory(ctx, req.RewardID, 1); err != nil {
+			return err
+		}
+		// with the reward row locked the counts cannot change until commit
+		if err := s.checkLimits(ctx, reward, req.UserID, time.Now()); err != nil {
+			return err
+		}
 		// Check all balances first
-		for ptID, cost := range reward.Costs {
+		for _, ptID := range ptIDs {
 			ub, err := s.balance.GetUserBalanceForUpdate(ctx, req.UserID, ptID)
 			if err != nil {
 				return err
 			}
-			if ub.Balance < cost {
+			if ub.Available() < costs[ptID] {
 				return d.ErrInsufficientBalance
 			}
 		}
 		// Deduct for each cost
-		for ptID, cost := range reward.Costs {
+		for _, ptID := range ptIDs {
+			cost := costs[ptID]
 			ub, err := s.balance.GetUserBalanceForUpdate(ctx, req.UserID, ptID)
 			if err != nil {
 				return err
 			}
+			if err := consumeLots(ctx, s.balance, req.UserID, ptID, ub.Balance, cost); err != nil {
+				return err
+			}
 			before := ub.Balance
 			ub.Balance = before - cost
 			if err := s.balance.UpsertUserBalance(ctx, *ub); err != nil {
 				return err
 			}
-			if _, err := s.balance.InsertTransaction(ctx, d.Transaction{UserID: req.UserID, PointTypeID: ptID, Amount: cost, Type: d.TransactionDebit, Reason: "redemption", Before: before, After: ub.Balance}); err != nil {
+			txID, err := s.balance.InsertTransaction(ctx, d.Transaction{UserID: req.UserID, PointTypeID: ptID, Amount: cost, Type: d.TransactionDebit, Reason: RedemptionReason, Before: before, After: ub.Balance, RedemptionID: rec.ID})
+			if err != nil {
 				return err
 			}
+			rec.Payments = append(rec.Payments, d.RedemptionPayment{PointTypeID: ptID, Amount: cost, TransactionID: txID})
+			s.scores.setBalance(ctx, ptID, req.UserID, ub.Balance)
 		}
-		// Create record (note: inventory decrement not in same tx due to different repo)
-		if err := s.rewards.DecrementInventory(ctx, req.RewardID, 1); err != nil {
+		_, err := s.rewards.CreateRedemptionRecord(ctx, rec)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.rewards.GetRedemptionRecord(ctx, rec.ID, false)
+}
+
+// checkLimits fails with ErrRedemptionLimitReached when one more redemption
+// would exceed a limit of the reward. It must run inside the transaction
+// that reserved the stock.
+func (s *RedemptionService) checkLimits(ctx context.Context, reward *d.RedemptionReward, userID string, now time.Time) error {
+	for _, l := range reward.Limits {
+		by := userID
+		if l.Scope == d.LimitGlobal {
+			by = ""
+		}
+		n, err := s.rewards.CountRedemptions(ctx, reward.ID, by, l.Since(now))
+		if err != nil {
+			return err
+		}
+		if n >= l.Max {
+			return d.ErrRedemptionLimitReached
+		}
+	}
+	return nil
+}
+
+// Get returns one redemption order.
+func (s *RedemptionService) Get(ctx context.Context, id string) (*d.RedemptionRecord, error) {
+	return s.rewards.GetRedemptionRecord(ctx, id, false)
+}
+
+// List returns redemption orders, newest first.
+func (s *RedemptionService) List(ctx context.Context, f RedemptionFilter) (*RedemptionPage, error) {
+	if f.Limit <= 0 {
+		f.Limit = 20
+	}
+	f.Offset = max(f.Offset, 0)
+	items, total, err := s.rewards.ListRedemptionRecords(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return &RedemptionPage{Items: items, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
+
+// Fulfill marks a pending order as delivered; note may carry the delivery
+// details, such as a tracking number.
+func (s *RedemptionService) Fulfill(ctx context.Context, id string, req RedemptionTransitionRequest) (*d.RedemptionRecord, error) {
+	err := s.balance.WithTx(ctx, func(ctx context.Context) error {
+		rec, err := s.rewards.GetRedemptionRecord(ctx, id, true)
+		if err != nil {
 			return err
 		}
-		if _, err := s.rewards.CreateRedemptionRecord(ctx, d.RedemptionRecord{UserID: req.UserID, RewardID: req.RewardID, Status: d.RedemptionCompleted}); err != nil {
+		if !rec.Status.CanTransitionTo(d.RedemptionFulfilled) {
+			return d.ErrRedemptionTransition
+		}
+		return s.rewards.UpdateRedemptionStatus(ctx, id, rec.Status, d.RedemptionFulfilled, req.Note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.rewards.GetRedemptionRecord(ctx, id, false)
+}
+
+// Cancel cancels a pending order. In one ledger transaction it reverses
+// each debit that paid for the order and puts the reward back in stock.
+//
+// It takes its locks in the order Redeem does: the reward row, then the
+// order rows, then the balances, so the two cannot deadlock.
+func (s *RedemptionService) Cancel(ctx context.Context, id string, req RedemptionTransitionRequest) (*d.RedemptionRecord, error) {
+	pending, err := s.rewards.GetRedemptionRecord(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	err = s.balance.WithTx(ctx, func(ctx context.Context) error {
+		// restocking locks the reward row; a failed transition rolls it back
+		if err := s.rewards.ReleaseInventory(ctx, pending.RewardID, 1); err != nil {
 			return err
 		}
-		return nil
+		rec, err := s.rewards.GetRedemptionRecord(ctx, id, true)
+		if err != nil {
+			return err
+		}
+		if !rec.Status.CanTransitionTo(d.RedemptionCancelled) {
+			return d.ErrRedemptionTransition
+		}
+		for _, p := range rec.Payments {
+			if err := s.refund(ctx, rec, p); err != nil {
+				return err
+			}
+		}
+		return s.rewards.UpdateRedemptionStatus(ctx, id, rec.Status, d.RedemptionCancelled, req.Note)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return s.rewards.GetRedemptionRecord(ctx, id, false)
+}
+
+// refund credits back one payment as the reversal of its debit. It must run
+// inside BalanceRepository.WithTx.
+func (s *RedemptionService) refund(ctx context.Context, rec *d.RedemptionRecord, p d.RedemptionPayment) error {
+	userID := rec.UserID
+	pt, err := s.points.GetPointTypeByID(ctx, p.PointTypeID)
+	if err != nil {
+		return err
+	}
+	ub, err := s.balance.GetUserBalanceForUpdate(ctx, userID, p.PointTypeID)
+	if err != nil {
+		return err
+	}
+	tx := d.Transaction{UserID: userID, PointTypeID: p.PointTypeID, Amount: p.Amount, Type: d.TransactionCredit, Reason: RedemptionRefundReason, Before: ub.Balance, After: ub.Balance + p.Amount, ReversalOf: p.TransactionID, RedemptionID: rec.ID}
+	ub.Balance = tx.After
+	if err := s.balance.UpsertUserBalance(ctx, *ub); err != nil {
+		return err
+	}
+	if tx.ID, err = s.balance.InsertTransaction(ctx, tx); err != nil {
+		return err
+	}
+	if err := s.balance.MarkTransactionReversed(ctx, p.TransactionID, tx.ID); err != nil {
+		return err
+	}
+	if err := addLot(ctx, s.balance, pt, tx, time.Now()); err != nil {
+		return err
+	}
+	s.scores.setBalance(ctx, p.PointTypeID, userID, ub.Balance)
+	return nil
 }
 
 var ErrInvalidRequest = errors.New("invalid redemption request")
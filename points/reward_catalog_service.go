@@ -0,0 +1,150 @@
+package points
+
+import (
+	"context"
+	"strings"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// RewardCatalogService manages the rewards users redeem points for. Admin
+// operations are scoped to a point type: they see the rewards with a cost in
+// it.
+type RewardCatalogService struct {
+	repo   RedemptionRepository
+	points PointTypeRepository
+}
+
+func NewRewardCatalogService(repo RedemptionRepository, pts PointTypeRepository) *RewardCatalogService {
+	return &RewardCatalogService{repo: repo, points: pts}
+}
+
+// Create adds a reward priced in the point type uri, among others.
+func (s *RewardCatalogService) Create(ctx context.Context, uri string, req RewardCreateRequest) (*d.RedemptionReward, error) {
+	reward := d.RedemptionReward{ID: newID(), Name: strings.TrimSpace(req.Name), Description: strings.TrimSpace(req.Description), Costs: req.Costs, Quantity: req.Quantity, Enabled: req.Enabled == nil || *req.Enabled, Limits: req.Limits}
+	if err := s.validate(ctx, uri, reward); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.CreateReward(ctx, reward); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRewardByID(ctx, reward.ID)
+}
+
+// List returns the rewards with a cost in the point type uri.
+func (s *RewardCatalogService) List(ctx context.Context, uri string, req RewardListRequest) (*RewardPage, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return s.list(ctx, RewardFilter{PointTypeID: pt.ID, Query: strings.TrimSpace(req.Query), Enabled: req.Enabled, InStock: req.InStock, IncludeArchived: req.IncludeArchived, Limit: req.Limit, Offset: req.Offset})
+}
+
+// Catalog lists the rewards users can redeem now: enabled, in stock and not
+// archived, optionally only those with a cost in the point type uri.
+func (s *RewardCatalogService) Catalog(ctx context.Context, uri string, limit, offset int) (*RewardPage, error) {
+	enabled := true
+	f := RewardFilter{Enabled: &enabled, InStock: true, Limit: limit, Offset: offset}
+	if uri != "" {
+		pt, err := s.points.GetPointTypeByURI(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		f.PointTypeID = pt.ID
+	}
+	return s.list(ctx, f)
+}
+
+func (s *RewardCatalogService) list(ctx context.Context, f RewardFilter) (*RewardPage, error) {
+	if f.Limit <= 0 {
+		f.Limit = 20
+	}
+	f.Offset = max(f.Offset, 0)
+	items, total, err := s.repo.ListRewards(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return &RewardPage{Items: items, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
+
+// Update changes the given fields; Costs, when set, replaces all costs.
+// Enabling and disabling a reward also go through Update.
+func (s *RewardCatalogService) Update(ctx context.Context, uri, id string, req RewardUpdateRequest) (*d.RedemptionReward, error) {
+	reward, err := s.get(ctx, uri, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		reward.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		reward.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Costs != nil {
+		reward.Costs = req.Costs
+	}
+	if req.Enabled != nil {
+		reward.Enabled = *req.Enabled
+	}
+	if req.Limits != nil {
+		reward.Limits = *req.Limits
+	}
+	if err := s.validate(ctx, uri, *reward); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateReward(ctx, *reward); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRewardByID(ctx, id)
+}
+
+// Archive removes a reward from the catalog for good.
+func (s *RewardCatalogService) Archive(ctx context.Context, uri, id string) error {
+	if _, err := s.get(ctx, uri, id); err != nil {
+		return err
+	}
+	return s.repo.ArchiveReward(ctx, id)
+}
+
+// Restock adds req.Quantity to the stock of a reward.
+func (s *RewardCatalogService) Restock(ctx context.Context, uri, id string, req RewardRestockRequest) (*d.RedemptionReward, error) {
+	if req.Quantity <= 0 {
+		return nil, d.ErrInvalidReward
+	}
+	if _, err := s.get(ctx, uri, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RestockReward(ctx, id, req.Quantity); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRewardByID(ctx, id)
+}
+
+// get returns a reward with a cost in the point type uri.
+func (s *RewardCatalogService) get(ctx context.Context, uri, id string) (*d.RedemptionReward, error) {
+	reward, err := s.repo.GetRewardByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := reward.Costs[uri]; !ok {
+		return nil, d.ErrRewardNotFound
+	}
+	return reward, nil
+}
+
+// validate checks a reward and that it stays priced in the point type uri
+// and in point types that exist.
+func (s *RewardCatalogService) validate(ctx context.Context, uri string, reward d.RedemptionReward) error {
+	if err := reward.Validate(); err != nil {
+		return err
+	}
+	if _, ok := reward.Costs[uri]; !ok {
+		return d.ErrInvalidReward
+	}
+	for costURI := range reward.Costs {
+		if pt, err := s.points.GetPointTypeByURI(ctx, costURI); err != nil || pt == nil {
+			return d.ErrPointTypeNotFound
+		}
+	}
+	return nil
+}
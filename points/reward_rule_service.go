@@ -0,0 +1,197 @@
+package points
+
+import (
+	"context"
+
+	d "github.com/usual2970/acto/domain/points"
+)
+
+// RewardRuleService manages the reward rules of point types. Rules of one
+// point type are written under a lock on its rules, so two concurrent writes
+// cannot both pass the overlap check.
+type RewardRuleService struct {
+	rewards RewardRepository
+	balance BalanceRepository
+	points  PointTypeRepository
+}
+
+func NewRewardRuleService(rew RewardRepository, bal BalanceRepository, pts PointTypeRepository) *RewardRuleService {
+	return &RewardRuleService{rewards: rew, balance: bal, points: pts}
+}
+
+// Create adds a rule, active unless req.Active is false.
+func (s *RewardRuleService) Create(ctx context.Context, req RewardRuleCreateRequest) (*d.RewardRule, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, req.URI)
+	if err != nil {
+		return nil, err
+	}
+	if req.Mode == "" {
+		req.Mode = d.RuleFixed
+	}
+	rule := d.RewardRule{
+		ID: newID(), PointTypeID: pt.ID, Mode: req.Mode,
+		MinRank: req.MinRank, MaxRank: req.MaxRank, PercentileFrom: req.PercentileFrom, PercentileTo: req.PercentileTo, MinScore: req.MinScore,
+		RewardAmount: req.RewardAmount, ShareBps: req.ShareBps,
+		Active: req.Active == nil || *req.Active, StartsAt: req.StartsAt, EndsAt: req.EndsAt,
+	}
+	if rule.RewardPointTypeID, err = s.rewardPointType(ctx, req.RewardURI); err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, rule, true); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// List returns every rule of a point type, inactive ones included.
+func (s *RewardRuleService) List(ctx context.Context, uri string) ([]d.RewardRule, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return s.rewards.ListAllRules(ctx, pt.ID)
+}
+
+// Update changes the given fields of a rule; it also activates and
+// deactivates rules.
+func (s *RewardRuleService) Update(ctx context.Context, uri, id string, req RewardRuleUpdateRequest) (*d.RewardRule, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.rewards.GetRule(ctx, pt.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Mode != nil {
+		rule.Mode = *req.Mode
+	}
+	if req.MinRank != nil {
+		rule.MinRank = *req.MinRank
+	}
+	if req.MaxRank != nil {
+		rule.MaxRank = *req.MaxRank
+	}
+	if req.PercentileFrom != nil {
+		rule.PercentileFrom = *req.PercentileFrom
+	}
+	if req.PercentileTo != nil {
+		rule.PercentileTo = *req.PercentileTo
+	}
+	if req.MinScore != nil {
+		rule.MinScore = *req.MinScore
+	}
+	if req.RewardAmount != nil {
+		rule.RewardAmount = *req.RewardAmount
+	}
+	if req.ShareBps != nil {
+		rule.ShareBps = *req.ShareBps
+	}
+	if req.RewardURI != nil {
+		if rule.RewardPointTypeID, err = s.rewardPointType(ctx, *req.RewardURI); err != nil {
+			return nil, err
+		}
+	}
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+	if req.StartsAt != nil {
+		rule.StartsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		rule.EndsAt = *req.EndsAt
+	}
+	if err := s.save(ctx, *rule, false); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *RewardRuleService) Delete(ctx context.Context, uri, id string) error {
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return err
+	}
+	return s.rewards.DeleteRule(ctx, pt.ID, id)
+}
+
+// rewardPointType resolves the point type a rule pays out in.
+func (s *RewardRuleService) rewardPointType(ctx context.Context, uri string) (int64, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil || pt == nil {
+		return 0, d.ErrPointTypeNotFound
+	}
+	return pt.ID, nil
+}
+
+// save validates rule and writes it unless it overlaps another active rule
+// of its point type, or the shares of a pool that can apply at the same time
+// would add up to more than the whole pool.
+func (s *RewardRuleService) save(ctx context.Context, rule d.RewardRule, create bool) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	return s.balance.WithTx(ctx, func(ctx context.Context) error {
+		rules, err := s.rewards.ListRulesForUpdate(ctx, rule.PointTypeID)
+		if err != nil {
+			return err
+		}
+		shares := rule.ShareBps
+		for _, other := range rules {
+			if other.ID == rule.ID {
+				continue
+			}
+			if rule.Overlaps(other) {
+				return d.ErrRewardRuleOverlap
+			}
+			if other.RewardPointTypeID == rule.RewardPointTypeID && rule.ShareBps > 0 && rule.Coincides(other) {
+				shares += other.ShareBps
+			}
+		}
+		if shares > d.MaxShareBps {
+			return d.ErrInvalidRewardRule
+		}
+		if create {
+			_, err = s.rewards.CreateRule(ctx, rule)
+			return err
+		}
+		return s.rewards.UpdateRule(ctx, rule)
+	})
+}
+
+// FundPool adds to the pool of a reward point type that the pool-share rules
+// of the point type uri split.
+func (s *RewardRuleService) FundPool(ctx context.Context, uri string, req RewardPoolFundRequest) (*d.RewardPool, error) {
+	if req.Amount <= 0 {
+		return nil, d.ErrInvalidRewardPool
+	}
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	rewardID, err := s.rewardPointType(ctx, req.RewardURI)
+	if err != nil {
+		return nil, err
+	}
+	var pool *d.RewardPool
+	err = s.balance.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.rewards.AddToRewardPool(ctx, pt.ID, rewardID, req.Amount); err != nil {
+			return err
+		}
+		pool, err = s.rewards.LockRewardPool(ctx, pt.ID, rewardID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// ListPools returns the reward pools of a point type.
+func (s *RewardRuleService) ListPools(ctx context.Context, uri string) ([]d.RewardPool, error) {
+	pt, err := s.points.GetPointTypeByURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return s.rewards.ListRewardPools(ctx, pt.ID)
+}
@@ -2,6 +2,7 @@ package points
 
 import (
 	"context"
+	"time"
 
 	d "github.com/usual2970/acto/domain/points"
 )
@@ -23,41 +24,281 @@ type PointTypeRepository interface {
 type BalanceRepository interface {
 	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
 	GetUserBalanceForUpdate(ctx context.Context, userID string, pointTypeID int64) (*d.UserBalance, error)
+	GetUserBalance(ctx context.Context, userID string, pointTypeID int64) (*d.UserBalance, error)
 	UpsertUserBalance(ctx context.Context, ub d.UserBalance) error
 	InsertTransaction(ctx context.Context, tx d.Transaction) (string, error)
+	// GetTransactionByIdempotencyKey returns nil, nil when the key is unused.
+	GetTransactionByIdempotencyKey(ctx context.Context, key string) (*d.Transaction, error)
 	ListTransactions(ctx context.Context, userID string, filter TransactionFilter) ([]d.Transaction, int, error)
+	GetTransaction(ctx context.Context, transactionID string, lock bool) (*d.Transaction, error)
+	MarkTransactionReversed(ctx context.Context, transactionID, reversalID string) error
+
+	// Streaming reads used by exports; an empty userID matches every user and
+	// a zero pointTypeID every point type.
+	StreamTransactions(ctx context.Context, userID string, filter TransactionFilter, fn func(d.Transaction) error) error
+	StreamUserBalances(ctx context.Context, pointTypeID int64, fn func(d.UserBalance) error) error
+
+	// Ranking scores replayed from MySQL under a ranking metric. Under the
+	// balance metric every balance row is scored; under the others users whose
+	// score is zero are left out.
+	StreamRankingScores(ctx context.Context, pointTypeID int64, metric d.RankingMetric, fn func(userID string, score int64) error) error
+	GetRankingScores(ctx context.Context, pointTypeID int64, metric d.RankingMetric, userIDs []string) (map[string]int64, error)
+
+	// Multi-row variants used by batch operations.
+	GetUserBalancesForUpdate(ctx context.Context, keys []BalanceKey) ([]d.UserBalance, error)
+	UpsertUserBalances(ctx context.Context, ubs []d.UserBalance) error
+	InsertTransactions(ctx context.Context, txs []d.Transaction) ([]string, error)
+	InsertLots(ctx context.Context, lots []d.BalanceLot) error
+
+	// Lots track unspent credits for point types with an expiry policy.
+	InsertLot(ctx context.Context, lot d.BalanceLot) (string, error)
+	ListOpenLotsForUpdate(ctx context.Context, userID string, pointTypeID int64) ([]d.BalanceLot, error)
+	GetLotForUpdate(ctx context.Context, lotID string) (*d.BalanceLot, error)
+	UpdateLotRemaining(ctx context.Context, lotID string, remaining int64) error
+	ListExpiredLots(ctx context.Context, now int64, limit int) ([]d.BalanceLot, error)
+	SumExpiringLots(ctx context.Context, userID string, pointTypeID int64, before int64) (int64, error)
+
+	// Holds reserve part of a balance for a later capture or release.
+	InsertHold(ctx context.Context, h d.BalanceHold) error
+	GetHoldForUpdate(ctx context.Context, holdID string) (*d.BalanceHold, error)
+	UpdateHoldStatus(ctx context.Context, holdID string, status d.BalanceHoldStatus) error
+	ListExpiredHolds(ctx context.Context, now int64, limit int) ([]d.BalanceHold, error)
 }
 
 type RankingRepository interface {
 	UpdateUserScore(ctx context.Context, pointTypeID int64, userID string, score int64) error
-	GetTop(ctx context.Context, pointTypeID int64, start, stop int64) ([]string, error)
+	// GetTop returns entries from start to stop, highest score first. Rank is
+	// left unset for the caller to assign.
+	GetTop(ctx context.Context, pointTypeID int64, w d.RankingWindow, start, stop int64) ([]d.RankingEntry, error)
+	Count(ctx context.Context, pointTypeID int64, w d.RankingWindow) (int64, error)
+	// CountAbove returns the number of users with a score strictly above score.
+	CountAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error)
+	// GetUserPosition returns the 0-based position of a user, highest score
+	// first, and their score. It fails with ErrUserNotRanked.
+	GetUserPosition(ctx context.Context, pointTypeID int64, w d.RankingWindow, userID string) (int64, int64, error)
+	// IncrementWindowScores adds earned points to time-windowed rankings.
+	IncrementWindowScores(ctx context.Context, incs []WindowIncrement) error
+	// UpdateUserScores applies many score updates in one round trip.
+	UpdateUserScores(ctx context.Context, updates []ScoreUpdate) error
+	// IncrementUserScores adds each update's Score to the user's score.
+	IncrementUserScores(ctx context.Context, updates []ScoreUpdate) error
+	// GetUserScores returns the scores of the given users; users that are not
+	// ranked are absent from the map.
+	GetUserScores(ctx context.Context, pointTypeID int64, userIDs []string) (map[string]int64, error)
+	// ScanUserScores calls fn for every ranked user, in no particular order.
+	ScanUserScores(ctx context.Context, pointTypeID int64, fn func(userID string, score int64) error) error
+	RemoveUsers(ctx context.Context, pointTypeID int64, userIDs ...string) error
+	// Rebuild replaces a ranking with the scores passed to add by fill. Readers
+	// keep seeing the old ranking until fill returns without error.
+	Rebuild(ctx context.Context, pointTypeID int64, fill func(add func(userID string, score int64) error) error) (int64, error)
+	Exists(ctx context.Context, pointTypeID int64) (bool, error)
+}
+
+// DistinctScoreCounter is implemented by ranking repositories that count
+// distinct scores without walking the ranking. Dense ranks use it when the
+// RankingRepository offers it.
+type DistinctScoreCounter interface {
+	// CountDistinctAbove returns the number of distinct scores above score.
+	CountDistinctAbove(ctx context.Context, pointTypeID int64, w d.RankingWindow, score int64) (int64, error)
 }
 
 // RankingsService provides read-only ranking queries for delivery layer
 type RankingsService interface {
-	GetTop(ctx context.Context, pointTypeName string, limit, offset int) ([]string, error)
+	GetTop(ctx context.Context, pointTypeName string, w d.RankingWindow, limit, offset int, tie d.TiePolicy) (*RankingPage, error)
+	GetUserRank(ctx context.Context, pointTypeName string, w d.RankingWindow, userID string, tie d.TiePolicy) (*RankingUserView, error)
+	// GetAround returns the user together with up to n neighbours on each side.
+	GetAround(ctx context.Context, pointTypeName string, w d.RankingWindow, userID string, n int, tie d.TiePolicy) (*RankingAroundView, error)
 }
 
 type RewardRepository interface {
 	CreateRule(ctx context.Context, rr d.RewardRule) (string, error)
+	GetRule(ctx context.Context, pointTypeID int64, id string) (*d.RewardRule, error)
+	// ListRules returns the active rules of a point type, valid or not.
 	ListRules(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error)
+	ListAllRules(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error)
+	// ListRulesForUpdate returns all rules of a point type and keeps others
+	// from changing or adding any until the transaction in ctx ends.
+	ListRulesForUpdate(ctx context.Context, pointTypeID int64) ([]d.RewardRule, error)
+	UpdateRule(ctx context.Context, rr d.RewardRule) error
+	DeleteRule(ctx context.Context, pointTypeID int64, id string) error
+
+	ListRewardPools(ctx context.Context, pointTypeID int64) ([]d.RewardPool, error)
+	// LockRewardPool reads a pool with a row lock held until the transaction
+	// in ctx ends; a pool never funded reads as empty.
+	LockRewardPool(ctx context.Context, pointTypeID, rewardPointTypeID int64) (*d.RewardPool, error)
+	AddToRewardPool(ctx context.Context, pointTypeID, rewardPointTypeID, delta int64) error
 	CreateDistribution(ctx context.Context, rd d.RewardDistribution) (string, error)
+	GetDistribution(ctx context.Context, id string) (*d.RewardDistribution, error)
+	// GetDistributionByPeriod finds the distribution of a schedule, 0 for
+	// runs on request, and period key.
+	GetDistributionByPeriod(ctx context.Context, pointTypeID, scheduleID int64, periodKey string) (*d.RewardDistribution, error)
 	MarkDistributionCompleted(ctx context.Context, distributionID string) error
+	MarkDistributionFailed(ctx context.Context, distributionID string) error
+
+	CreateDistributionItems(ctx context.Context, items []d.RewardDistributionItem) error
+	// ListDistributionItems returns items in position order, optionally only
+	// those in the given statuses.
+	ListDistributionItems(ctx context.Context, distributionID string, statuses ...d.DistributionItemStatus) ([]d.RewardDistributionItem, error)
+	// LockDistributionItem reads an item with a row lock held until the
+	// transaction in ctx ends.
+	LockDistributionItem(ctx context.Context, id int64) (*d.RewardDistributionItem, error)
+	MarkDistributionItemPaid(ctx context.Context, id int64, transactionID string) error
+	MarkDistributionItemFailed(ctx context.Context, id int64, reason string) error
+}
+
+// SnapshotRepository stores frozen ranking tops.
+type SnapshotRepository interface {
+	// CreateSnapshot stores a snapshot and its entries, in ranking order, at once.
+	CreateSnapshot(ctx context.Context, s d.RankingSnapshot, entries []d.RankingEntry) error
+	GetSnapshot(ctx context.Context, id string) (*d.RankingSnapshot, error)
+	// ListSnapshots returns a point type's snapshots, newest first, and their
+	// total count; a zero pointTypeID lists every point type.
+	ListSnapshots(ctx context.Context, pointTypeID int64, limit, offset int) ([]d.RankingSnapshot, int, error)
+	// ListSnapshotEntries returns entries in ranking order, optionally only
+	// those of one user.
+	ListSnapshotEntries(ctx context.Context, snapshotID, userID string, limit, offset int) ([]d.RankingEntry, error)
+}
+
+// DistributionScheduleRepository stores the schedules of recurring reward
+// distributions.
+type DistributionScheduleRepository interface {
+	CreateSchedule(ctx context.Context, s d.DistributionSchedule) (int64, error)
+	GetSchedule(ctx context.Context, id int64) (*d.DistributionSchedule, error)
+	ListSchedules(ctx context.Context) ([]d.DistributionSchedule, error)
+	// ListDueSchedules returns the enabled schedules with NextRunAt <= now.
+	ListDueSchedules(ctx context.Context, now int64) ([]d.DistributionSchedule, error)
+	UpdateSchedule(ctx context.Context, s d.DistributionSchedule) error
+	DeleteSchedule(ctx context.Context, id int64) error
+}
+
+// Locker hands out named locks shared by every replica.
+type Locker interface {
+	// TryLock takes the lock without waiting; ok is false while another
+	// holder has it. The lock is dropped after ttl if release is never called.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (release func(), ok bool, err error)
 }
 
 type RedemptionRepository interface {
 	CreateReward(ctx context.Context, r d.RedemptionReward) (string, error)
 	GetRewardByID(ctx context.Context, rewardID string) (*d.RedemptionReward, error)
+	ListRewards(ctx context.Context, f RewardFilter) ([]d.RedemptionReward, int, error)
+	UpdateReward(ctx context.Context, r d.RedemptionReward) error
+	ArchiveReward(ctx context.Context, rewardID string) error
+	// RestockReward adds quantity to the stock of a reward not archived.
+	RestockReward(ctx context.Context, rewardID string, quantity int) error
+	// DecrementInventory takes quantity from the stock within the current
+	// transaction and counts it as redeemed; it fails with
+	// ErrRewardOutOfStock when the stock is short.
 	DecrementInventory(ctx context.Context, rewardID string, quantity int) error
+	ReleaseInventory(ctx context.Context, rewardID string, quantity int) error
+	// CountRedemptions counts the redemptions of a reward since a unix time
+	// that were not cancelled, by userID or by everyone when it is empty.
+	CountRedemptions(ctx context.Context, rewardID, userID string, since int64) (int, error)
 	CreateRedemptionRecord(ctx context.Context, rr d.RedemptionRecord) (string, error)
+	// GetRedemptionRecord returns a record with its payments; lock holds a row
+	// lock until the transaction ends.
+	GetRedemptionRecord(ctx context.Context, id string, lock bool) (*d.RedemptionRecord, error)
+	ListRedemptionRecords(ctx context.Context, f RedemptionFilter) ([]d.RedemptionRecord, int, error)
+	// UpdateRedemptionStatus fails with ErrRedemptionTransition when the
+	// record is no longer in status from.
+	UpdateRedemptionStatus(ctx context.Context, id string, from, to d.RedemptionStatus, note string) error
+}
+
+type ImportJobRepository interface {
+	CreateImportJob(ctx context.Context, job d.ImportJob) error
+	UpdateImportJob(ctx context.Context, job d.ImportJob) error
+	// TouchImportJob sets updated_at to now.
+	TouchImportJob(ctx context.Context, jobID string) error
+	// FailStaleImportJobs fails the pending and running jobs last updated
+	// before the unix time before with the given error.
+	FailStaleImportJobs(ctx context.Context, before int64, reason string) (int, error)
+	GetImportJob(ctx context.Context, jobID string) (*d.ImportJob, error)
+	InsertImportRowErrors(ctx context.Context, rows []d.ImportRowError) error
+	ListImportRowErrors(ctx context.Context, jobID string, limit, offset int) ([]d.ImportRowError, int, error)
+}
+
+// SeasonRepository stores the named windows of seasonal rankings.
+type SeasonRepository interface {
+	CreateSeason(ctx context.Context, s d.RankingSeason) error
+	GetSeason(ctx context.Context, name string) (*d.RankingSeason, error)
+	ListSeasons(ctx context.Context) ([]d.RankingSeason, error)
+	// ListUnfinishedSeasons returns running and upcoming seasons.
+	ListUnfinishedSeasons(ctx context.Context, now int64) ([]d.RankingSeason, error)
+}
+
+// ReconciliationRepository reads the ledger totals compared by ledger
+// reconciliation and records the repairs it makes.
+type ReconciliationRepository interface {
+	// StreamLedgerTotals calls fn for every user of a point type that has a
+	// balance row or a transaction, in user ID order.
+	StreamLedgerTotals(ctx context.Context, pointTypeID int64, fn func(LedgerTotal) error) error
+	GetLedgerTotal(ctx context.Context, userID string, pointTypeID int64) (LedgerTotal, error)
+	InsertReconciliationFixes(ctx context.Context, fixes []d.ReconciliationFix) error
 }
 
 // TransactionFilter defines optional filters and pagination for listing transactions
 type TransactionFilter struct {
 	PointTypeID   int64
-	OperationType string // "credit" | "debit" | ""
+	OperationType string // a d.TransactionType or "" for all
 	StartTime     int64  // Unix timestamp or 0
 	EndTime       int64  // Unix timestamp or 0
 	Limit         int
 	Offset        int
 }
+
+// RewardFilter selects catalog rewards; zero fields do not filter.
+type RewardFilter struct {
+	PointTypeID     int64  // rewards with a cost in this point type
+	Query           string // part of the name
+	Enabled         *bool
+	InStock         bool
+	IncludeArchived bool
+	Limit           int
+	Offset          int
+}
+
+// RedemptionFilter selects redemption records; zero fields do not filter.
+type RedemptionFilter struct {
+	UserID   string
+	RewardID string
+	Status   d.RedemptionStatus
+	Limit    int
+	Offset   int
+}
+
+// BalanceKey identifies one user_balances row
+type BalanceKey struct {
+	UserID      string
+	PointTypeID int64
+}
+
+// LedgerTotal compares a stored balance with its ledger. Total replays the
+// ledger: credits add, debits and captures subtract, holds and releases only
+// move points between available and held.
+type LedgerTotal struct {
+	UserID      string
+	PointTypeID int64
+	HasBalance  bool // a user_balances row exists
+	Balance     int64
+	Entries     int64 // number of transactions
+	Total       int64
+	LastAfter   int64 // after_balance of the latest transaction
+}
+
+// WindowIncrement adds points to a time-windowed ranking. The ranking expires
+// at ExpireAt (unix seconds).
+type WindowIncrement struct {
+	PointTypeID int64
+	Window      d.RankingWindow
+	UserID      string
+	Amount      int64
+	ExpireAt    int64
+}
+
+// ScoreUpdate sets, or under IncrementUserScores adds to, a user's ranking
+// score for a point type
+type ScoreUpdate struct {
+	PointTypeID int64
+	UserID      string
+	Score       int64
+}
@@ -65,24 +65,24 @@ export const leaderboardApi = {
   getList: (pointsTypeId: string) => axios.get(`/points-types/${pointsTypeId}/leaderboard`),
 };
 
-// 奖励相关接口
+// 奖励相关接口（管理接口按积分类型编码 uri 定位，而非 id）
 export const rewardApi = {
   // 获取奖励列表
-  getList: (pointsTypeId: string) => axios.get(`/points-types/${pointsTypeId}/rewards`),
+  getList: (pointTypeUri: string) => axios.get(`/admin/v1/point-types/${pointTypeUri}/rewards`),
 
   // 获取奖励记录
   getRecords: (pointsTypeId: string) => axios.get(`/points-types/${pointsTypeId}/reward-records`),
 
   // 创建奖励
-  create: (pointsTypeId: string, data: any) => axios.post(`/points-types/${pointsTypeId}/rewards`, data),
+  create: (pointTypeUri: string, data: any) => axios.post(`/admin/v1/point-types/${pointTypeUri}/rewards`, data),
 
   // 更新奖励
-  update: (pointsTypeId: string, rewardId: string, data: any) =>
-    axios.put(`/points-types/${pointsTypeId}/rewards/${rewardId}`, data),
+  update: (pointTypeUri: string, rewardId: string, data: any) =>
+    axios.patch(`/admin/v1/point-types/${pointTypeUri}/rewards/${rewardId}`, data),
 
   // 删除奖励
-  delete: (pointsTypeId: string, rewardId: string) =>
-    axios.delete(`/points-types/${pointsTypeId}/rewards/${rewardId}`),
+  delete: (pointTypeUri: string, rewardId: string) =>
+    axios.delete(`/admin/v1/point-types/${pointTypeUri}/rewards/${rewardId}`),
 };
 
 // 登录相关接口